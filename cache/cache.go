@@ -0,0 +1,283 @@
+// Package cache implements a sharded LRU cache with charge-based eviction.
+package cache
+
+import (
+	"hash/crc32"
+	"sync"
+	"sync/atomic"
+
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+// Cache maps keys to values, entries are evicted in LRU order once the total charge exceeds the capacity.
+// Entries returned by Insert and Lookup are pinned by their handles, a pinned entry is never released
+// before its handle is released, even if it is evicted or erased from the cache.
+type Cache interface {
+	// Insert: insert a mapping from key to value with the given charge against the capacity,
+	// the old entry of key is replaced. deleter is called with the key and value once the entry
+	// is no longer in the cache and all its handles are released, it may be nil.
+	// The returned handle must be released when the value is no longer needed.
+	Insert(key slice.Slice, value interface{}, charge int, deleter func(key slice.Slice, value interface{})) *Handle
+	// Lookup: return the handle of the entry of key, nil if there is no such entry.
+	// The returned handle must be released when the value is no longer needed.
+	Lookup(key slice.Slice) *Handle
+	// Release: release a handle returned by Insert or Lookup
+	Release(h *Handle)
+	// Erase: remove the entry of key from the cache, it is released once all its handles are released
+	Erase(key slice.Slice)
+	// NewID: return a new numeric id, clients sharing a cache use it to partition the key space
+	NewID() uint64
+	// TotalCharge: the total charge of the entries in the cache
+	TotalCharge() int
+	// Hits: the number of successful lookups
+	Hits() uint64
+	// Misses: the number of failed lookups
+	Misses() uint64
+}
+
+// Handle pins an entry of the cache
+type Handle struct {
+	key     string
+	value   interface{}
+	charge  int
+	deleter func(key slice.Slice, value interface{})
+	hash    uint32
+
+	// references held by handles and by the cache itself
+	refs    int
+	inCache bool
+
+	// entries referenced only by the cache are linked in the lru list of their shard
+	prev, next *Handle
+}
+
+// Value: the value of the pinned entry
+func (h *Handle) Value() interface{} {
+	return h.value
+}
+
+const (
+	numShardBits = 4
+	numShards    = 1 << numShardBits
+)
+
+type shardedLRUCache struct {
+	shards [numShards]lruShard
+	lastID uint64
+	hits   uint64
+	misses uint64
+}
+
+var _ Cache = (*shardedLRUCache)(nil)
+
+// NewLRUCache: create a cache holding entries of at most capacity total charge, usually the size in bytes.
+// A cache of capacity 0 keeps nothing, inserted entries are released as soon as their handles are.
+func NewLRUCache(capacity int) Cache {
+	c := &shardedLRUCache{}
+	perShard := (capacity + numShards - 1) / numShards
+	for i := range c.shards {
+		c.shards[i].init(perShard)
+	}
+
+	return c
+}
+
+func (c *shardedLRUCache) shard(hash uint32) *lruShard {
+	return &c.shards[hash>>(32-numShardBits)]
+}
+
+func (c *shardedLRUCache) Insert(key slice.Slice, value interface{}, charge int,
+	deleter func(key slice.Slice, value interface{})) *Handle {
+	hash := crc32.ChecksumIEEE(key)
+	return c.shard(hash).insert(string(key), hash, value, charge, deleter)
+}
+
+func (c *shardedLRUCache) Lookup(key slice.Slice) *Handle {
+	hash := crc32.ChecksumIEEE(key)
+	h := c.shard(hash).lookup(string(key))
+	if h == nil {
+		atomic.AddUint64(&c.misses, 1)
+	} else {
+		atomic.AddUint64(&c.hits, 1)
+	}
+
+	return h
+}
+
+func (c *shardedLRUCache) Release(h *Handle) {
+	c.shard(h.hash).release(h)
+}
+
+func (c *shardedLRUCache) Erase(key slice.Slice) {
+	hash := crc32.ChecksumIEEE(key)
+	c.shard(hash).erase(string(key))
+}
+
+func (c *shardedLRUCache) NewID() uint64 {
+	return atomic.AddUint64(&c.lastID, 1)
+}
+
+func (c *shardedLRUCache) TotalCharge() int {
+	total := 0
+	for i := range c.shards {
+		total += c.shards[i].totalCharge()
+	}
+
+	return total
+}
+
+func (c *shardedLRUCache) Hits() uint64 {
+	return atomic.LoadUint64(&c.hits)
+}
+
+func (c *shardedLRUCache) Misses() uint64 {
+	return atomic.LoadUint64(&c.misses)
+}
+
+// lruShard is a LRU cache protected by its own lock
+type lruShard struct {
+	mu       sync.Mutex
+	capacity int
+	usage    int
+	table    map[string]*Handle
+	// dummy head of the lru list, lru.next is the oldest entry
+	lru Handle
+}
+
+func (s *lruShard) init(capacity int) {
+	s.capacity = capacity
+	s.table = make(map[string]*Handle)
+	s.lru.next = &s.lru
+	s.lru.prev = &s.lru
+}
+
+func (s *lruShard) insert(key string, hash uint32, value interface{}, charge int,
+	deleter func(key slice.Slice, value interface{})) *Handle {
+	h := &Handle{
+		key:     key,
+		value:   value,
+		charge:  charge,
+		deleter: deleter,
+		hash:    hash,
+		refs:    1, // for the returned handle
+	}
+
+	var released []*Handle
+	s.mu.Lock()
+	if s.capacity > 0 {
+		h.refs++ // for the cache
+		h.inCache = true
+		s.usage += charge
+		if old, ok := s.table[key]; ok {
+			released = s.finishErase(old, released)
+		}
+		s.table[key] = h
+	}
+
+	for s.usage > s.capacity && s.lru.next != &s.lru {
+		oldest := s.lru.next
+		delete(s.table, oldest.key)
+		released = s.finishErase(oldest, released)
+	}
+	s.mu.Unlock()
+
+	callDeleters(released)
+
+	return h
+}
+
+func (s *lruShard) lookup(key string) *Handle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.table[key]
+	if !ok {
+		return nil
+	}
+	s.ref(h)
+
+	return h
+}
+
+func (s *lruShard) release(h *Handle) {
+	s.mu.Lock()
+	released := s.unref(h, nil)
+	s.mu.Unlock()
+
+	callDeleters(released)
+}
+
+func (s *lruShard) erase(key string) {
+	var released []*Handle
+	s.mu.Lock()
+	if h, ok := s.table[key]; ok {
+		delete(s.table, key)
+		released = s.finishErase(h, released)
+	}
+	s.mu.Unlock()
+
+	callDeleters(released)
+}
+
+func (s *lruShard) totalCharge() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.usage
+}
+
+// ref: add a reference to h, an entry in the lru list is removed from it since it is pinned now
+func (s *lruShard) ref(h *Handle) {
+	if h.refs == 1 && h.inCache {
+		listRemove(h)
+	}
+	h.refs++
+}
+
+// unref: drop a reference of h, h is appended to released once it has no reference,
+// or is moved back to the lru list if only the cache references it
+func (s *lruShard) unref(h *Handle, released []*Handle) []*Handle {
+	h.refs--
+	switch {
+	case h.refs == 0:
+		released = append(released, h)
+	case h.refs == 1 && h.inCache:
+		listAppend(&s.lru, h)
+	}
+
+	return released
+}
+
+// finishErase: drop the reference of the cache to h, which is already removed from the table
+func (s *lruShard) finishErase(h *Handle, released []*Handle) []*Handle {
+	if h.refs == 1 {
+		listRemove(h)
+	}
+	h.inCache = false
+	s.usage -= h.charge
+
+	return s.unref(h, released)
+}
+
+// callDeleters: call deleters outside the lock, so that they may use the cache
+func callDeleters(released []*Handle) {
+	for _, h := range released {
+		if h.deleter != nil {
+			h.deleter(slice.Slice(h.key), h.value)
+		}
+	}
+}
+
+func listRemove(h *Handle) {
+	h.prev.next = h.next
+	h.next.prev = h.prev
+	h.prev, h.next = nil, nil
+}
+
+// listAppend: make h the newest entry of the list
+func listAppend(head, h *Handle) {
+	h.next = head
+	h.prev = head.prev
+	h.prev.next = h
+	h.next.prev = h
+}
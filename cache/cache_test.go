@@ -0,0 +1,238 @@
+package cache
+
+import (
+	"encoding/binary"
+	"sync"
+	"testing"
+
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+// testCache records the values released by deleters
+type testCache struct {
+	Cache
+	mu       sync.Mutex
+	released []int
+}
+
+func newTestCache(capacity int) *testCache {
+	return &testCache{Cache: NewLRUCache(capacity)}
+}
+
+func encodeKey(k int) slice.Slice {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(k))
+
+	return b
+}
+
+func (c *testCache) deleter(_ slice.Slice, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.released = append(c.released, value.(int))
+}
+
+func (c *testCache) insert(key, value, charge int) {
+	c.Release(c.Insert(encodeKey(key), value, charge, c.deleter))
+}
+
+// lookup: return the value of key, -1 if it does not exist
+func (c *testCache) lookup(key int) int {
+	h := c.Lookup(encodeKey(key))
+	if h == nil {
+		return -1
+	}
+	defer c.Release(h)
+
+	return h.Value().(int)
+}
+
+func TestCache_HitAndMiss(t *testing.T) {
+	c := newTestCache(1000)
+	if got := c.lookup(100); got != -1 {
+		t.Errorf("lookup(100) = %d, want -1", got)
+	}
+
+	c.insert(100, 101, 1)
+	if got := c.lookup(100); got != 101 {
+		t.Errorf("lookup(100) = %d, want 101", got)
+	}
+
+	c.insert(200, 201, 1)
+	c.insert(100, 102, 1)
+	if got := c.lookup(100); got != 102 {
+		t.Errorf("lookup(100) = %d, want 102", got)
+	}
+	if got := c.lookup(200); got != 201 {
+		t.Errorf("lookup(200) = %d, want 201", got)
+	}
+	if len(c.released) != 1 || c.released[0] != 101 {
+		t.Errorf("released = %v, want [101]", c.released)
+	}
+
+	if c.Hits() != 3 || c.Misses() != 1 {
+		t.Errorf("hits = %d, misses = %d, want 3, 1", c.Hits(), c.Misses())
+	}
+}
+
+func TestCache_Erase(t *testing.T) {
+	c := newTestCache(1000)
+	c.Erase(encodeKey(200))
+	if len(c.released) != 0 {
+		t.Errorf("erase of missing key released %v", c.released)
+	}
+
+	c.insert(100, 101, 1)
+	c.insert(200, 201, 1)
+	c.Erase(encodeKey(100))
+	if got := c.lookup(100); got != -1 {
+		t.Errorf("lookup(100) = %d, want -1", got)
+	}
+	if got := c.lookup(200); got != 201 {
+		t.Errorf("lookup(200) = %d, want 201", got)
+	}
+	if len(c.released) != 1 || c.released[0] != 101 {
+		t.Errorf("released = %v, want [101]", c.released)
+	}
+}
+
+func TestCache_PinnedEntries(t *testing.T) {
+	c := newTestCache(1000)
+	h1 := c.Insert(encodeKey(100), 101, 1, c.deleter)
+
+	// replace the pinned entry, the old value is alive until h1 is released
+	c.insert(100, 102, 1)
+	h2 := c.Lookup(encodeKey(100))
+	if h1.Value().(int) != 101 || h2.Value().(int) != 102 {
+		t.Errorf("values = %v, %v, want 101, 102", h1.Value(), h2.Value())
+	}
+	if len(c.released) != 0 {
+		t.Errorf("released = %v, want none", c.released)
+	}
+
+	c.Release(h1)
+	if len(c.released) != 1 || c.released[0] != 101 {
+		t.Errorf("released = %v, want [101]", c.released)
+	}
+
+	c.Erase(encodeKey(100))
+	if got := c.lookup(100); got != -1 {
+		t.Errorf("lookup(100) = %d, want -1", got)
+	}
+	if len(c.released) != 1 {
+		t.Errorf("released = %v, erased entry should be alive while pinned", c.released)
+	}
+
+	c.Release(h2)
+	if len(c.released) != 2 || c.released[1] != 102 {
+		t.Errorf("released = %v, want [101 102]", c.released)
+	}
+}
+
+func TestCache_EvictionPolicy(t *testing.T) {
+	c := newTestCache(numShards * 100)
+	c.insert(100, 101, 1)
+	c.insert(200, 201, 1)
+	c.insert(300, 301, 1)
+	h := c.Lookup(encodeKey(300))
+
+	// frequently used and pinned entries survive, others are evicted
+	for i := 0; i < numShards*1000; i++ {
+		c.insert(1000+i, 2000+i, 1)
+		if got := c.lookup(1000 + i); got != 2000+i {
+			t.Fatalf("lookup(%d) = %d, want %d", 1000+i, got, 2000+i)
+		}
+		if got := c.lookup(100); got != 101 {
+			t.Fatalf("lookup(100) = %d, want 101", got)
+		}
+	}
+	if got := c.lookup(100); got != 101 {
+		t.Errorf("lookup(100) = %d, want 101", got)
+	}
+	if got := c.lookup(200); got != -1 {
+		t.Errorf("lookup(200) = %d, want -1", got)
+	}
+	if got := c.lookup(300); got != 301 {
+		t.Errorf("lookup(300) = %d, want 301", got)
+	}
+	c.Release(h)
+}
+
+func TestCache_Charge(t *testing.T) {
+	capacity := numShards * 100
+	c := newTestCache(capacity)
+
+	// insert entries of various charges, the total charge never exceeds the capacity
+	for i := 0; i < 10000; i++ {
+		c.insert(i, i, 1+i%10)
+		if got := c.TotalCharge(); got > capacity {
+			t.Fatalf("total charge %d exceeds capacity %d", got, capacity)
+		}
+	}
+	if got := c.TotalCharge(); got < capacity/2 {
+		t.Errorf("total charge %d, want at least %d", got, capacity/2)
+	}
+
+	// an entry larger than the capacity is kept while it is pinned, and evicted once released
+	h := c.Insert(encodeKey(-1), 12345, capacity*2, c.deleter)
+	if got := c.lookup(-1); got != 12345 {
+		t.Errorf("lookup(-1) = %d, want 12345", got)
+	}
+	c.Release(h)
+	for i := 0; i < 10000; i++ {
+		c.insert(i, i, 1)
+	}
+	if got := c.lookup(-1); got != -1 {
+		t.Errorf("lookup(-1) = %d, want -1", got)
+	}
+	if got := c.TotalCharge(); got > capacity {
+		t.Errorf("total charge %d exceeds capacity %d", got, capacity)
+	}
+}
+
+func TestCache_ZeroCapacity(t *testing.T) {
+	c := newTestCache(0)
+	h := c.Insert(encodeKey(100), 101, 1, c.deleter)
+	if h.Value().(int) != 101 {
+		t.Errorf("value = %v, want 101", h.Value())
+	}
+	if got := c.lookup(100); got != -1 {
+		t.Errorf("lookup(100) = %d, want -1", got)
+	}
+
+	c.Release(h)
+	if len(c.released) != 1 || c.released[0] != 101 {
+		t.Errorf("released = %v, want [101]", c.released)
+	}
+}
+
+func TestCache_NewID(t *testing.T) {
+	c := NewLRUCache(100)
+	a, b := c.NewID(), c.NewID()
+	if a == b {
+		t.Errorf("NewID() returns duplicated id %d", a)
+	}
+}
+
+func TestCache_Concurrent(t *testing.T) {
+	c := newTestCache(numShards * 10)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := (g*1000 + i) % 300
+				if got := c.lookup(key); got != -1 && got != key {
+					t.Errorf("lookup(%d) = %d", key, got)
+				}
+				c.insert(key, key, 1)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if got := c.TotalCharge(); got > numShards*10 {
+		t.Errorf("total charge %d exceeds capacity %d", got, numShards*10)
+	}
+}
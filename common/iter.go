@@ -14,8 +14,16 @@ type Iterator interface {
 	// 	value := iter.Value()
 	// }
 	Find(key slice.Slice)
+	// SeekToFirst move the iterator to the first key
+	SeekToFirst()
+	// SeekToLast move the iterator to the last key
+	SeekToLast()
 	// Key returns key of the pair to which the iterator is pointing
 	Key() slice.Slice
 	// Value returns value of the pair to which the iterator is pointing
 	Value() slice.Slice
+	// Err returns the error the iterator has encountered, if any
+	Err() error
+	// Release releases the resources held by the iterator, the iterator must not be used after released
+	Release()
 }
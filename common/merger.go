@@ -0,0 +1,215 @@
+package common
+
+import (
+	"container/heap"
+
+	"github.com/goleveldb/goleveldb/comparator"
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+type direction int
+
+const (
+	forward direction = iota
+	reverse
+)
+
+// mergingIterator merges several sorted iterators into one sorted iterator.
+// The children that are still valid are kept in a heap, the top of which is the current entry:
+// a min-heap when iterating forward and a max-heap when iterating backward.
+// For equal keys, the child with the smaller index comes first in forward order.
+type mergingIterator struct {
+	cmp       comparator.Comparator
+	children  []Iterator
+	heap      iterHeap
+	direction direction
+}
+
+var _ Iterator = (*mergingIterator)(nil)
+
+// NewMergingIterator: create an iterator over the union of children, whose keys are all ordered by cmp
+func NewMergingIterator(cmp comparator.Comparator, children ...Iterator) Iterator {
+	m := &mergingIterator{
+		cmp:      cmp,
+		children: children,
+	}
+	m.heap.cmp = cmp
+
+	return m
+}
+
+func (m *mergingIterator) Success() bool {
+	return m.heap.Len() > 0
+}
+
+func (m *mergingIterator) SeekToFirst() {
+	for _, child := range m.children {
+		child.SeekToFirst()
+	}
+	m.rebuild(forward)
+}
+
+func (m *mergingIterator) SeekToLast() {
+	for _, child := range m.children {
+		child.SeekToLast()
+	}
+	m.rebuild(reverse)
+}
+
+func (m *mergingIterator) Find(key slice.Slice) {
+	for _, child := range m.children {
+		child.Find(key)
+	}
+	m.rebuild(forward)
+}
+
+func (m *mergingIterator) Next() {
+	if !m.Success() {
+		return
+	}
+
+	// when switching from backward, all non-current children are positioned before the current entry,
+	// move each of them to the first entry after the current entry
+	if m.direction != forward {
+		current, key := m.heap.items[0].index, m.Key()
+		for index, child := range m.children {
+			if index == current {
+				continue
+			}
+
+			// an equal key in a child with a smaller index comes before the current entry
+			child.Find(key)
+			if child.Success() && m.cmp.Compare(key, child.Key()) == 0 && index < current {
+				child.Next()
+			}
+		}
+		m.rebuild(forward)
+	}
+
+	m.current().Next()
+	m.fixTop()
+}
+
+func (m *mergingIterator) Prev() {
+	if !m.Success() {
+		return
+	}
+
+	// when switching from forward, all non-current children are positioned after the current entry,
+	// move each of them to the last entry before the current entry
+	if m.direction != reverse {
+		current, key := m.heap.items[0].index, m.Key()
+		for index, child := range m.children {
+			if index == current {
+				continue
+			}
+
+			// an equal key in a child with a smaller index comes before the current entry
+			child.Find(key)
+			if !child.Success() {
+				child.SeekToLast()
+			} else if m.cmp.Compare(key, child.Key()) != 0 || index > current {
+				child.Prev()
+			}
+		}
+		m.rebuild(reverse)
+	}
+
+	m.current().Prev()
+	m.fixTop()
+}
+
+func (m *mergingIterator) Key() slice.Slice {
+	return m.current().Key()
+}
+
+func (m *mergingIterator) Value() slice.Slice {
+	return m.current().Value()
+}
+
+// Err: return the first error encountered by the children
+func (m *mergingIterator) Err() error {
+	for _, child := range m.children {
+		if err := child.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *mergingIterator) Release() {
+	for _, child := range m.children {
+		child.Release()
+	}
+}
+
+func (m *mergingIterator) current() Iterator {
+	return m.heap.items[0].iter
+}
+
+// rebuild: rebuild the heap in direction d from the children that are still valid
+func (m *mergingIterator) rebuild(d direction) {
+	m.direction = d
+	m.heap.reverse = d == reverse
+	m.heap.items = m.heap.items[:0]
+	for index, child := range m.children {
+		if child.Success() {
+			m.heap.items = append(m.heap.items, heapItem{iter: child, index: index})
+		}
+	}
+	heap.Init(&m.heap)
+}
+
+// fixTop: restore the heap after the current child moved
+func (m *mergingIterator) fixTop() {
+	if m.current().Success() {
+		heap.Fix(&m.heap, 0)
+	} else {
+		heap.Pop(&m.heap)
+	}
+}
+
+type heapItem struct {
+	iter  Iterator
+	index int // index of the child, used to order equal keys
+}
+
+// iterHeap implements heap.Interface, the top is the smallest key, or the largest key if reverse is set
+type iterHeap struct {
+	cmp     comparator.Comparator
+	items   []heapItem
+	reverse bool
+}
+
+func (h *iterHeap) Len() int {
+	return len(h.items)
+}
+
+func (h *iterHeap) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	res := h.cmp.Compare(a.iter.Key(), b.iter.Key())
+	if res == 0 {
+		res = a.index - b.index
+	}
+	if h.reverse {
+		return res > 0
+	}
+
+	return res < 0
+}
+
+func (h *iterHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+}
+
+func (h *iterHeap) Push(x interface{}) {
+	h.items = append(h.items, x.(heapItem))
+}
+
+func (h *iterHeap) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+
+	return last
+}
@@ -0,0 +1,227 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/goleveldb/goleveldb/comparator"
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+// sliceIterator iterates over sorted keys, the value of each entry is the name of the iterator
+type sliceIterator struct {
+	cmp  comparator.Comparator
+	keys []string
+	name string
+	pos  int
+	err  error
+
+	released bool
+}
+
+func newSliceIterator(cmp comparator.Comparator, name string, keys ...string) *sliceIterator {
+	sort.Slice(keys, func(i, j int) bool { return cmp.Compare(slice.Slice(keys[i]), slice.Slice(keys[j])) < 0 })
+
+	return &sliceIterator{cmp: cmp, keys: keys, name: name, pos: -1}
+}
+
+func (s *sliceIterator) Success() bool { return s.pos >= 0 && s.pos < len(s.keys) }
+func (s *sliceIterator) Prev()         { s.pos-- }
+func (s *sliceIterator) Next()         { s.pos++ }
+func (s *sliceIterator) SeekToFirst()  { s.pos = 0 }
+func (s *sliceIterator) SeekToLast()   { s.pos = len(s.keys) - 1 }
+func (s *sliceIterator) Key() slice.Slice {
+	return slice.Slice(s.keys[s.pos])
+}
+func (s *sliceIterator) Value() slice.Slice { return slice.Slice(s.name) }
+func (s *sliceIterator) Err() error         { return s.err }
+func (s *sliceIterator) Release()           { s.released = true }
+
+func (s *sliceIterator) Find(key slice.Slice) {
+	s.pos = sort.Search(len(s.keys), func(i int) bool { return s.cmp.Compare(slice.Slice(s.keys[i]), key) >= 0 })
+}
+
+type mergeEntry struct {
+	key   string
+	value string
+}
+
+func collectForward(it Iterator) (res []mergeEntry) {
+	for it.SeekToFirst(); it.Success(); it.Next() {
+		res = append(res, mergeEntry{string(it.Key()), string(it.Value())})
+	}
+
+	return res
+}
+
+func collectBackward(it Iterator) (res []mergeEntry) {
+	for it.SeekToLast(); it.Success(); it.Prev() {
+		res = append([]mergeEntry{{string(it.Key()), string(it.Value())}}, res...)
+	}
+
+	return res
+}
+
+func assertEntries(t *testing.T, name string, got, want []mergeEntry) {
+	t.Helper()
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("%s => got %v, want %v", name, got, want)
+	}
+}
+
+func TestMergingIterator_Iterate(t *testing.T) {
+	for _, cmp := range []comparator.Comparator{comparator.Bytewise, comparator.Reverse(comparator.Bytewise)} {
+		t.Run(cmp.Name(), func(t *testing.T) {
+			it := NewMergingIterator(cmp,
+				newSliceIterator(cmp, "a", "1", "4", "7"),
+				newSliceIterator(cmp, "b"),
+				newSliceIterator(cmp, "c", "2", "4", "8", "9"),
+			)
+
+			want := []mergeEntry{{"1", "a"}, {"2", "c"}, {"4", "a"}, {"4", "c"}, {"7", "a"}, {"8", "c"}, {"9", "c"}}
+			if cmp != comparator.Bytewise {
+				want = []mergeEntry{{"9", "c"}, {"8", "c"}, {"7", "a"}, {"4", "a"}, {"4", "c"}, {"2", "c"}, {"1", "a"}}
+			}
+			assertEntries(t, "forward", collectForward(it), want)
+
+			// backward iteration mirrors forward iteration, including equal keys
+			assertEntries(t, "backward", collectBackward(it), want)
+		})
+	}
+}
+
+func TestMergingIterator_DirectionSwitch(t *testing.T) {
+	cmp := comparator.Bytewise
+	it := NewMergingIterator(cmp,
+		newSliceIterator(cmp, "a", "1", "3", "5"),
+		newSliceIterator(cmp, "b", "2", "4", "6"),
+	)
+
+	steps := []struct {
+		op   string
+		want string
+	}{
+		{"find 3", "3"}, {"next", "4"}, {"prev", "3"}, {"prev", "2"}, {"next", "3"},
+		{"next", "4"}, {"next", "5"}, {"prev", "4"}, {"find 0", "1"}, {"prev", ""},
+	}
+	for _, step := range steps {
+		switch step.op {
+		case "next":
+			it.Next()
+		case "prev":
+			it.Prev()
+		default:
+			it.Find(slice.Slice(step.op[len("find "):]))
+		}
+
+		got := ""
+		if it.Success() {
+			got = string(it.Key())
+		}
+		if got != step.want {
+			t.Fatalf("%s => got key %q, want %q", step.op, got, step.want)
+		}
+	}
+}
+
+func TestMergingIterator_DirectionSwitchWithEqualKeys(t *testing.T) {
+	cmp := comparator.Bytewise
+	it := NewMergingIterator(cmp,
+		newSliceIterator(cmp, "a", "1", "2"),
+		newSliceIterator(cmp, "b", "2", "3"),
+		newSliceIterator(cmp, "c", "2"),
+	)
+
+	// forward order: 1a 2a 2b 2c 3b
+	steps := []struct {
+		op   string
+		want string
+	}{
+		{"find 2", "2a"}, {"next", "2b"}, {"prev", "2a"}, {"next", "2b"}, {"next", "2c"},
+		{"prev", "2b"}, {"prev", "2a"}, {"prev", "1a"}, {"next", "2a"}, {"next", "2b"},
+		{"next", "2c"}, {"next", "3b"}, {"prev", "2c"},
+	}
+	for _, step := range steps {
+		switch step.op {
+		case "next":
+			it.Next()
+		case "prev":
+			it.Prev()
+		default:
+			it.Find(slice.Slice(step.op[len("find "):]))
+		}
+
+		if got := string(it.Key()) + string(it.Value()); got != step.want {
+			t.Fatalf("%s => got %q, want %q", step.op, got, step.want)
+		}
+	}
+}
+
+func TestMergingIterator_Random(t *testing.T) {
+	cmp := comparator.Bytewise
+	rnd := rand.New(rand.NewSource(1))
+
+	var (
+		children []Iterator
+		all      []string
+	)
+	for i := 0; i < 5; i++ {
+		var keys []string
+		for j := rnd.Intn(50); j > 0; j-- {
+			key := fmt.Sprintf("%04d_%d", rnd.Intn(1000), i)
+			keys = append(keys, key)
+			all = append(all, key)
+		}
+		children = append(children, newSliceIterator(cmp, fmt.Sprint(i), keys...))
+	}
+	sort.Strings(all)
+
+	it := NewMergingIterator(cmp, children...)
+	pos := 0
+	it.SeekToFirst()
+	// random walk, compare with the sorted keys
+	for step := 0; step < 2000 && len(all) > 0; step++ {
+		if rnd.Intn(2) == 0 && pos < len(all)-1 {
+			it.Next()
+			pos++
+		} else if pos > 0 {
+			it.Prev()
+			pos--
+		}
+
+		if !it.Success() || string(it.Key()) != all[pos] {
+			t.Fatalf("step %d => want key %s, iterator success = %v", step, all[pos], it.Success())
+		}
+	}
+}
+
+func TestMergingIterator_Err(t *testing.T) {
+	cmp := comparator.Bytewise
+	broken := newSliceIterator(cmp, "broken")
+	broken.err = errors.New("broken child")
+
+	it := NewMergingIterator(cmp, newSliceIterator(cmp, "ok", "1"), broken)
+	if !errors.Is(it.Err(), broken.err) {
+		t.Errorf("Err() = %v, want %v", it.Err(), broken.err)
+	}
+
+	empty := NewMergingIterator(cmp)
+	empty.SeekToFirst()
+	if empty.Success() || empty.Err() != nil {
+		t.Error("merging no iterators should be empty")
+	}
+}
+
+func TestMergingIterator_Release(t *testing.T) {
+	cmp := comparator.Bytewise
+	a, b := newSliceIterator(cmp, "a", "1"), newSliceIterator(cmp, "b", "2")
+
+	NewMergingIterator(cmp, a, b).Release()
+	if !a.released || !b.released {
+		t.Error("Release() should release all children")
+	}
+}
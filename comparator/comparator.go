@@ -0,0 +1,105 @@
+// Package comparator 定义 key 的排序规则, memtable、block 及 table 均通过它比较 key.
+package comparator
+
+import "github.com/goleveldb/goleveldb/slice"
+
+// Comparator 定义 key 之间的全序关系.
+type Comparator interface {
+	// Compare 比较 a, b, 返回 slice.CMPSmaller, slice.CMPSame 或 slice.CMPLarger.
+	Compare(a, b slice.Slice) int
+	// Name 返回比较器名称, 名称会写入 table 文件, 打开 table 时用于校验比较器是否一致.
+	Name() string
+	// FindShortestSeparator 返回一个满足 start <= key < limit 且尽可能短的 key, 用于缩短 index block 中的 key.
+	FindShortestSeparator(start, limit slice.Slice) slice.Slice
+	// FindShortSuccessor 返回一个大于等于 key 且尽可能短的 key.
+	FindShortSuccessor(key slice.Slice) slice.Slice
+}
+
+// Bytewise 按字节序比较 key, 是默认使用的比较器.
+var Bytewise Comparator = bytewiseComparator{}
+
+type bytewiseComparator struct{}
+
+// Compare 按字节序比较 a, b.
+func (bytewiseComparator) Compare(a, b slice.Slice) int {
+	return a.Compare(b)
+}
+
+// Name 返回比较器名称.
+func (bytewiseComparator) Name() string {
+	return "leveldb.BytewiseComparator"
+}
+
+// FindShortestSeparator 找到 start 与 limit 第一个不同的字节,
+// 若该字节加一后仍小于 limit 对应字节, 则截断返回.
+func (bytewiseComparator) FindShortestSeparator(start, limit slice.Slice) slice.Slice {
+	minLen := len(start)
+	if len(limit) < minLen {
+		minLen = len(limit)
+	}
+
+	diff := 0
+	for diff < minLen && start[diff] == limit[diff] {
+		diff++
+	}
+
+	// 一个 key 是另一个的前缀, 无法缩短.
+	if diff >= minLen {
+		return start
+	}
+
+	if diffByte := start[diff]; diffByte < 0xff && diffByte+1 < limit[diff] {
+		res := make(slice.Slice, diff+1)
+		copy(res, start[:diff+1])
+		res[diff]++
+
+		return res
+	}
+
+	return start
+}
+
+// FindShortSuccessor 将第一个不为 0xff 的字节加一后截断返回.
+func (bytewiseComparator) FindShortSuccessor(key slice.Slice) slice.Slice {
+	for i, b := range key {
+		if b != 0xff {
+			res := make(slice.Slice, i+1)
+			copy(res, key[:i+1])
+			res[i]++
+
+			return res
+		}
+	}
+
+	// key 全部由 0xff 组成, 无法缩短.
+	return key
+}
+
+// Reverse 返回与 cmp 排序结果相反的比较器.
+func Reverse(cmp Comparator) Comparator {
+	return reverseComparator{cmp: cmp}
+}
+
+type reverseComparator struct {
+	cmp Comparator
+}
+
+// Compare 返回 cmp 比较结果的相反值.
+func (c reverseComparator) Compare(a, b slice.Slice) int {
+	return c.cmp.Compare(b, a)
+}
+
+// Name 返回比较器名称.
+func (c reverseComparator) Name() string {
+	return "goleveldb.Reverse(" + c.cmp.Name() + ")"
+}
+
+// FindShortestSeparator 逆序下不缩短 key.
+func (reverseComparator) FindShortestSeparator(start, _ slice.Slice) slice.Slice {
+	return start
+}
+
+// FindShortSuccessor 逆序下不缩短 key.
+func (reverseComparator) FindShortSuccessor(key slice.Slice) slice.Slice {
+	return key
+}
@@ -0,0 +1,62 @@
+package comparator
+
+import (
+	"testing"
+
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+func TestBytewise_FindShortestSeparator(t *testing.T) {
+	tests := []struct {
+		name  string
+		start slice.Slice
+		limit slice.Slice
+		want  slice.Slice
+	}{
+		{"shorten", slice.Slice("abcd"), slice.Slice("abzz"), slice.Slice("abd")},
+		{"adjacent byte", slice.Slice("abc"), slice.Slice("abd"), slice.Slice("abc")},
+		{"prefix", slice.Slice("ab"), slice.Slice("abc"), slice.Slice("ab")},
+		{"0xff", slice.Slice{'a', 0xff, 'c'}, slice.Slice("b"), slice.Slice{'a', 0xff, 'c'}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Bytewise.FindShortestSeparator(tt.start, tt.limit)
+			if got.Compare(tt.want) != slice.CMPSame {
+				t.Errorf("FindShortestSeparator() = %q, want %q", got, tt.want)
+			}
+			if got.Compare(tt.start) == slice.CMPSmaller || got.Compare(tt.limit) != slice.CMPSmaller {
+				t.Errorf("FindShortestSeparator() = %q, not in [%q, %q)", got, tt.start, tt.limit)
+			}
+		})
+	}
+}
+
+func TestBytewise_FindShortSuccessor(t *testing.T) {
+	tests := []struct {
+		name string
+		key  slice.Slice
+		want slice.Slice
+	}{
+		{"normal", slice.Slice("abc"), slice.Slice("b")},
+		{"leading 0xff", slice.Slice{0xff, 'a'}, slice.Slice{0xff, 'b'}},
+		{"all 0xff", slice.Slice{0xff, 0xff}, slice.Slice{0xff, 0xff}},
+		{"empty", slice.Slice{}, slice.Slice{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Bytewise.FindShortSuccessor(tt.key); got.Compare(tt.want) != slice.CMPSame {
+				t.Errorf("FindShortSuccessor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReverse(t *testing.T) {
+	cmp := Reverse(Bytewise)
+	if got := cmp.Compare(slice.Slice("a"), slice.Slice("b")); got != slice.CMPLarger {
+		t.Errorf("Reverse().Compare() = %d, want %d", got, slice.CMPLarger)
+	}
+	if cmp.Name() == Bytewise.Name() {
+		t.Error("Reverse().Name() should differ from the wrapped comparator")
+	}
+}
@@ -0,0 +1,101 @@
+// Package compress implements the block compression types supported by tables.
+package compress
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+// Type is the compression type of a block, it is stored in the trailer of each block.
+type Type byte
+
+const (
+	// NoCompression stores blocks as is.
+	NoCompression Type = 0
+	// SnappyCompression compresses blocks in the snappy block format, compatible with LevelDB.
+	SnappyCompression Type = 1
+	// FlateCompression compresses blocks as raw deflate streams (RFC 1951). It is not a LevelDB
+	// compression type, its id is kept outside the range LevelDB uses (2 is zstd there), so
+	// LevelDB reports such blocks as unsupported instead of misreading them.
+	FlateCompression Type = 0x80
+)
+
+var (
+	ErrUnknownType = errors.New("unknown compression type")
+	ErrCorrupted   = errors.New("corrupted compressed data")
+)
+
+func (t Type) String() string {
+	switch t {
+	case NoCompression:
+		return "none"
+	case SnappyCompression:
+		return "snappy"
+	case FlateCompression:
+		return "flate"
+	default:
+		return fmt.Sprintf("unknown(%d)", byte(t))
+	}
+}
+
+// Compress: compress src with the compression type t
+func Compress(t Type, src slice.Slice) (slice.Slice, error) {
+	switch t {
+	case NoCompression:
+		return src, nil
+	case SnappyCompression:
+		return snappyEncode(src), nil
+	case FlateCompression:
+		return flateEncode(src)
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnknownType, byte(t))
+	}
+}
+
+// Decompress: decompress src which is compressed with the compression type t
+func Decompress(t Type, src slice.Slice) (slice.Slice, error) {
+	switch t {
+	case NoCompression:
+		return src, nil
+	case SnappyCompression:
+		return snappyDecode(src)
+	case FlateCompression:
+		return flateDecode(src)
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnknownType, byte(t))
+	}
+}
+
+func flateEncode(src slice.Slice) (slice.Slice, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func flateDecode(src slice.Slice) (slice.Slice, error) {
+	r := flate.NewReader(bytes.NewReader(src))
+	defer r.Close()
+
+	res, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,138 @@
+package compress
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+func testInputs() map[string][]byte {
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	return map[string][]byte{
+		"empty":      {},
+		"short":      []byte("foobar"),
+		"repeated":   bytes.Repeat([]byte("a"), 100000),
+		"text":       []byte(strings.Repeat("the quick brown fox jumps over the lazy dog. ", 3000)),
+		"random":     random,
+		"mixed":      append(append([]byte{}, random[:70000]...), bytes.Repeat([]byte("wdnmd"), 20000)...),
+		"block_size": bytes.Repeat([]byte("0123456789abcdef"), snappyMaxBlockSize/16),
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, cType := range []Type{NoCompression, SnappyCompression, FlateCompression} {
+		for name, input := range testInputs() {
+			t.Run(cType.String()+"_"+name, func(t *testing.T) {
+				compressed, err := Compress(cType, input)
+				if err != nil {
+					t.Fatalf("compress failed: %v", err)
+				}
+
+				got, err := Decompress(cType, compressed)
+				if err != nil {
+					t.Fatalf("decompress failed: %v", err)
+				}
+				if !bytes.Equal(got, input) {
+					t.Errorf("round trip mismatch, want %d bytes, got %d bytes", len(input), len(got))
+				}
+			})
+		}
+	}
+}
+
+func TestCompressRatio(t *testing.T) {
+	input := testInputs()["text"]
+	for _, cType := range []Type{SnappyCompression, FlateCompression} {
+		compressed, err := Compress(cType, input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(compressed) > len(input)/4 {
+			t.Errorf("%s: compressed %d bytes into %d bytes", cType, len(input), len(compressed))
+		}
+	}
+}
+
+func TestSnappyDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "empty", input: "\x00", want: ""},
+		{name: "literal", input: "\x03\x08abc", want: "abc"},
+		// literal "abc", then copy of length 8 at offset 3 with 1-byte offset
+		{name: "copy1", input: "\x0b\x08abc\x11\x03", want: "abcabcabcab"},
+		// literal "ab", then copy of length 4 at offset 2 with 2-byte offset
+		{name: "copy2", input: "\x06\x04ab\x0e\x02\x00", want: "ababab"},
+		{name: "copy4", input: "\x06\x04ab\x0f\x02\x00\x00\x00", want: "ababab"},
+		// literal "a", then two copies of length 64 at offset 1, the largest expansion of the input
+		{name: "max_expansion", input: "\x81\x01\x00a\xfe\x01\x00\xfe\x01\x00", want: strings.Repeat("a", 129)},
+		{name: "length_beyond_input", input: "\x82\x01\x00a\xfe\x01\x00\xfe\x01\x00", wantErr: true},
+		{name: "bad_offset", input: "\x06\x04ab\x0e\x03\x00", wantErr: true},
+		{name: "zero_offset", input: "\x06\x04ab\x0e\x00\x00", wantErr: true},
+		{name: "short_output", input: "\x04\x08abc", wantErr: true},
+		{name: "long_output", input: "\x02\x08abc", wantErr: true},
+		{name: "truncated_literal", input: "\x03\x08ab", wantErr: true},
+		{name: "truncated_copy", input: "\x06\x04ab\x0e\x02", wantErr: true},
+		{name: "no_length", input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decompress(SnappyCompression, slice.Slice(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("get err = %v, but wantErr = %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrCorrupted) {
+				t.Errorf("want ErrCorrupted, get %v", err)
+			}
+			if err == nil && string(got) != tt.want {
+				t.Errorf("want %q, get %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSnappyDecodeHugeLength(t *testing.T) {
+	// the length in the header is checked against the input before the output is allocated
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := Decompress(SnappyCompression, slice.Slice("\xff\xff\xff\xff\x0f\x00a")); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("want ErrCorrupted, get %v", err)
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("allocated %d bytes for a 7-byte input", allocated)
+	}
+}
+
+func TestSnappyEncode(t *testing.T) {
+	// short inputs are emitted as a single literal
+	if got := snappyEncode([]byte("abc")); string(got) != "\x03\x08abc" {
+		t.Errorf("want %q, get %q", "\x03\x08abc", got)
+	}
+	if got := snappyEncode(nil); string(got) != "\x00" {
+		t.Errorf("want %q, get %q", "\x00", got)
+	}
+}
+
+func TestUnknownType(t *testing.T) {
+	// 2 is zstd in LevelDB, it must not be decoded as another type.
+	for _, cType := range []Type{Type(2), Type(9)} {
+		if _, err := Compress(cType, slice.Slice("foobar")); !errors.Is(err, ErrUnknownType) {
+			t.Errorf("Compress(%v): want ErrUnknownType, get %v", cType, err)
+		}
+		if _, err := Decompress(cType, slice.Slice("foobar")); !errors.Is(err, ErrUnknownType) {
+			t.Errorf("Decompress(%v): want ErrUnknownType, get %v", cType, err)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package compress
+
+import (
+	"encoding/binary"
+)
+
+// The snappy block format: the uncompressed length as an uvarint, followed by a sequence of elements.
+// The low 2 bits of the first byte of an element are its tag:
+//   - 00 literal: the length is stored in the upper 6 bits, or in the following 1-4 bytes if the upper 6 bits are 60-63
+//   - 01 copy with 1-byte offset: length 4-11 in 3 bits, offset 11 bits
+//   - 10 copy with 2-byte offset: length 1-64 in the upper 6 bits, little endian 16-bit offset
+//   - 11 copy with 4-byte offset: length 1-64 in the upper 6 bits, little endian 32-bit offset
+//
+// See https://github.com/google/snappy/blob/main/format_description.txt
+const (
+	tagLiteral = 0x00
+	tagCopy1   = 0x01
+	tagCopy2   = 0x02
+	tagCopy4   = 0x03
+
+	// the input is encoded in independent blocks, so that offsets always fit in 16 bits
+	snappyMaxBlockSize = 65536
+	// inputs shorter than this are emitted as a single literal
+	snappyMinNonLiteralBlockSize = 1 + 1 + snappyInputMargin
+	// bytes kept at the end of a block so that 8-byte loads never go out of range
+	snappyInputMargin = 16 - 1
+
+	snappyTableBits = 14
+	snappyTableSize = 1 << snappyTableBits
+)
+
+func snappyEncode(src []byte) []byte {
+	dst := make([]byte, binary.MaxVarintLen64, snappyMaxEncodedLen(len(src)))
+	dst = dst[:binary.PutUvarint(dst, uint64(len(src)))]
+
+	for len(src) > 0 {
+		block := src
+		if len(block) > snappyMaxBlockSize {
+			block = block[:snappyMaxBlockSize]
+		}
+		src = src[len(block):]
+
+		if len(block) < snappyMinNonLiteralBlockSize {
+			dst = emitLiteral(dst, block)
+		} else {
+			dst = encodeBlock(dst, block)
+		}
+	}
+
+	return dst
+}
+
+// snappyMaxEncodedLen: the worst case size of the encoded data
+func snappyMaxEncodedLen(srcLen int) int {
+	return 32 + srcLen + srcLen/6
+}
+
+// snappyMaxDecodedLen: the largest output srcLen bytes of elements can decode to,
+// a 3-byte copy with 2-byte offset produces at most 64 bytes, which is the largest expansion of any element
+func snappyMaxDecodedLen(srcLen int) uint64 {
+	return uint64(srcLen) * 64 / 3
+}
+
+func snappyHash(u uint32) uint32 {
+	return (u * 0x1e35a7bd) >> (32 - snappyTableBits)
+}
+
+func load32(b []byte, i int) uint32 {
+	return binary.LittleEndian.Uint32(b[i:])
+}
+
+func load64(b []byte, i int) uint64 {
+	return binary.LittleEndian.Uint64(b[i:])
+}
+
+// encodeBlock: encode a block of at least snappyMinNonLiteralBlockSize bytes, it greedily looks for 4-byte matches
+// through a hash table, and skips faster over incompressible data
+func encodeBlock(dst, src []byte) []byte {
+	var table [snappyTableSize]int32
+
+	sLimit := len(src) - snappyInputMargin
+	nextEmit := 0
+	s := 1
+	nextHash := snappyHash(load32(src, s))
+
+	for {
+		skip := 32
+		nextS := s
+		candidate := 0
+		for {
+			s = nextS
+			bytesBetweenHashLookups := skip >> 5
+			nextS = s + bytesBetweenHashLookups
+			skip += bytesBetweenHashLookups
+			if nextS > sLimit {
+				return emitRemainder(dst, src, nextEmit)
+			}
+
+			candidate = int(table[nextHash])
+			table[nextHash] = int32(s)
+			nextHash = snappyHash(load32(src, nextS))
+			if load32(src, s) == load32(src, candidate) {
+				break
+			}
+		}
+
+		// src[nextEmit:s] is not matched, emit it as a literal
+		dst = emitLiteral(dst, src[nextEmit:s])
+
+		// emit copies as long as the bytes right after a match match again
+		for {
+			base := s
+			s += 4
+			for i := candidate + 4; s < len(src) && src[i] == src[s]; i, s = i+1, s+1 {
+			}
+
+			dst = emitCopy(dst, base-candidate, s-base)
+			nextEmit = s
+			if s >= sLimit {
+				return emitRemainder(dst, src, nextEmit)
+			}
+
+			x := load64(src, s-1)
+			table[snappyHash(uint32(x))] = int32(s - 1)
+			currHash := snappyHash(uint32(x >> 8))
+			candidate = int(table[currHash])
+			table[currHash] = int32(s)
+			if uint32(x>>8) != load32(src, candidate) {
+				nextHash = snappyHash(uint32(x >> 16))
+				s++
+				break
+			}
+		}
+	}
+}
+
+func emitRemainder(dst, src []byte, nextEmit int) []byte {
+	if nextEmit < len(src) {
+		dst = emitLiteral(dst, src[nextEmit:])
+	}
+
+	return dst
+}
+
+func emitLiteral(dst, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+
+	n := uint32(len(lit) - 1)
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2|tagLiteral)
+	case n < 1<<8:
+		dst = append(dst, 60<<2|tagLiteral, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2|tagLiteral, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2|tagLiteral, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2|tagLiteral, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+
+	return append(dst, lit...)
+}
+
+// emitCopy: emit copies of at most 64 bytes, offset is always less than snappyMaxBlockSize
+func emitCopy(dst []byte, offset, length int) []byte {
+	for length >= 68 {
+		dst = append(dst, 63<<2|tagCopy2, byte(offset), byte(offset>>8))
+		length -= 64
+	}
+	// keep the last copy at least 4 bytes long, so that it may use the 1-byte offset form
+	if length > 64 {
+		dst = append(dst, 59<<2|tagCopy2, byte(offset), byte(offset>>8))
+		length -= 60
+	}
+
+	if length >= 12 || offset >= 2048 {
+		return append(dst, byte(length-1)<<2|tagCopy2, byte(offset), byte(offset>>8))
+	}
+
+	return append(dst, byte(offset>>8)<<5|byte(length-4)<<2|tagCopy1, byte(offset))
+}
+
+func snappyDecode(src []byte) ([]byte, error) {
+	dLen, n := binary.Uvarint(src)
+	if n <= 0 || dLen > 0xffffffff {
+		return nil, ErrCorrupted
+	}
+	// reject lengths the input can't produce before allocating dst, a corrupted header may claim up to 4GB
+	if dLen > snappyMaxDecodedLen(len(src)-n) {
+		return nil, ErrCorrupted
+	}
+
+	dst := make([]byte, dLen)
+	d, s := 0, n
+	for s < len(src) {
+		var length, offset int
+		switch src[s] & 0x03 {
+		case tagLiteral:
+			x := uint32(src[s] >> 2)
+			switch {
+			case x < 60:
+				s++
+			case x == 60:
+				s += 2
+				if s > len(src) {
+					return nil, ErrCorrupted
+				}
+				x = uint32(src[s-1])
+			case x == 61:
+				s += 3
+				if s > len(src) {
+					return nil, ErrCorrupted
+				}
+				x = uint32(src[s-2]) | uint32(src[s-1])<<8
+			case x == 62:
+				s += 4
+				if s > len(src) {
+					return nil, ErrCorrupted
+				}
+				x = uint32(src[s-3]) | uint32(src[s-2])<<8 | uint32(src[s-1])<<16
+			default:
+				s += 5
+				if s > len(src) {
+					return nil, ErrCorrupted
+				}
+				x = binary.LittleEndian.Uint32(src[s-4:])
+			}
+
+			length = int(x) + 1
+			if length <= 0 || length > len(dst)-d || length > len(src)-s {
+				return nil, ErrCorrupted
+			}
+			copy(dst[d:], src[s:s+length])
+			d += length
+			s += length
+
+			continue
+
+		case tagCopy1:
+			s += 2
+			if s > len(src) {
+				return nil, ErrCorrupted
+			}
+			length = 4 + int(src[s-2]>>2&0x07)
+			offset = int(uint32(src[s-2]&0xe0)<<3 | uint32(src[s-1]))
+
+		case tagCopy2:
+			s += 3
+			if s > len(src) {
+				return nil, ErrCorrupted
+			}
+			length = 1 + int(src[s-3]>>2)
+			offset = int(binary.LittleEndian.Uint16(src[s-2:]))
+
+		case tagCopy4:
+			s += 5
+			if s > len(src) {
+				return nil, ErrCorrupted
+			}
+			length = 1 + int(src[s-5]>>2)
+			offset = int(binary.LittleEndian.Uint32(src[s-4:]))
+		}
+
+		if offset <= 0 || d < offset || length > len(dst)-d {
+			return nil, ErrCorrupted
+		}
+		// the source and destination may overlap, copy byte by byte
+		for i := 0; i < length; i++ {
+			dst[d+i] = dst[d-offset+i]
+		}
+		d += length
+	}
+
+	if d != len(dst) {
+		return nil, ErrCorrupted
+	}
+
+	return dst, nil
+}
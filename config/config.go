@@ -2,5 +2,6 @@ package config
 
 const (
 	BLOCK_RESTART_INTERVAL = 16       // 重新进行前缀压缩的最大key间隔，参考leveldb设置为16
+	INDEX_RESTART_INTERVAL = 1        // index block 的前缀压缩间隔，与leveldb相同，每个key都是重启点
 	BLOCK_MAX_SIZE         = 4 * 1024 // 最大的块大小，超过该限制后应该及时刷到磁盘上
 )
@@ -0,0 +1,32 @@
+// Package crc32c 实现 LevelDB 使用的 CRC-32C(Castagnoli) 校验和及其掩码.
+package crc32c
+
+import "hash/crc32"
+
+// maskDelta 计算掩码时加上的常量.
+const maskDelta = 0xa282ead8
+
+var table = crc32.MakeTable(crc32.Castagnoli)
+
+// Value 返回 data 的 CRC-32C.
+func Value(data []byte) uint32 {
+	return crc32.Checksum(data, table)
+}
+
+// Extend 返回 crc 所对应的数据之后拼接 data 的 CRC-32C.
+func Extend(crc uint32, data []byte) uint32 {
+	return crc32.Update(crc, table, data)
+}
+
+// Mask 返回 crc 的掩码.
+// 对包含 CRC 的数据再计算 CRC 容易出现问题, 因此存储的 CRC 都经过掩码.
+func Mask(crc uint32) uint32 {
+	return (crc>>15 | crc<<17) + maskDelta
+}
+
+// Unmask 返回 Mask 之前的 CRC.
+func Unmask(masked uint32) uint32 {
+	rot := masked - maskDelta
+
+	return rot>>17 | rot<<15
+}
@@ -0,0 +1,50 @@
+package crc32c
+
+import "testing"
+
+func TestValue(t *testing.T) {
+	// 与 LevelDB crc32c_test 中的结果相同.
+	ascending, descending := make([]byte, 32), make([]byte, 32)
+	for i := range ascending {
+		ascending[i] = byte(i)
+		descending[i] = byte(31 - i)
+	}
+	ones := make([]byte, 32)
+	for i := range ones {
+		ones[i] = 0xff
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want uint32
+	}{
+		{"zeros", make([]byte, 32), 0x8a9136aa},
+		{"ones", ones, 0x62a8ab43},
+		{"ascending", ascending, 0x46dd794e},
+		{"descending", descending, 0x113fdb5c},
+		{"check", []byte("123456789"), 0xe3069283},
+	}
+	for _, tt := range tests {
+		if got := Value(tt.data); got != tt.want {
+			t.Errorf("Value(%s) = %#x, want %#x", tt.name, got, tt.want)
+		}
+	}
+
+	if Value([]byte("a")) == Value([]byte("foo")) {
+		t.Error("different data should have different crc")
+	}
+	if Value([]byte("hello world")) != Extend(Value([]byte("hello ")), []byte("world")) {
+		t.Error("Extend() should be equal to Value() of the concatenated data")
+	}
+}
+
+func TestMask(t *testing.T) {
+	crc := Value([]byte("foo"))
+	if Mask(crc) == crc || Mask(Mask(crc)) == crc {
+		t.Error("Mask() should change the crc")
+	}
+	if Unmask(Mask(crc)) != crc || Unmask(Unmask(Mask(Mask(crc)))) != crc {
+		t.Error("Unmask() should revert Mask()")
+	}
+}
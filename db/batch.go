@@ -0,0 +1,207 @@
+package db
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/goleveldb/goleveldb/ikey"
+	"github.com/goleveldb/goleveldb/memtable"
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+// batchHeaderLen WriteBatch 头部长度: sequenceNumber (fixed64) + count (fixed32).
+const batchHeaderLen = 8 + 4
+
+// ErrBatchCorrupted WriteBatch 的内容无法解析.
+var ErrBatchCorrupted = errors.New("write batch is corrupted")
+
+// Handler 用于遍历 WriteBatch 中的操作, 返回 error 时遍历终止.
+type Handler interface {
+	// Put 处理一次写入操作.
+	Put(key, value slice.Slice) error
+	// Delete 处理一次删除操作.
+	Delete(key slice.Slice) error
+}
+
+// WriteBatch 记录一组写操作, 这组操作会被原子地写入 DB. 零值可以直接使用.
+// 序列化格式如下, 整个 WriteBatch 作为一条日志记录写入日志:
+// - sequenceNumber (fixed64), 第一条操作的序列号, 之后的操作序列号依次递增.
+// - count (fixed32), 操作个数.
+// - count 条操作, 每条操作为:
+//   - valueType (1 byte).
+//   - key length (uvarint) & key data.
+//   - value length (uvarint) & value data, 仅 ikey.TypeValue 类型存在.
+type WriteBatch struct {
+	rep []byte
+}
+
+// NewWriteBatch 创建空的 WriteBatch.
+func NewWriteBatch() *WriteBatch {
+	b := &WriteBatch{}
+	b.Reset()
+
+	return b
+}
+
+// Put 向 WriteBatch 中添加写入 key, value 的操作.
+func (b *WriteBatch) Put(key, value slice.Slice) {
+	b.ensureHeader()
+	b.setCount(b.Len() + 1)
+	b.rep = append(b.rep, byte(ikey.TypeValue))
+	b.rep = appendLengthPrefixed(b.rep, key)
+	b.rep = appendLengthPrefixed(b.rep, value)
+}
+
+// Delete 向 WriteBatch 中添加删除 key 的操作.
+func (b *WriteBatch) Delete(key slice.Slice) {
+	b.ensureHeader()
+	b.setCount(b.Len() + 1)
+	b.rep = append(b.rep, byte(ikey.TypeDeletion))
+	b.rep = appendLengthPrefixed(b.rep, key)
+}
+
+// Len 返回 WriteBatch 中的操作个数.
+func (b *WriteBatch) Len() int {
+	if len(b.rep) < batchHeaderLen {
+		return 0
+	}
+
+	return int(binary.LittleEndian.Uint32(b.rep[8:]))
+}
+
+// Reset 清空 WriteBatch 中的所有操作.
+func (b *WriteBatch) Reset() {
+	b.rep = make([]byte, batchHeaderLen)
+}
+
+// Iterate 按照添加顺序, 将 WriteBatch 中的操作依次交给 h 处理.
+func (b *WriteBatch) Iterate(h Handler) error {
+	if len(b.rep) == 0 {
+		return nil
+	}
+	if len(b.rep) < batchHeaderLen {
+		return ErrBatchCorrupted
+	}
+
+	data, found := b.rep[batchHeaderLen:], 0
+	for len(data) > 0 {
+		valueType := ikey.ValueType(data[0])
+		data = data[1:]
+
+		key, rest, ok := readLengthPrefixed(data)
+		if !ok {
+			return ErrBatchCorrupted
+		}
+		data = rest
+
+		var err error
+		switch valueType {
+		case ikey.TypeValue:
+			var value slice.Slice
+			if value, data, ok = readLengthPrefixed(data); !ok {
+				return ErrBatchCorrupted
+			}
+			err = h.Put(key, value)
+		case ikey.TypeDeletion:
+			err = h.Delete(key)
+		default:
+			return ErrBatchCorrupted
+		}
+
+		if err != nil {
+			return err
+		}
+		found++
+	}
+
+	if found != b.Len() {
+		return ErrBatchCorrupted
+	}
+
+	return nil
+}
+
+// ensureHeader 为零值 WriteBatch 填充头部.
+func (b *WriteBatch) ensureHeader() {
+	if len(b.rep) < batchHeaderLen {
+		b.Reset()
+	}
+}
+
+func (b *WriteBatch) setCount(count int) {
+	binary.LittleEndian.PutUint32(b.rep[8:], uint32(count))
+}
+
+// sequence 返回第一条操作的序列号.
+func (b *WriteBatch) sequence() uint64 {
+	return binary.LittleEndian.Uint64(b.rep)
+}
+
+func (b *WriteBatch) setSequence(sequence uint64) {
+	b.ensureHeader()
+	binary.LittleEndian.PutUint64(b.rep, sequence)
+}
+
+// contents 返回 WriteBatch 序列化后的内容.
+func (b *WriteBatch) contents() slice.Slice {
+	b.ensureHeader()
+
+	return b.rep
+}
+
+// setContents 使用序列化的内容重建 WriteBatch.
+func (b *WriteBatch) setContents(contents slice.Slice) error {
+	if len(contents) < batchHeaderLen {
+		return ErrBatchCorrupted
+	}
+
+	b.rep = append([]byte(nil), contents...)
+
+	return nil
+}
+
+// insertInto 将 WriteBatch 中的操作以连续的序列号写入内存表.
+func (b *WriteBatch) insertInto(mem *memtable.Memtable) error {
+	return b.Iterate(&memtableInserter{sequence: b.sequence(), mem: mem})
+}
+
+// memtableInserter 实现 Handler, 将操作写入内存表.
+type memtableInserter struct {
+	sequence uint64
+	mem      *memtable.Memtable
+}
+
+func (h *memtableInserter) Put(key, value slice.Slice) error {
+	err := h.mem.Insert(h.sequence, ikey.TypeValue, key, value)
+	h.sequence++
+
+	return err
+}
+
+func (h *memtableInserter) Delete(key slice.Slice) error {
+	err := h.mem.Delete(h.sequence, key)
+	h.sequence++
+
+	return err
+}
+
+// appendLengthPrefixed 向 dst 追加 uvarint 编码的 data 长度及 data.
+func appendLengthPrefixed(dst []byte, data slice.Slice) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(len(data)))
+	dst = append(dst, buf[:n]...)
+
+	return append(dst, data...)
+}
+
+// readLengthPrefixed 从 data 中读取 uvarint 长度前缀的数据, 返回数据及剩余部分.
+func readLengthPrefixed(data []byte) (res, rest slice.Slice, ok bool) {
+	length, n := binary.Uvarint(data)
+	if n <= 0 || uint64(len(data)-n) < length {
+		return nil, nil, false
+	}
+
+	end := n + int(length)
+
+	return data[n:end], data[end:], true
+}
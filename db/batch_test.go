@@ -0,0 +1,136 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/goleveldb/goleveldb/comparator"
+	"github.com/goleveldb/goleveldb/ikey"
+	"github.com/goleveldb/goleveldb/memtable"
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+// recordHandler 将遍历到的操作记录为字符串.
+type recordHandler struct {
+	ops []string
+}
+
+func (h *recordHandler) Put(key, value slice.Slice) error {
+	h.ops = append(h.ops, fmt.Sprintf("Put(%s, %s)", key, value))
+	return nil
+}
+
+func (h *recordHandler) Delete(key slice.Slice) error {
+	h.ops = append(h.ops, fmt.Sprintf("Delete(%s)", key))
+	return nil
+}
+
+func TestWriteBatch_Iterate(t *testing.T) {
+	var batch WriteBatch
+	if err := batch.Iterate(&recordHandler{}); err != nil {
+		t.Errorf("iterate zero value batch => unexpected err %v", err)
+	}
+
+	batch.Put(slice.Slice("foo"), slice.Slice("bar"))
+	batch.Delete(slice.Slice("box"))
+	batch.Put(slice.Slice("baz"), slice.Slice("boo"))
+	batch.setSequence(100)
+
+	if batch.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", batch.Len())
+	}
+	if batch.sequence() != 100 {
+		t.Errorf("sequence() = %d, want 100", batch.sequence())
+	}
+
+	h := &recordHandler{}
+	if err := batch.Iterate(h); err != nil {
+		t.Fatal(err)
+	}
+	want := "Put(foo, bar),Delete(box),Put(baz, boo)"
+	if got := strings.Join(h.ops, ","); got != want {
+		t.Errorf("Iterate() => get %s, want %s", got, want)
+	}
+
+	batch.Reset()
+	if batch.Len() != 0 {
+		t.Errorf("Len() after Reset() = %d, want 0", batch.Len())
+	}
+}
+
+func TestWriteBatch_Corrupted(t *testing.T) {
+	batch := NewWriteBatch()
+	batch.Put(slice.Slice("foo"), slice.Slice("bar"))
+	contents := batch.contents()
+
+	tests := []struct {
+		name     string
+		contents slice.Slice
+	}{
+		{"truncated value", contents[:len(contents)-1]},
+		{"count mismatch", append(append(slice.Slice{}, contents[:8]...), 2, 0, 0, 0, byte(ikey.TypeDeletion), 0)},
+		{"unknown type", append(append(slice.Slice{}, contents[:batchHeaderLen]...), 0xff, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b WriteBatch
+			if err := b.setContents(tt.contents); err != nil {
+				t.Fatal(err)
+			}
+			if err := b.Iterate(&recordHandler{}); !errors.Is(err, ErrBatchCorrupted) {
+				t.Errorf("Iterate() => want err = %v, get err = %v", ErrBatchCorrupted, err)
+			}
+		})
+	}
+
+	var b WriteBatch
+	if err := b.setContents(contents[:batchHeaderLen-1]); !errors.Is(err, ErrBatchCorrupted) {
+		t.Errorf("setContents() => want err = %v, get err = %v", ErrBatchCorrupted, err)
+	}
+}
+
+func TestWriteBatch_InsertInto(t *testing.T) {
+	batch := NewWriteBatch()
+	batch.Put(slice.Slice("foo"), slice.Slice("bar"))
+	batch.Put(slice.Slice("foo"), slice.Slice("baz"))
+	batch.Put(slice.Slice("box"), slice.Slice("bar"))
+	batch.Delete(slice.Slice("box"))
+	batch.setSequence(1)
+
+	mem := memtable.New(comparator.Bytewise)
+	if err := batch.insertInto(mem); err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一个 batch 中, 后面的操作拥有更大的序列号.
+	if got, err := mem.Get(slice.Slice("foo")); err != nil || string(got) != "baz" {
+		t.Errorf("Get(foo) => get (%s, %v), want (baz, nil)", got, err)
+	}
+	if _, err := mem.Get(slice.Slice("box")); !errors.Is(err, memtable.ErrNotFound) {
+		t.Errorf("Get(box) => want err = %v, get err = %v", memtable.ErrNotFound, err)
+	}
+}
+
+func TestDB_Write(t *testing.T) {
+	db := openTestDB(t, t.TempDir())
+	defer db.Close()
+
+	batch := NewWriteBatch()
+	batch.Put(slice.Slice("foo"), slice.Slice("bar"))
+	batch.Put(slice.Slice("box"), slice.Slice("baz"))
+	batch.Delete(slice.Slice("foo"))
+	if err := db.Write(batch, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if db.versions.LastSequence() != 3 {
+		t.Errorf("lastSequence = %d, want 3", db.versions.LastSequence())
+	}
+
+	runDBOperations(t, db, []*dbOperation{
+		{name: "get deleted in batch", method: methodGet, key: "foo", wantErr: ErrNotFound},
+		{name: "get written in batch", method: methodGet, key: "box", value: "baz"},
+	})
+}
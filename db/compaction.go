@@ -0,0 +1,289 @@
+package db
+
+import (
+	"os"
+	"sync/atomic"
+
+	"github.com/goleveldb/goleveldb/common"
+	"github.com/goleveldb/goleveldb/file"
+	"github.com/goleveldb/goleveldb/ikey"
+	"github.com/goleveldb/goleveldb/slice"
+	"github.com/goleveldb/goleveldb/table"
+	"github.com/goleveldb/goleveldb/version"
+)
+
+// maybeScheduleCompaction 存在只读内存表或需要压缩, 且没有正在进行的后台工作时, 启动后台工作.
+// 调用时需持有 db.mu.
+func (db *DB) maybeScheduleCompaction() {
+	switch {
+	case db.bgScheduled, db.closed, db.bgErr != nil:
+		return
+	case db.imm == nil && !db.versions.NeedsCompaction():
+		return
+	}
+
+	db.bgScheduled = true
+	go db.backgroundCall()
+}
+
+// backgroundCall 完成一项后台工作: 优先将只读内存表写入 table, 否则进行一次压缩.
+// 完成后若仍有工作则继续调度, 并通知等待者.
+func (db *DB) backgroundCall() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var err error
+	if db.imm != nil {
+		err = db.flushMemtable()
+	} else {
+		err = db.backgroundCompaction()
+	}
+	if err != nil && db.bgErr == nil {
+		db.bgErr = err
+	}
+
+	db.bgScheduled = false
+	db.maybeScheduleCompaction()
+	db.bgCond.Broadcast()
+}
+
+// backgroundCompaction 选择需要压缩的文件进行压缩, 并将结果记录到 MANIFEST 中. 调用时需持有 db.mu.
+func (db *DB) backgroundCompaction() error {
+	c := db.versions.PickCompaction()
+	if c == nil {
+		return nil
+	}
+
+	edit := c.Edit()
+	if c.IsDeletionCompaction() {
+		c.AddInputDeletions(edit)
+		if err := db.versions.LogAndApply(edit); err != nil {
+			return err
+		}
+		db.deleteObsoleteFiles()
+
+		return nil
+	}
+	if c.IsTrivialMove() {
+		f := c.Inputs(0)[0]
+		edit.DeleteFile(c.InputLevel(0), f.Number)
+		edit.AddFile(c.OutputLevel(), f)
+
+		return db.versions.LogAndApply(edit)
+	}
+
+	state := &compactionState{c: c, smallestSnapshot: db.smallestSnapshot()}
+	defer func() {
+		for _, out := range state.outputs {
+			delete(db.pendingOutputs, out.Number)
+		}
+	}()
+
+	db.mu.Unlock()
+	err := db.doCompactionWork(state)
+	db.mu.Lock()
+	if err != nil {
+		// 未完成的输出文件不在任何 Version 中, 之后由 deleteObsoleteFiles 删除.
+		return err
+	}
+
+	c.AddInputDeletions(edit)
+	for _, out := range state.outputs {
+		edit.AddFile(c.OutputLevel(), out)
+	}
+	if err := db.versions.LogAndApply(edit); err != nil {
+		return err
+	}
+	db.deleteObsoleteFiles()
+
+	return nil
+}
+
+// compactionState 记录一次压缩的输出.
+type compactionState struct {
+	c       *version.Compaction
+	outputs []*version.FileMetaData
+	// 压缩开始时仍可能被读取的最小序列号, 见 DB.smallestSnapshot.
+	smallestSnapshot uint64
+
+	// 正在写入的输出文件, 没有时为 nil.
+	fileWriter  file.Writer
+	tableWriter table.Writer
+	numEntries  int         // 写入当前输出文件的条目数.
+	lastKey     slice.Slice // 写入当前输出文件的最后一个 key.
+}
+
+func (s *compactionState) current() *version.FileMetaData {
+	return s.outputs[len(s.outputs)-1]
+}
+
+// doCompactionWork 按顺序合并所有输入文件, 写入新的 table, 输出文件超过目标大小后切换到新的文件.
+// 同一个 user key 的多个版本按序列号从新到旧排列, 以下记录被丢弃:
+//   - 存在对所有快照可见的更新版本, 即更新版本的序列号不大于 smallestSnapshot;
+//   - 对所有快照可见的删除标记, 且输出层之下没有该 key 更旧的数据.
+//
+// 同一个 user key 的所有版本写入同一个输出文件, 使第 0 层之外的文件之间 user key 范围不重叠.
+// 调用时不持有 db.mu, 期间出现只读内存表时优先将其写入 table, 避免写操作长时间等待.
+func (db *DB) doCompactionWork(state *compactionState) error {
+	it, err := db.compactionInputIterator(state.c)
+	if err != nil {
+		return err
+	}
+	defer it.Release()
+	defer func() {
+		// 出错时关闭未完成的输出文件.
+		if state.fileWriter != nil {
+			state.fileWriter.Close()
+		}
+	}()
+
+	var (
+		currentUserKey    slice.Slice
+		hasCurrentUserKey bool
+		// 当前 user key 上一条记录的序列号, 没有时为 ikey.MaxSequenceNumber.
+		lastSequenceForKey = ikey.MaxSequenceNumber
+	)
+	for it.SeekToFirst(); it.Success(); it.Next() {
+		if atomic.LoadInt32(&db.hasImm) == 1 {
+			if err := db.flushImmDuringCompaction(); err != nil {
+				return err
+			}
+		}
+
+		key := it.Key()
+		parsed, err := ikey.ParseInternalKey(key)
+		newUserKey := err != nil || !hasCurrentUserKey ||
+			db.opts.Comparator.Compare(parsed.UserKey, currentUserKey) != slice.CMPSame
+		if newUserKey && state.tableWriter != nil && state.tableWriter.FileSize() >= state.c.MaxOutputFileSize() {
+			if err := db.finishCompactionOutput(state); err != nil {
+				return err
+			}
+		}
+
+		if err != nil {
+			// 无法解析的 key 不丢弃, 由读取者报告错误.
+			currentUserKey, hasCurrentUserKey = currentUserKey[:0], false
+			lastSequenceForKey = ikey.MaxSequenceNumber
+		} else {
+			if newUserKey {
+				currentUserKey, hasCurrentUserKey = append(currentUserKey[:0], parsed.UserKey...), true
+				lastSequenceForKey = ikey.MaxSequenceNumber
+			}
+
+			drop := lastSequenceForKey <= state.smallestSnapshot ||
+				parsed.ValueType == ikey.TypeDeletion && parsed.Sequence <= state.smallestSnapshot &&
+					state.c.IsBaseLevelForKey(parsed.UserKey)
+			lastSequenceForKey = parsed.Sequence
+			if drop {
+				continue
+			}
+		}
+
+		if state.tableWriter == nil {
+			if err := db.openCompactionOutput(state); err != nil {
+				return err
+			}
+		}
+		if err := state.tableWriter.Add(key, it.Value()); err != nil {
+			return err
+		}
+		if state.numEntries == 0 {
+			state.current().Smallest = append(slice.Slice{}, key...)
+		}
+		state.numEntries++
+		state.lastKey = append(state.lastKey[:0], key...)
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+
+	if state.tableWriter != nil {
+		return db.finishCompactionOutput(state)
+	}
+
+	return nil
+}
+
+// flushImmDuringCompaction 在压缩过程中将只读内存表写入 table.
+func (db *DB) flushImmDuringCompaction() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.imm == nil {
+		return nil
+	}
+	if err := db.flushMemtable(); err != nil {
+		return err
+	}
+	db.bgCond.Broadcast()
+
+	return nil
+}
+
+// compactionInputIterator 返回按顺序遍历所有输入文件的迭代器, 读取的数据块不加入块缓存.
+func (db *DB) compactionInputIterator(c *version.Compaction) (common.Iterator, error) {
+	ropts := &table.ReadOptions{DontFillCache: true}
+	var children []common.Iterator
+	for which := 0; which < c.NumInputLevels(); which++ {
+		for _, f := range c.Inputs(which) {
+			it, err := db.tableCache.newIterator(f, ropts)
+			if err != nil {
+				for _, child := range children {
+					child.Release()
+				}
+				return nil, err
+			}
+			children = append(children, it)
+		}
+	}
+
+	return common.NewMergingIterator(ikey.NewComparator(db.opts.Comparator), children...), nil
+}
+
+// openCompactionOutput 创建新的输出文件.
+func (db *DB) openCompactionOutput(state *compactionState) error {
+	db.mu.Lock()
+	number := db.versions.NewFileNumber()
+	db.pendingOutputs[number] = struct{}{}
+	state.outputs = append(state.outputs, &version.FileMetaData{Number: number})
+	db.mu.Unlock()
+
+	fileWriter, err := file.NewWriter(tableFileName(db.dir, number))
+	if err != nil {
+		return err
+	}
+	state.fileWriter = fileWriter
+	state.tableWriter = table.NewWriter(fileWriter, db.opts.tableOptions())
+	state.current().CreationTime = state.c.MaxCreationTime()
+	state.numEntries = 0
+	state.lastKey = state.lastKey[:0]
+
+	return nil
+}
+
+// finishCompactionOutput 完成当前输出文件并同步到磁盘, 确认其可以正常打开.
+func (db *DB) finishCompactionOutput(state *compactionState) error {
+	meta := state.current()
+	err := state.tableWriter.Finish()
+	if err == nil {
+		err = state.fileWriter.Sync()
+	}
+	if closeErr := state.fileWriter.Close(); err == nil {
+		err = closeErr
+	}
+	meta.Size = state.tableWriter.FileSize()
+	meta.Largest = append(slice.Slice{}, state.lastKey...)
+	state.fileWriter, state.tableWriter = nil, nil
+	if err != nil {
+		os.Remove(tableFileName(db.dir, meta.Number))
+		return err
+	}
+
+	h, err := db.tableCache.findTable(meta)
+	if err != nil {
+		return err
+	}
+	db.tableCache.release(h)
+
+	return nil
+}
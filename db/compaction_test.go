@@ -0,0 +1,447 @@
+package db
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goleveldb/goleveldb/ikey"
+	"github.com/goleveldb/goleveldb/slice"
+	"github.com/goleveldb/goleveldb/version"
+)
+
+// waitForBackgroundWork 等待后台刷盘与压缩全部完成.
+func waitForBackgroundWork(db *DB) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for db.bgScheduled {
+		db.bgCond.Wait()
+	}
+}
+
+// putOverwrites 以随机顺序多轮覆盖写 numKeys 个 key, 每个 key 在多个 table 中都有旧的版本,
+// 返回每个 key 最新的 value.
+func putOverwrites(t *testing.T, db *DB, numKeys, rounds int) map[string]string {
+	t.Helper()
+
+	want := make(map[string]string)
+	rnd := rand.New(rand.NewSource(1))
+	for round := 0; round < rounds; round++ {
+		for _, i := range rnd.Perm(numKeys) {
+			key, value := fmt.Sprintf("key_%04d", i), fmt.Sprintf("value_%d_%04d", round, i)
+			runDBOperations(t, db, []*dbOperation{
+				{name: "put " + key, method: methodPut, key: key, value: value},
+			})
+			want[key] = value
+		}
+	}
+
+	return want
+}
+
+// checkValues 检查 DB 中每个 key 的 value, 并检查重新打开后的 DB.
+func checkValues(t *testing.T, db *DB, dir string, opts *Options, want map[string]string) {
+	t.Helper()
+
+	check := func(db *DB) {
+		for key, value := range want {
+			runDBOperations(t, db, []*dbOperation{
+				{name: "get " + key, method: methodGet, key: key, value: value},
+			})
+		}
+	}
+	check(db)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	check(db)
+}
+
+// countLiveFiles 返回当前 Version 中的文件数.
+func countLiveFiles(db *DB) int {
+	live := 0
+	for level := 0; level < version.NumLevels; level++ {
+		live += db.versions.Current().NumFiles(level)
+	}
+
+	return live
+}
+
+func TestDB_Compaction(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{
+		CreateIfMissing:      true,
+		WriteBufferSize:      4096,
+		L0CompactionTrigger:  2,
+		MaxBytesForLevelBase: 16 * 1024,
+		TargetFileSize:       4096,
+	}
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := putOverwrites(t, db, 1000, 4)
+	waitForBackgroundWork(db)
+
+	v := db.versions.Current()
+	if v.NumFiles(0) >= opts.L0CompactionTrigger {
+		t.Errorf("level 0 files = %d, want < %d", v.NumFiles(0), opts.L0CompactionTrigger)
+	}
+	if v.NumFiles(1) == 0 || v.NumFiles(2) == 0 {
+		t.Errorf("level 1 files = %d, level 2 files = %d, want > 0", v.NumFiles(1), v.NumFiles(2))
+	}
+	for level := 1; level < version.NumLevels; level++ {
+		for _, f := range v.Files(level) {
+			if f.Size > 2*uint64(opts.TargetFileSize) {
+				t.Errorf("level %d file %d size = %d, want about %d", level, f.Number, f.Size, opts.TargetFileSize)
+			}
+		}
+	}
+	if got := countFiles(t, dir, fileTypeTable); got != countLiveFiles(db) {
+		t.Errorf("table files = %d, live files = %d", got, countLiveFiles(db))
+	}
+
+	checkValues(t, db, dir, opts, want)
+}
+
+func TestDB_UniversalCompaction(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{
+		CreateIfMissing:     true,
+		WriteBufferSize:     4096,
+		L0CompactionTrigger: 4,
+		CompactionStyle:     CompactionStyleUniversal,
+	}
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := putOverwrites(t, db, 1000, 4)
+	waitForBackgroundWork(db)
+
+	// 压缩结束后 sorted run 的数量低于 L0CompactionTrigger, 且数据已被合并到第 0 层以外的层.
+	v := db.versions.Current()
+	runs := v.NumFiles(0)
+	for level := 1; level < version.NumLevels; level++ {
+		if v.NumFiles(level) > 0 {
+			runs++
+		}
+	}
+	if runs >= opts.L0CompactionTrigger {
+		t.Errorf("sorted runs = %d, want < %d", runs, opts.L0CompactionTrigger)
+	}
+	if v.NumFiles(0) == countLiveFiles(db) {
+		t.Errorf("all %d files are in level 0", v.NumFiles(0))
+	}
+	if got := countFiles(t, dir, fileTypeTable); got != countLiveFiles(db) {
+		t.Errorf("table files = %d, live files = %d", got, countLiveFiles(db))
+	}
+
+	checkValues(t, db, dir, opts, want)
+}
+
+func TestDB_CompactionDropsShadowedVersions(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir, &Options{L0CompactionTrigger: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// 第 0 层的 4 个 table 依次使用序列号 1, 2, 3, 4.
+	for sequence := uint64(1); sequence <= 4; sequence++ {
+		addTestTable(t, db, 0, sequence, "key", fmt.Sprintf("value_%d", sequence))
+	}
+	db.mu.Lock()
+	db.maybeScheduleCompaction()
+	db.mu.Unlock()
+	waitForBackgroundWork(db)
+
+	v := db.versions.Current()
+	if v.NumFiles(0) != 0 || v.NumFiles(1) != 1 {
+		t.Fatalf("level 0 files = %d, level 1 files = %d, want 0, 1", v.NumFiles(0), v.NumFiles(1))
+	}
+	if got := countFiles(t, dir, fileTypeTable); got != 1 {
+		t.Errorf("table files = %d, want 1", got)
+	}
+
+	it, err := db.tableCache.newIterator(v.Files(1)[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Release()
+	entries := 0
+	for it.SeekToFirst(); it.Success(); it.Next() {
+		key := ikey.InternalKey(it.Key())
+		if string(key.UserKey()) != "key" || key.Sequence() != 4 || string(it.Value()) != "value_4" {
+			t.Errorf("entry = %s@%d => %s, want key@4 => value_4", key.UserKey(), key.Sequence(), it.Value())
+		}
+		entries++
+	}
+	if entries != 1 {
+		t.Errorf("entries = %d, want 1", entries)
+	}
+}
+
+func TestDB_FIFOCompaction(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{
+		CreateIfMissing: true,
+		WriteBufferSize: 4096,
+		CompactionStyle: CompactionStyleFIFO,
+		FIFO:            FIFOOptions{MaxTableFilesSize: 32 * 1024},
+	}
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	const numKeys = 2000
+	value := fmt.Sprintf("%0100d", 0)
+	for i := 0; i < numKeys; i++ {
+		key := fmt.Sprintf("key_%05d", i)
+		runDBOperations(t, db, []*dbOperation{
+			{name: "put " + key, method: methodPut, key: key, value: value},
+		})
+	}
+	waitForBackgroundWork(db)
+
+	// 所有 table 都在第 0 层, 总大小不超过上限, 且不重写数据.
+	v := db.versions.Current()
+	if v.NumFiles(0) != countLiveFiles(db) {
+		t.Errorf("level 0 files = %d, live files = %d", v.NumFiles(0), countLiveFiles(db))
+	}
+	if got := v.NumLevelBytes(0); got > opts.FIFO.MaxTableFilesSize {
+		t.Errorf("table files size = %d, want <= %d", got, opts.FIFO.MaxTableFilesSize)
+	}
+	if got := countFiles(t, dir, fileTypeTable); got != countLiveFiles(db) {
+		t.Errorf("table files = %d, live files = %d", got, countLiveFiles(db))
+	}
+	for _, f := range v.Files(0) {
+		if time.Since(time.Unix(f.CreationTime, 0)) > time.Minute {
+			t.Errorf("file %d creation time = %d", f.Number, f.CreationTime)
+		}
+	}
+
+	// 最旧的数据被删除, 最新的数据仍可读取.
+	runDBOperations(t, db, []*dbOperation{
+		{name: "get oldest key", method: methodGet, key: "key_00000", wantErr: ErrNotFound},
+		{name: "get newest key", method: methodGet, key: fmt.Sprintf("key_%05d", numKeys-1), value: value},
+	})
+}
+
+// countVersions 返回当前 Version 的所有 table 中 userKey 的记录数, 包括删除标记.
+func countVersions(t *testing.T, db *DB, userKey string) int {
+	t.Helper()
+
+	res := 0
+	for level := 0; level < version.NumLevels; level++ {
+		for _, f := range db.versions.Current().Files(level) {
+			it, err := db.tableCache.newIterator(f, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for it.SeekToFirst(); it.Success(); it.Next() {
+				if string(ikey.InternalKey(it.Key()).UserKey()) == userKey {
+					res++
+				}
+			}
+			it.Release()
+		}
+	}
+
+	return res
+}
+
+func TestDB_CompactionSnapshot(t *testing.T) {
+	db, err := Open(t.TempDir(), &Options{CreateIfMissing: true, WriteBufferSize: 4096, L0CompactionTrigger: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	runDBOperations(t, db, []*dbOperation{
+		{name: "put foo v1", method: methodPut, key: "foo", value: "v1"},
+		{name: "put bar v1", method: methodPut, key: "bar", value: "v1"},
+		{name: "put baz v1", method: methodPut, key: "baz", value: "v1"},
+	})
+	s := db.GetSnapshot()
+	runDBOperations(t, db, []*dbOperation{
+		{name: "put foo v2", method: methodPut, key: "foo", value: "v2"},
+		{name: "delete bar", method: methodDelete, key: "bar"},
+		{name: "delete baz", method: methodDelete, key: "baz"},
+	})
+
+	// 写入足够多的数据, 使上述记录被写入 table 并被压缩.
+	putOverwrites(t, db, 200, 5)
+	waitForBackgroundWork(db)
+	if db.versions.Current().NumFiles(0) >= 2 || db.versions.Current().NumFiles(1) == 0 {
+		t.Fatalf("level 0 files = %d, level 1 files = %d, want compacted",
+			db.versions.Current().NumFiles(0), db.versions.Current().NumFiles(1))
+	}
+
+	// 快照仍可读取压缩前的版本.
+	for key, want := range map[string]string{"foo": "v1", "bar": "v1", "baz": "v1"} {
+		if value, err := db.Get([]byte(key), &ReadOptions{Snapshot: s}); err != nil || string(value) != want {
+			t.Errorf("Get(%s) at snapshot = (%s, %v), want %s", key, value, err, want)
+		}
+	}
+	runDBOperations(t, db, []*dbOperation{
+		{name: "get foo", method: methodGet, key: "foo", value: "v2"},
+		{name: "get bar", method: methodGet, key: "bar", wantErr: ErrNotFound},
+	})
+	if got := countVersions(t, db, "bar"); got != 2 {
+		t.Errorf("versions of bar = %d, want 2", got)
+	}
+
+	// 释放快照后, 被覆盖的版本与更深的层中没有旧数据的删除标记在压缩时被丢弃.
+	db.ReleaseSnapshot(s)
+	putOverwrites(t, db, 200, 5)
+	waitForBackgroundWork(db)
+	if got := countVersions(t, db, "foo"); got != 1 {
+		t.Errorf("versions of foo = %d, want 1", got)
+	}
+	if got := countVersions(t, db, "bar"); got != 0 {
+		t.Errorf("versions of bar = %d, want 0", got)
+	}
+	runDBOperations(t, db, []*dbOperation{
+		{name: "get foo", method: methodGet, key: "foo", value: "v2"},
+		{name: "get bar", method: methodGet, key: "bar", wantErr: ErrNotFound},
+		{name: "get baz", method: methodGet, key: "baz", wantErr: ErrNotFound},
+	})
+}
+
+func TestDB_ConcurrentGet(t *testing.T) {
+	opts := &Options{
+		CreateIfMissing:      true,
+		WriteBufferSize:      4096,
+		L0CompactionTrigger:  2,
+		MaxBytesForLevelBase: 16 * 1024,
+		TargetFileSize:       4096,
+	}
+	db, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	const numKeys = 500
+	putOverwrites(t, db, numKeys, 1)
+
+	// 读取与刷盘, 压缩并发进行, 被压缩删除的 table 在读取完成前不会被删除.
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(r)))
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				i := rnd.Intn(numKeys)
+				value, err := db.Get(slice.Slice(fmt.Sprintf("key_%04d", i)), nil)
+				if err != nil || !strings.HasSuffix(string(value), fmt.Sprintf("_%04d", i)) {
+					t.Errorf("get key_%04d => value %s, err %v", i, value, err)
+					return
+				}
+			}
+		}(r)
+	}
+	putOverwrites(t, db, numKeys, 4)
+	close(done)
+	wg.Wait()
+}
+
+// levelsOf 返回当前 Version 中包含 userKey 的记录的层.
+func levelsOf(t *testing.T, db *DB, userKey string) []int {
+	t.Helper()
+
+	var res []int
+	for level := 0; level < version.NumLevels; level++ {
+		for _, f := range db.versions.Current().Files(level) {
+			it, err := db.tableCache.newIterator(f, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for it.SeekToFirst(); it.Success(); it.Next() {
+				if string(ikey.InternalKey(it.Key()).UserKey()) == userKey {
+					res = append(res, level)
+				}
+			}
+			it.Release()
+		}
+	}
+
+	return res
+}
+
+func TestDB_CompactionKeepsDeletionAboveOlderData(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{CreateIfMissing: true, WriteBufferSize: 1024, L0CompactionTrigger: 1000}
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 将 k 的 value 所在的 table 移动到第 2 层.
+	runDBOperations(t, db, []*dbOperation{{name: "put k", method: methodPut, key: "k", value: "v"}})
+	flushFiller(t, db, "z")
+	db.mu.Lock()
+	edit := &version.VersionEdit{}
+	for _, f := range db.versions.Current().Files(0) {
+		if string(ikey.InternalKey(f.Smallest).UserKey()) == "k" {
+			edit.DeleteFile(0, f.Number)
+			edit.AddFile(2, f)
+		}
+	}
+	err = db.versions.LogAndApply(edit)
+	db.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if levels := levelsOf(t, db, "k"); fmt.Sprint(levels) != "[2]" {
+		t.Fatalf("levels of k = %v, want [2]", levels)
+	}
+
+	// 删除标记与相互重叠的第 0 层 table 一起被压缩到第 1 层时, 第 2 层中仍有旧的 value, 删除标记必须保留.
+	runDBOperations(t, db, []*dbOperation{{name: "delete k", method: methodDelete, key: "k"}})
+	flushFiller(t, db, "b")
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = Open(dir, &Options{WriteBufferSize: 1024, L0CompactionTrigger: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForBackgroundWork(db)
+	if levels := levelsOf(t, db, "k"); fmt.Sprint(levels) != "[1 2]" {
+		t.Fatalf("levels of k = %v, want [1 2]", levels)
+	}
+	runDBOperations(t, db, []*dbOperation{{name: "get deleted k", method: methodGet, key: "k", wantErr: ErrNotFound}})
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	runDBOperations(t, db, []*dbOperation{{name: "get deleted k after reopen", method: methodGet, key: "k", wantErr: ErrNotFound}})
+}
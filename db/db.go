@@ -0,0 +1,313 @@
+// Package db 将日志、内存表与 table 组合为完整的 kv 存储.
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/goleveldb/goleveldb/file"
+	"github.com/goleveldb/goleveldb/ikey"
+	"github.com/goleveldb/goleveldb/log"
+	"github.com/goleveldb/goleveldb/memtable"
+	"github.com/goleveldb/goleveldb/slice"
+	"github.com/goleveldb/goleveldb/table"
+	"github.com/goleveldb/goleveldb/version"
+)
+
+var (
+	// ErrNotFound DB 中不存在相应的 key.
+	ErrNotFound = errors.New("key not found")
+	// ErrClosed DB 已关闭.
+	ErrClosed = errors.New("db is closed")
+	// ErrDBExists 设置了 ErrorIfExists, 但 DB 已存在.
+	ErrDBExists = errors.New("db already exists")
+	// ErrDBMissing 未设置 CreateIfMissing, 且 DB 不存在.
+	ErrDBMissing = errors.New("db does not exist")
+	// ErrCurrentMissing DB 目录下有 table 文件, 但没有指向 MANIFEST 的 CURRENT 文件.
+	ErrCurrentMissing = errors.New("CURRENT file does not exist")
+)
+
+// DB 是一个持久化的有序 kv 存储, 可以被多个 goroutine 并发使用.
+// 写操作先追加到日志(WAL), 再写入内存表; 读操作依次查找内存表、正在刷盘的内存表与已刷盘的 table.
+// 内存表超过 WriteBufferSize 后变为只读, 在后台写入第 0 层的 table, 同时新的写操作写入新的内存表与日志.
+// 各层的 table 过多或过大时, 在后台与下一层合并(压缩), 以减少读取时需要查找的文件数.
+type DB struct {
+	dir  string
+	opts *Options
+
+	mu         sync.Mutex
+	mem        *memtable.Memtable
+	imm        *memtable.Memtable  // 正在后台写入 table 的只读内存表, 没有时为 nil.
+	versions   *version.VersionSet // 记录已刷盘的 table, 以及文件编号与序列号.
+	tableCache *tableCache
+	snapshots  snapshotList
+
+	// pendingOutputs 正在写入的 table 文件编号, 这些文件尚未加入 Version, 但不能被删除.
+	pendingOutputs map[uint64]struct{}
+	// hasImm 与 imm 是否为 nil 保持一致, 压缩过程中不持有 db.mu 时据此检查是否需要优先刷盘.
+	hasImm int32
+	// bgScheduled 为 true 表示后台刷盘或压缩正在进行, 每完成一项工作都通过 bgCond 通知等待者.
+	bgScheduled bool
+	bgCond      *sync.Cond
+	// bgErr 后台刷盘或压缩, 以及写日志或内存表时发生的错误, 此后的写操作都将返回该错误.
+	bgErr error
+
+	logFile   file.Writer
+	log       log.Writer
+	logNumber uint64
+
+	closed bool
+}
+
+// Open 打开 dir 目录下的 DB, 并重放目录下的日志恢复上次关闭前的数据. opts 为 nil 时使用默认配置.
+func Open(dir string, opts *Options) (*DB, error) {
+	opts = opts.sanitize()
+	if err := prepareDir(dir, opts); err != nil {
+		return nil, err
+	}
+
+	db := &DB{
+		dir:        dir,
+		opts:       opts,
+		mem:        memtable.New(opts.Comparator),
+		versions:   version.NewVersionSet(dir, opts.versionOptions()),
+		tableCache: newTableCache(dir, opts.tableOptions(), opts.MaxOpenFiles-numNonTableCacheFiles),
+
+		pendingOutputs: make(map[uint64]struct{}),
+	}
+	db.bgCond = sync.NewCond(&db.mu)
+	db.snapshots.init()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if err := db.recover(); err != nil {
+		db.release()
+		return nil, err
+	}
+	db.maybeScheduleCompaction()
+
+	return db, nil
+}
+
+// prepareDir 根据 opts 检查并创建 DB 目录.
+func prepareDir(dir string, opts *Options) error {
+	_, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		if !opts.CreateIfMissing {
+			return fmt.Errorf("%s: %w", dir, ErrDBMissing)
+		}
+
+		return os.MkdirAll(dir, os.ModePerm)
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.ErrorIfExists {
+		return fmt.Errorf("%s: %w", dir, ErrDBExists)
+	}
+
+	return nil
+}
+
+// newLog 创建新的日志文件, 此后的写操作都将追加到该文件.
+func (db *DB) newLog() error {
+	number := db.versions.NewFileNumber()
+	logFile, err := file.NewWriter(logFileName(db.dir, number))
+	if err != nil {
+		return err
+	}
+
+	db.logFile = logFile
+	db.log = log.NewWriter(logFile, 0)
+	db.logNumber = number
+
+	return nil
+}
+
+// Put 写入 key, value.
+func (db *DB) Put(key, value slice.Slice, opts *WriteOptions) error {
+	batch := NewWriteBatch()
+	batch.Put(key, value)
+
+	return db.Write(batch, opts)
+}
+
+// Delete 删除 key, key 不存在时不返回错误.
+func (db *DB) Delete(key slice.Slice, opts *WriteOptions) error {
+	batch := NewWriteBatch()
+	batch.Delete(key)
+
+	return db.Write(batch, opts)
+}
+
+// Write 原子地执行 batch 中的所有操作: batch 作为一条记录追加到日志后, 以连续的序列号写入内存表.
+func (db *DB) Write(batch *WriteBatch, opts *WriteOptions) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return ErrClosed
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+	if err := db.makeRoomForWrite(); err != nil {
+		return err
+	}
+
+	batch.setSequence(db.versions.LastSequence() + 1)
+	err := db.log.AddRecord(batch.contents())
+	if err == nil && opts != nil && opts.Sync {
+		err = db.logFile.Sync()
+	}
+	if err == nil {
+		err = batch.insertInto(db.mem)
+	}
+	if err != nil {
+		// 日志记录或内存表可能只写入了一部分, 之后的写操作会与之不一致, 因此此后的写操作都返回该错误.
+		db.bgErr = err
+		return err
+	}
+	db.versions.SetLastSequence(db.versions.LastSequence() + uint64(batch.Len()))
+
+	return nil
+}
+
+// makeRoomForWrite 在内存表已满时切换到新的内存表与日志, 并在后台将旧内存表写入 table.
+// 上一个内存表仍在刷盘, 或第 0 层的文件过多时, 等待后台工作完成. 调用时需持有 db.mu.
+func (db *DB) makeRoomForWrite() error {
+	for {
+		switch {
+		case db.bgErr != nil:
+			return db.bgErr
+		case db.closed:
+			return ErrClosed
+		case db.mem.ApproximateMemoryUsage() <= db.opts.WriteBufferSize:
+			return nil
+		case db.imm != nil:
+			db.bgCond.Wait()
+		case db.versions.Current().NumFiles(0) >= db.opts.l0StopWritesTrigger():
+			db.bgCond.Wait()
+		default:
+			oldLogFile := db.logFile
+			if err := db.newLog(); err != nil {
+				return err
+			}
+			if err := oldLogFile.Close(); err != nil {
+				return err
+			}
+
+			db.setImm(db.mem)
+			db.mem = memtable.New(db.opts.Comparator)
+			db.maybeScheduleCompaction()
+		}
+	}
+}
+
+// Get 获取 key 对应的 value, key 不存在时返回 ErrNotFound.
+// 指定 opts.Snapshot 时, 读取创建快照时 key 对应的 value.
+// 只在获取内存表与当前 Version 时持有 db.mu, 读取内存表与 table 时不加锁.
+func (db *DB) Get(key slice.Slice, opts *ReadOptions) (slice.Slice, error) {
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return nil, ErrClosed
+	}
+
+	sequence := db.versions.LastSequence()
+	if opts != nil && opts.Snapshot != nil {
+		sequence = opts.Snapshot.sequence
+	}
+	mem, imm, current := db.mem, db.imm, db.versions.Current()
+	db.versions.Ref(current)
+	db.mu.Unlock()
+
+	defer func() {
+		db.mu.Lock()
+		db.versions.Unref(current)
+		db.mu.Unlock()
+	}()
+
+	return db.get(key, sequence, opts, mem, imm, current)
+}
+
+// get 依次在 mem, imm 与 current 的 table 中查找 key 序列号不大于 sequence 的最新版本, 调用时无需持有 db.mu.
+func (db *DB) get(key slice.Slice, sequence uint64, opts *ReadOptions,
+	mem, imm *memtable.Memtable, current *version.Version) (slice.Slice, error) {
+	for _, m := range []*memtable.Memtable{mem, imm} {
+		if m == nil {
+			continue
+		}
+		switch value, res := m.Lookup(key, sequence); res {
+		case memtable.Found:
+			return value, nil
+		case memtable.Deleted:
+			return nil, ErrNotFound
+		}
+	}
+
+	// 在 table 中查找序列号不大于 sequence 的最新版本, 第一个包含该 key 的 table 中的版本即为结果.
+	lookupKey := ikey.MakeLookupKey(key, sequence)
+	tableReadOpts := opts.tableReadOptions()
+	for _, f := range current.FilesForKey(key) {
+		foundKey, value, err := db.tableCache.get(f, slice.Slice(lookupKey.InternalKey()), tableReadOpts)
+		if errors.Is(err, table.ErrNoSuchKey) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		parsed, err := ikey.ParseInternalKey(foundKey)
+		if err != nil {
+			return nil, err
+		}
+		if db.opts.Comparator.Compare(parsed.UserKey, key) != slice.CMPSame {
+			continue
+		}
+		if parsed.ValueType == ikey.TypeDeletion {
+			return nil, ErrNotFound
+		}
+
+		return value, nil
+	}
+
+	return nil, ErrNotFound
+}
+
+// Close 关闭 DB, 关闭后不可再进行读写操作. 正在进行的后台刷盘或压缩完成后才会返回.
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return ErrClosed
+	}
+	db.closed = true
+	for db.bgScheduled {
+		db.bgCond.Wait()
+	}
+
+	return db.release()
+}
+
+// release 关闭 DB 打开的所有文件.
+func (db *DB) release() error {
+	for level := 0; level < version.NumLevels; level++ {
+		for _, f := range db.versions.Current().Files(level) {
+			db.tableCache.evict(f.Number)
+		}
+	}
+
+	err := db.versions.Close()
+	if db.logFile != nil {
+		if closeErr := db.logFile.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
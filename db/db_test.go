@@ -0,0 +1,259 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/goleveldb/goleveldb/cache"
+	"github.com/goleveldb/goleveldb/ikey"
+	mockfile "github.com/goleveldb/goleveldb/internal/mock/file"
+	"github.com/goleveldb/goleveldb/log"
+	"github.com/goleveldb/goleveldb/slice"
+	"github.com/goleveldb/goleveldb/table"
+	"github.com/goleveldb/goleveldb/version"
+)
+
+const (
+	methodPut    = "put"
+	methodGet    = "get"
+	methodDelete = "delete"
+)
+
+type dbOperation struct {
+	name   string
+	method string
+	key    string
+	value  string
+
+	wantErr error
+}
+
+func openTestDB(t *testing.T, dir string) *DB {
+	t.Helper()
+
+	db, err := Open(dir, &Options{CreateIfMissing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// addTestTable 将 kvs 中按顺序排列的 key, value 写入新的 table 并加入 db 的第 level 层,
+// 第 i 条记录的序列号为 sequence+i.
+func addTestTable(t *testing.T, db *DB, level int, sequence uint64, kvs ...string) {
+	t.Helper()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	meta, err := db.buildTableFile(db.versions.NewFileNumber(), func(tableWriter table.Writer, meta *version.FileMetaData) (int, error) {
+		for i := 0; i < len(kvs); i += 2 {
+			key := slice.Slice(ikey.MakeInternalKey(slice.Slice(kvs[i]), sequence+uint64(i/2), ikey.TypeValue))
+			if err := tableWriter.Add(key, slice.Slice(kvs[i+1])); err != nil {
+				return 0, err
+			}
+			if i == 0 {
+				meta.Smallest = key
+			}
+			meta.Largest = key
+		}
+		return len(kvs) / 2, tableWriter.Finish()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if last := sequence + uint64(len(kvs)/2) - 1; last > db.versions.LastSequence() {
+		db.versions.SetLastSequence(last)
+	}
+	edit := &version.VersionEdit{}
+	edit.AddFile(level, meta)
+	if err := db.versions.LogAndApply(edit); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func runDBOperations(t *testing.T, db *DB, operations []*dbOperation) {
+	t.Helper()
+
+	for _, op := range operations {
+		var (
+			got slice.Slice
+			err error
+		)
+		switch op.method {
+		case methodPut:
+			err = db.Put(slice.Slice(op.key), slice.Slice(op.value), nil)
+		case methodDelete:
+			err = db.Delete(slice.Slice(op.key), &WriteOptions{Sync: true})
+		case methodGet:
+			got, err = db.Get(slice.Slice(op.key), nil)
+			if err == nil && string(got) != op.value {
+				t.Errorf("%s => want value %s, get %s", op.name, op.value, string(got))
+			}
+		default:
+			t.Fatal("no such method: ", op.method)
+		}
+
+		if !errors.Is(err, op.wantErr) {
+			t.Errorf("%s => want err = %v, get err = %v", op.name, op.wantErr, err)
+		}
+	}
+}
+
+func TestDB_All(t *testing.T) {
+	db := openTestDB(t, t.TempDir())
+	defer db.Close()
+
+	runDBOperations(t, db, []*dbOperation{
+		{name: "get not exist key", method: methodGet, key: "foo", wantErr: ErrNotFound},
+		{name: "put foo", method: methodPut, key: "foo", value: "bar"},
+		{name: "get foo", method: methodGet, key: "foo", value: "bar"},
+		{name: "overwrite foo", method: methodPut, key: "foo", value: "baz"},
+		{name: "get overwritten foo", method: methodGet, key: "foo", value: "baz"},
+		{name: "delete foo", method: methodDelete, key: "foo"},
+		{name: "get deleted foo", method: methodGet, key: "foo", wantErr: ErrNotFound},
+		{name: "delete not exist key", method: methodDelete, key: "not exist"},
+	})
+}
+
+func TestDB_Close(t *testing.T) {
+	db := openTestDB(t, t.TempDir())
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	runDBOperations(t, db, []*dbOperation{
+		{name: "put after close", method: methodPut, key: "foo", value: "bar", wantErr: ErrClosed},
+		{name: "get after close", method: methodGet, key: "foo", wantErr: ErrClosed},
+		{name: "delete after close", method: methodDelete, key: "foo", wantErr: ErrClosed},
+	})
+
+	if err := db.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("close twice => want err = %v, get err = %v", ErrClosed, err)
+	}
+}
+
+func TestDB_WriteErrorIsSticky(t *testing.T) {
+	errIO := errors.New("io error")
+	tests := []struct {
+		name   string
+		sync   bool
+		expect func(w *mockfile.MockWriter)
+	}{
+		{
+			name: "append",
+			expect: func(w *mockfile.MockWriter) {
+				w.EXPECT().Append(gomock.Any()).Return(errIO)
+			},
+		},
+		{
+			name: "sync",
+			sync: true,
+			expect: func(w *mockfile.MockWriter) {
+				w.EXPECT().Append(gomock.Any()).AnyTimes().Return(nil)
+				w.EXPECT().Flush().AnyTimes().Return(nil)
+				w.EXPECT().Sync().Return(errIO)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			db := openTestDB(t, t.TempDir())
+			logFile := mockfile.NewMockWriter(mockCtrl)
+			tt.expect(logFile)
+			logFile.EXPECT().Close().Return(nil)
+			if err := db.logFile.Close(); err != nil {
+				t.Fatal(err)
+			}
+			db.logFile, db.log = logFile, log.NewWriter(logFile, 0)
+
+			// 日志记录可能只写入了一部分, 之后的写操作都返回该错误, 不再写入日志.
+			opts := &WriteOptions{Sync: tt.sync}
+			for i := 0; i < 2; i++ {
+				if err := db.Put(slice.Slice("foo"), slice.Slice("bar"), opts); !errors.Is(err, errIO) {
+					t.Errorf("put %d => want err = %v, get err = %v", i, errIO, err)
+				}
+			}
+			runDBOperations(t, db, []*dbOperation{
+				{name: "get failed write", method: methodGet, key: "foo", wantErr: ErrNotFound},
+			})
+			if err := db.Close(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	t.Run("missing", func(t *testing.T) {
+		_, err := Open(filepath.Join(t.TempDir(), "missing"), nil)
+		if !errors.Is(err, ErrDBMissing) {
+			t.Errorf("Open() => want err = %v, get err = %v", ErrDBMissing, err)
+		}
+	})
+
+	t.Run("create if missing", func(t *testing.T) {
+		db := openTestDB(t, filepath.Join(t.TempDir(), "missing"))
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("error if exists", func(t *testing.T) {
+		_, err := Open(t.TempDir(), &Options{ErrorIfExists: true})
+		if !errors.Is(err, ErrDBExists) {
+			t.Errorf("Open() => want err = %v, get err = %v", ErrDBExists, err)
+		}
+	})
+}
+
+func TestDB_GetFromTable(t *testing.T) {
+	db := openTestDB(t, t.TempDir())
+	defer db.Close()
+	addTestTable(t, db, 0, 1, "a", "table_a", "b", "table_b")
+
+	runDBOperations(t, db, []*dbOperation{
+		{name: "get from table", method: methodGet, key: "a", value: "table_a"},
+		{name: "put b", method: methodPut, key: "b", value: "mem_b"},
+		{name: "get from memtable first", method: methodGet, key: "b", value: "mem_b"},
+		{name: "get not exist key", method: methodGet, key: "c", wantErr: ErrNotFound},
+		// 内存表中的删除标记覆盖 table 中的旧数据.
+		{name: "delete a", method: methodDelete, key: "a"},
+		{name: "get deleted key", method: methodGet, key: "a", wantErr: ErrNotFound},
+	})
+}
+
+func TestDB_BlockCache(t *testing.T) {
+	blockCache := cache.NewLRUCache(1024)
+	db, err := Open(t.TempDir(), &Options{BlockCache: blockCache})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	addTestTable(t, db, 0, 1, "a", "table_a")
+
+	// DontFillCache 的读取不会将数据块加入缓存.
+	if _, err := db.Get(slice.Slice("a"), &ReadOptions{DontFillCache: true}); err != nil {
+		t.Fatal(err)
+	}
+	if blockCache.TotalCharge() != 0 {
+		t.Errorf("cache charge = %d, want 0", blockCache.TotalCharge())
+	}
+
+	hits, misses := blockCache.Hits(), blockCache.Misses()
+	for i := 0; i < 3; i++ {
+		runDBOperations(t, db, []*dbOperation{
+			{name: "get from table", method: methodGet, key: "a", value: "table_a"},
+		})
+	}
+	if blockCache.Misses()-misses != 1 || blockCache.Hits()-hits != 2 {
+		t.Errorf("hits = %d, misses = %d, want 2, 1", blockCache.Hits()-hits, blockCache.Misses()-misses)
+	}
+}
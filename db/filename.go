@@ -0,0 +1,67 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/goleveldb/goleveldb/version"
+)
+
+// fileType 描述 DB 目录下文件的类型.
+type fileType int
+
+const (
+	fileTypeLog fileType = iota
+	fileTypeTable
+	fileTypeManifest
+	fileTypeCurrent
+	fileTypeTemp
+)
+
+const (
+	logFileSuffix   = ".log"
+	tableFileSuffix = ".ldb"
+)
+
+// logFileName 返回编号为 number 的日志文件路径.
+func logFileName(dir string, number uint64) string {
+	return makeFileName(dir, number, logFileSuffix)
+}
+
+// tableFileName 返回编号为 number 的 table 文件路径.
+func tableFileName(dir string, number uint64) string {
+	return makeFileName(dir, number, tableFileSuffix)
+}
+
+func makeFileName(dir string, number uint64, suffix string) string {
+	return filepath.Join(dir, fmt.Sprintf("%06d%s", number, suffix))
+}
+
+// parseFileName 解析 DB 目录下的文件名, 返回文件编号与类型, 无法识别时 ok 为 false.
+// CURRENT 文件没有编号, 返回的编号为 0.
+func parseFileName(name string) (number uint64, t fileType, ok bool) {
+	var numberStr string
+	switch {
+	case name == version.CurrentFile:
+		return 0, fileTypeCurrent, true
+	case strings.HasPrefix(name, version.ManifestFilePrefix):
+		numberStr, t = strings.TrimPrefix(name, version.ManifestFilePrefix), fileTypeManifest
+	case strings.HasSuffix(name, version.TempFileSuffix):
+		numberStr, t = strings.TrimSuffix(name, version.TempFileSuffix), fileTypeTemp
+	case strings.HasSuffix(name, logFileSuffix):
+		numberStr, t = strings.TrimSuffix(name, logFileSuffix), fileTypeLog
+	case strings.HasSuffix(name, tableFileSuffix):
+		numberStr, t = strings.TrimSuffix(name, tableFileSuffix), fileTypeTable
+	default:
+		return 0, 0, false
+	}
+
+	number, err := strconv.ParseUint(numberStr, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return number, t, true
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/goleveldb/goleveldb/version"
+)
+
+func TestParseFileName(t *testing.T) {
+	tests := []struct {
+		name       string
+		fileName   string
+		wantNumber uint64
+		wantType   fileType
+		wantOK     bool
+	}{
+		{"log", filepath.Base(logFileName("dir", 7)), 7, fileTypeLog, true},
+		{"table", filepath.Base(tableFileName("dir", 1234567)), 1234567, fileTypeTable, true},
+		{"manifest", filepath.Base(version.ManifestFileName("dir", 12)), 12, fileTypeManifest, true},
+		{"current", version.CurrentFile, 0, fileTypeCurrent, true},
+		{"temp", "000005.dbtmp", 5, fileTypeTemp, true},
+		{"unknown suffix", "000001.txt", 0, 0, false},
+		{"bad manifest number", "MANIFEST-foo", 0, 0, false},
+		{"bad number", "foo.log", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			number, ft, ok := parseFileName(tt.fileName)
+			if number != tt.wantNumber || ft != tt.wantType || ok != tt.wantOK {
+				t.Errorf("parseFileName(%s) = (%d, %d, %v), want (%d, %d, %v)",
+					tt.fileName, number, ft, ok, tt.wantNumber, tt.wantType, tt.wantOK)
+			}
+		})
+	}
+}
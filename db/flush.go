@@ -0,0 +1,127 @@
+package db
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/goleveldb/goleveldb/file"
+	"github.com/goleveldb/goleveldb/memtable"
+	"github.com/goleveldb/goleveldb/slice"
+	"github.com/goleveldb/goleveldb/table"
+	"github.com/goleveldb/goleveldb/version"
+)
+
+// flushMemtable 将只读内存表写入第 0 层的 table, 并记录到 MANIFEST 中. 调用时需持有 db.mu.
+func (db *DB) flushMemtable() error {
+	edit := &version.VersionEdit{}
+	if err := db.writeLevel0Table(db.imm, edit); err != nil {
+		return err
+	}
+
+	// 只读内存表的内容都在当前日志之前的日志中, 这些日志已不再需要.
+	edit.SetLogNumber(db.logNumber)
+	if err := db.versions.LogAndApply(edit); err != nil {
+		return err
+	}
+	db.setImm(nil)
+	db.deleteObsoleteFiles()
+
+	return nil
+}
+
+// setImm 设置只读内存表, 同时更新 hasImm. 调用时需持有 db.mu.
+func (db *DB) setImm(imm *memtable.Memtable) {
+	db.imm = imm
+	if imm != nil {
+		atomic.StoreInt32(&db.hasImm, 1)
+	} else {
+		atomic.StoreInt32(&db.hasImm, 0)
+	}
+}
+
+// writeLevel0Table 将内存表写入新的 table 文件, 并在 edit 中将其加入第 0 层.
+// 调用时需持有 db.mu, 写入 table 期间会释放 db.mu.
+func (db *DB) writeLevel0Table(mem *memtable.Memtable, edit *version.VersionEdit) error {
+	number := db.versions.NewFileNumber()
+	db.pendingOutputs[number] = struct{}{}
+	defer delete(db.pendingOutputs, number)
+
+	db.mu.Unlock()
+	meta, err := db.buildTableFile(number, func(tableWriter table.Writer, meta *version.FileMetaData) (int, error) {
+		return buildTable(mem, tableWriter, meta)
+	})
+	db.mu.Lock()
+	if err != nil || meta == nil {
+		return err
+	}
+
+	edit.AddFile(0, meta)
+
+	return nil
+}
+
+// buildTableFile 使用 build 写入编号为 number 的 table 文件并同步到磁盘, 返回文件信息.
+// build 返回写入的条目数, 没有写入任何记录时不创建文件, 返回 nil.
+func (db *DB) buildTableFile(number uint64, build func(table.Writer, *version.FileMetaData) (int, error)) (*version.FileMetaData, error) {
+	fileName := tableFileName(db.dir, number)
+	fileWriter, err := file.NewWriter(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &version.FileMetaData{Number: number, CreationTime: time.Now().Unix()}
+	entries, err := build(table.NewWriter(fileWriter, db.opts.tableOptions()), meta)
+	if err == nil {
+		err = fileWriter.Sync()
+	}
+	if closeErr := fileWriter.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil || entries == 0 {
+		os.Remove(fileName)
+		return nil, err
+	}
+
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	// 确认 table 可以正常打开.
+	meta.Size = uint64(info.Size())
+	h, err := db.tableCache.findTable(meta)
+	if err != nil {
+		return nil, err
+	}
+	db.tableCache.release(h)
+
+	return meta, nil
+}
+
+// buildTable 将内存表中的所有记录按顺序写入 tableWriter, 返回写入的条目数, 写入的 key 范围记录在 meta 中.
+// 同一个 key 的旧版本与删除标记也会写入, 快照可能仍需读取它们, 压缩时再根据快照丢弃.
+func buildTable(mem *memtable.Memtable, tableWriter table.Writer, meta *version.FileMetaData) (entries int, err error) {
+	var lastKey slice.Slice
+	for it := mem.Iterator(); it.Valid(); it.Next() {
+		record, err := it.Key()
+		if err != nil {
+			return 0, err
+		}
+
+		key, value := memtable.ParseRecord(record)
+		if err := tableWriter.Add(slice.Slice(key), value); err != nil {
+			return 0, err
+		}
+		if entries == 0 {
+			meta.Smallest = append(slice.Slice(nil), key...)
+		}
+		lastKey = slice.Slice(key)
+		entries++
+	}
+
+	meta.Largest = append(slice.Slice(nil), lastKey...)
+
+	return entries, tableWriter.Finish()
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDB_Flush(t *testing.T) {
+	dir := t.TempDir()
+	// 不触发压缩, 所有 table 都留在第 0 层.
+	opts := &Options{CreateIfMissing: true, WriteBufferSize: 4096, L0CompactionTrigger: 1000}
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value := strings.Repeat("v", 100)
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("key_%05d", i)
+		runDBOperations(t, db, []*dbOperation{
+			{name: "put " + key, method: methodPut, key: key, value: value},
+		})
+	}
+	// 内存表、只读内存表与 table 中的数据都可以读取.
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("key_%05d", i)
+		runDBOperations(t, db, []*dbOperation{
+			{name: "get " + key, method: methodGet, key: key, value: value},
+		})
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 关闭时等待后台刷盘完成, 已刷盘的日志均被删除.
+	if db.imm != nil || db.bgScheduled {
+		t.Error("Close() should wait for the background flush")
+	}
+	if db.versions.Current().NumFiles(0) < 10 {
+		t.Errorf("level 0 files = %d, want >= 10", db.versions.Current().NumFiles(0))
+	}
+	if got := countFiles(t, dir, fileTypeTable); got != db.versions.Current().NumFiles(0) {
+		t.Errorf("table files = %d, want %d", got, db.versions.Current().NumFiles(0))
+	}
+	if got := countFiles(t, dir, fileTypeLog); got > 2 {
+		t.Errorf("log files = %d, want <= 2", got)
+	}
+
+	db, err = Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("key_%05d", i)
+		runDBOperations(t, db, []*dbOperation{
+			{name: "get " + key, method: methodGet, key: key, value: value},
+		})
+	}
+}
+
+func TestDB_ConcurrentFlush(t *testing.T) {
+	db, err := Open(t.TempDir(), &Options{CreateIfMissing: true, WriteBufferSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	const (
+		writers = 4
+		keys    = 300
+	)
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < keys; i++ {
+				key := fmt.Sprintf("key_%d_%03d", w, i)
+				runDBOperations(t, db, []*dbOperation{
+					{name: "put " + key, method: methodPut, key: key, value: key},
+					{name: "get " + key, method: methodGet, key: key, value: key},
+				})
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for w := 0; w < writers; w++ {
+		for i := 0; i < keys; i++ {
+			key := fmt.Sprintf("key_%d_%03d", w, i)
+			runDBOperations(t, db, []*dbOperation{
+				{name: "get " + key, method: methodGet, key: key, value: key},
+			})
+		}
+	}
+}
+
+// flushFiller 写入足够多的数据, 使之前的写入所在的内存表被写入 table.
+func flushFiller(t *testing.T, db *DB, prefix string) {
+	t.Helper()
+
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("%s_%02d", prefix, i)
+		runDBOperations(t, db, []*dbOperation{
+			{name: "put " + key, method: methodPut, key: key, value: strings.Repeat("f", 100)},
+		})
+	}
+	waitForBackgroundWork(db)
+}
+
+func TestDB_FlushKeepsDeletions(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{CreateIfMissing: true, WriteBufferSize: 1024, L0CompactionTrigger: 1000}
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 删除标记与旧的 value 分别在不同的 table 中, 删除标记覆盖旧的 value.
+	runDBOperations(t, db, []*dbOperation{{name: "put k", method: methodPut, key: "k", value: "v"}})
+	flushFiller(t, db, "a")
+	runDBOperations(t, db, []*dbOperation{{name: "delete k", method: methodDelete, key: "k"}})
+	flushFiller(t, db, "b")
+	if got := countVersions(t, db, "k"); got != 2 {
+		t.Errorf("versions of k in tables = %d, want 2", got)
+	}
+	runDBOperations(t, db, []*dbOperation{{name: "get deleted k", method: methodGet, key: "k", wantErr: ErrNotFound}})
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	runDBOperations(t, db, []*dbOperation{{name: "get deleted k after reopen", method: methodGet, key: "k", wantErr: ErrNotFound}})
+}
@@ -0,0 +1,413 @@
+package db
+
+import (
+	"sort"
+
+	"github.com/goleveldb/goleveldb/common"
+	"github.com/goleveldb/goleveldb/comparator"
+	"github.com/goleveldb/goleveldb/ikey"
+	"github.com/goleveldb/goleveldb/slice"
+	"github.com/goleveldb/goleveldb/table"
+	"github.com/goleveldb/goleveldb/version"
+)
+
+// NewIterator 返回按 user key 顺序遍历 DB 的迭代器, 使用完毕后需调用 Release 释放.
+// 指定 opts.Snapshot 时遍历创建快照时的数据, 否则遍历创建迭代器时的数据, 之后的写入对迭代器不可见.
+// 迭代器不是并发安全的, 但多个迭代器可以与其他读写操作并发使用.
+func (db *DB) NewIterator(opts *ReadOptions) (common.Iterator, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return nil, ErrClosed
+	}
+
+	sequence := db.versions.LastSequence()
+	if opts != nil && opts.Snapshot != nil {
+		sequence = opts.Snapshot.sequence
+	}
+
+	current := db.versions.Current()
+	children := []common.Iterator{db.mem.NewIterator()}
+	if db.imm != nil {
+		children = append(children, db.imm.NewIterator())
+	}
+
+	// 第 0 层的文件之间可能重叠, 每个文件使用单独的迭代器; 其余各层的文件按顺序依次遍历.
+	tableReadOpts := opts.tableReadOptions()
+	for _, f := range current.Files(0) {
+		it, err := db.tableCache.newIterator(f, tableReadOpts)
+		if err != nil {
+			for _, child := range children {
+				child.Release()
+			}
+			return nil, err
+		}
+		children = append(children, it)
+	}
+	icmp := ikey.NewComparator(db.opts.Comparator)
+	for level := 1; level < version.NumLevels; level++ {
+		if current.NumFiles(level) > 0 {
+			children = append(children, newLevelIterator(db.tableCache, icmp, current.Files(level), tableReadOpts))
+		}
+	}
+
+	db.versions.Ref(current)
+	release := func() {
+		db.mu.Lock()
+		db.versions.Unref(current)
+		db.mu.Unlock()
+	}
+
+	return newDBIterator(common.NewMergingIterator(icmp, children...), db.opts.Comparator, sequence, release), nil
+}
+
+// levelIterator 按顺序遍历第 0 层之外某一层的文件, 只在遍历到某个文件时才打开其 table.
+type levelIterator struct {
+	tableCache *tableCache
+	icmp       *ikey.Comparator
+	files      []*version.FileMetaData
+	ropts      *table.ReadOptions
+
+	// 当前文件的下标及其迭代器, 下标超出范围或打开失败时 cur 为 nil.
+	index int
+	cur   common.Iterator
+	err   error
+}
+
+var _ common.Iterator = (*levelIterator)(nil)
+
+func newLevelIterator(c *tableCache, icmp *ikey.Comparator, files []*version.FileMetaData, ropts *table.ReadOptions) *levelIterator {
+	return &levelIterator{tableCache: c, icmp: icmp, files: files, ropts: ropts, index: -1}
+}
+
+func (i *levelIterator) Success() bool {
+	return i.cur != nil && i.cur.Success()
+}
+
+func (i *levelIterator) Next() {
+	if !i.Success() {
+		return
+	}
+	i.cur.Next()
+	i.skipEmptyFilesForward()
+}
+
+func (i *levelIterator) Prev() {
+	if !i.Success() {
+		return
+	}
+	i.cur.Prev()
+	i.skipEmptyFilesBackward()
+}
+
+// Find 在最大 key 不小于 key 的第一个文件中查找.
+func (i *levelIterator) Find(key slice.Slice) {
+	index := sort.Search(len(i.files), func(n int) bool { return i.icmp.Compare(i.files[n].Largest, key) >= 0 })
+	i.setFile(index)
+	if i.cur != nil {
+		i.cur.Find(key)
+	}
+	i.skipEmptyFilesForward()
+}
+
+func (i *levelIterator) SeekToFirst() {
+	i.setFile(0)
+	if i.cur != nil {
+		i.cur.SeekToFirst()
+	}
+	i.skipEmptyFilesForward()
+}
+
+func (i *levelIterator) SeekToLast() {
+	i.setFile(len(i.files) - 1)
+	if i.cur != nil {
+		i.cur.SeekToLast()
+	}
+	i.skipEmptyFilesBackward()
+}
+
+func (i *levelIterator) Key() slice.Slice {
+	return i.cur.Key()
+}
+
+func (i *levelIterator) Value() slice.Slice {
+	return i.cur.Value()
+}
+
+func (i *levelIterator) Err() error {
+	if i.err != nil {
+		return i.err
+	}
+	if i.cur != nil {
+		return i.cur.Err()
+	}
+
+	return nil
+}
+
+func (i *levelIterator) Release() {
+	i.setFile(-1)
+}
+
+// skipEmptyFilesForward 当前文件遍历完毕时, 移动到之后第一个非空文件的开头.
+func (i *levelIterator) skipEmptyFilesForward() {
+	for !i.Success() && i.index < len(i.files) {
+		i.setFile(i.index + 1)
+		if i.cur != nil {
+			i.cur.SeekToFirst()
+		}
+	}
+}
+
+// skipEmptyFilesBackward 当前文件遍历完毕时, 移动到之前第一个非空文件的末尾.
+func (i *levelIterator) skipEmptyFilesBackward() {
+	for !i.Success() && i.index >= 0 {
+		i.setFile(i.index - 1)
+		if i.cur != nil {
+			i.cur.SeekToLast()
+		}
+	}
+}
+
+// setFile 切换到下标为 index 的文件, 记录打开文件或遍历时遇到的第一个错误.
+func (i *levelIterator) setFile(index int) {
+	if i.cur != nil && index == i.index {
+		return
+	}
+	if i.cur != nil {
+		if err := i.cur.Err(); err != nil && i.err == nil {
+			i.err = err
+		}
+		i.cur.Release()
+		i.cur = nil
+	}
+
+	i.index = index
+	if index < 0 || index >= len(i.files) {
+		return
+	}
+	cur, err := i.tableCache.newIterator(i.files[index], i.ropts)
+	if err != nil {
+		if i.err == nil {
+			i.err = err
+		}
+		return
+	}
+	i.cur = cur
+}
+
+// direction 迭代器的移动方向.
+type direction int
+
+const (
+	forward direction = iota
+	reverse
+)
+
+// dbIterator 将按 internal key 排列的记录转换为 user key 的视图:
+// 忽略序列号大于 sequence 的记录, 每个 user key 只返回不大于 sequence 的最新版本,
+// 最新版本为删除标记的 key 被跳过.
+//
+// 正向移动时, iter 指向当前 user key 的最新版本;
+// 反向移动时, iter 指向当前 user key 之前的记录, 当前 key 与 value 保存在 savedKey 与 savedValue 中.
+type dbIterator struct {
+	iter     common.Iterator
+	ucmp     comparator.Comparator
+	sequence uint64
+	release  func()
+
+	direction  direction
+	valid      bool
+	savedKey   slice.Slice
+	savedValue slice.Slice
+	err        error
+}
+
+var _ common.Iterator = (*dbIterator)(nil)
+
+func newDBIterator(iter common.Iterator, ucmp comparator.Comparator, sequence uint64, release func()) *dbIterator {
+	return &dbIterator{iter: iter, ucmp: ucmp, sequence: sequence, release: release}
+}
+
+func (i *dbIterator) Success() bool {
+	return i.valid
+}
+
+func (i *dbIterator) Key() slice.Slice {
+	if i.direction == forward {
+		return ikey.InternalKey(i.iter.Key()).UserKey()
+	}
+
+	return i.savedKey
+}
+
+func (i *dbIterator) Value() slice.Slice {
+	if i.direction == forward {
+		return i.iter.Value()
+	}
+
+	return i.savedValue
+}
+
+func (i *dbIterator) Err() error {
+	if i.err != nil {
+		return i.err
+	}
+
+	return i.iter.Err()
+}
+
+func (i *dbIterator) Release() {
+	i.iter.Release()
+	if i.release != nil {
+		i.release()
+		i.release = nil
+	}
+}
+
+// parseKey 解析 iter 当前的 internal key, 无法解析的 key 被跳过, 并记录错误.
+func (i *dbIterator) parseKey() (ikey.ParsedInternalKey, bool) {
+	parsed, err := ikey.ParseInternalKey(i.iter.Key())
+	if err != nil {
+		if i.err == nil {
+			i.err = err
+		}
+		return parsed, false
+	}
+
+	return parsed, true
+}
+
+func (i *dbIterator) Next() {
+	if !i.valid {
+		return
+	}
+
+	if i.direction == reverse {
+		// iter 在当前 key 的所有记录之前, 移动到当前 key 的记录中, 之后跳过 savedKey 的所有记录.
+		i.direction = forward
+		if i.iter.Success() {
+			i.iter.Next()
+		} else {
+			i.iter.SeekToFirst()
+		}
+	} else {
+		i.savedKey = append(i.savedKey[:0], ikey.InternalKey(i.iter.Key()).UserKey()...)
+		i.iter.Next()
+	}
+	if !i.iter.Success() {
+		i.valid = false
+		i.savedKey = i.savedKey[:0]
+		return
+	}
+
+	i.findNextUserEntry(true)
+}
+
+// findNextUserEntry 从 iter 开始向后查找第一个未被删除的 user key 的最新可见版本.
+// skipping 为 true 时, 跳过 user key 不大于 savedKey 的记录.
+func (i *dbIterator) findNextUserEntry(skipping bool) {
+	for ; i.iter.Success(); i.iter.Next() {
+		parsed, ok := i.parseKey()
+		if !ok || parsed.Sequence > i.sequence {
+			continue
+		}
+
+		switch parsed.ValueType {
+		case ikey.TypeDeletion:
+			// 跳过该 key 之后更旧的版本.
+			i.savedKey = append(i.savedKey[:0], parsed.UserKey...)
+			skipping = true
+		case ikey.TypeValue:
+			if skipping && i.ucmp.Compare(parsed.UserKey, i.savedKey) <= 0 {
+				continue
+			}
+			i.valid = true
+			i.savedKey = i.savedKey[:0]
+			return
+		}
+	}
+
+	i.valid = false
+	i.savedKey = i.savedKey[:0]
+}
+
+func (i *dbIterator) Prev() {
+	if !i.valid {
+		return
+	}
+
+	if i.direction == forward {
+		// iter 指向当前 key, 向前移动到 user key 更小的记录.
+		i.savedKey = append(i.savedKey[:0], ikey.InternalKey(i.iter.Key()).UserKey()...)
+		for {
+			i.iter.Prev()
+			if !i.iter.Success() {
+				i.valid = false
+				i.savedKey, i.savedValue = i.savedKey[:0], nil
+				return
+			}
+			if i.ucmp.Compare(ikey.InternalKey(i.iter.Key()).UserKey(), i.savedKey) < 0 {
+				break
+			}
+		}
+		i.direction = reverse
+	}
+
+	i.findPrevUserEntry()
+}
+
+// findPrevUserEntry 从 iter 开始向前查找第一个未被删除的 user key 的最新可见版本,
+// 保存在 savedKey 与 savedValue 中.
+// 同一个 user key 的记录从旧到新遍历, 遇到更小的 user key 时 savedKey 即为结果.
+func (i *dbIterator) findPrevUserEntry() {
+	valueType := ikey.TypeDeletion
+	for ; i.iter.Success(); i.iter.Prev() {
+		parsed, ok := i.parseKey()
+		if !ok || parsed.Sequence > i.sequence {
+			continue
+		}
+		if valueType != ikey.TypeDeletion && i.ucmp.Compare(parsed.UserKey, i.savedKey) < 0 {
+			break
+		}
+
+		valueType = parsed.ValueType
+		if valueType == ikey.TypeDeletion {
+			i.savedKey, i.savedValue = i.savedKey[:0], nil
+		} else {
+			i.savedKey = append(i.savedKey[:0], parsed.UserKey...)
+			i.savedValue = append(i.savedValue[:0], i.iter.Value()...)
+		}
+	}
+
+	if valueType == ikey.TypeDeletion {
+		i.valid = false
+		i.savedKey, i.savedValue = i.savedKey[:0], nil
+		i.direction = forward
+		return
+	}
+	i.valid = true
+}
+
+// Find 移动到第一个不小于 key 的 user key.
+func (i *dbIterator) Find(key slice.Slice) {
+	i.direction = forward
+	i.savedValue = nil
+	i.iter.Find(slice.Slice(ikey.MakeLookupKey(key, i.sequence).InternalKey()))
+	i.findNextUserEntry(false)
+}
+
+func (i *dbIterator) SeekToFirst() {
+	i.direction = forward
+	i.savedValue = nil
+	i.iter.SeekToFirst()
+	i.findNextUserEntry(false)
+}
+
+func (i *dbIterator) SeekToLast() {
+	i.direction = reverse
+	i.savedValue = nil
+	i.iter.SeekToLast()
+	i.findPrevUserEntry()
+}
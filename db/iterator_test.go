@@ -0,0 +1,190 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/goleveldb/goleveldb/common"
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+// iteratorModel 是 DB 在某一时刻的内容, 用于检查迭代器的结果.
+type iteratorModel map[string]string
+
+func (m iteratorModel) clone() iteratorModel {
+	res := make(iteratorModel, len(m))
+	for k, v := range m {
+		res[k] = v
+	}
+
+	return res
+}
+
+func (m iteratorModel) keys() []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// checkIterator 检查 it 的正向遍历, 反向遍历, 查找与改变方向的结果与 want 一致.
+func checkIterator(t *testing.T, it common.Iterator, want iteratorModel) {
+	t.Helper()
+
+	keys := want.keys()
+	entry := func() string { return fmt.Sprintf("%s=%s", it.Key(), it.Value()) }
+	var wantEntries []string
+	for _, k := range keys {
+		wantEntries = append(wantEntries, fmt.Sprintf("%s=%s", k, want[k]))
+	}
+
+	var got []string
+	for it.SeekToFirst(); it.Success(); it.Next() {
+		got = append(got, entry())
+	}
+	if fmt.Sprint(got) != fmt.Sprint(wantEntries) {
+		t.Errorf("forward = %v, want %v", got, wantEntries)
+	}
+
+	got = got[:0]
+	for it.SeekToLast(); it.Success(); it.Prev() {
+		got = append([]string{entry()}, got...)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(wantEntries) {
+		t.Errorf("backward = %v, want %v", got, wantEntries)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for n := 0; n < 100; n++ {
+		target := fmt.Sprintf("key_%03d", rnd.Intn(120))
+		i := sort.SearchStrings(keys, target)
+		it.Find(slice.Slice(target))
+		if i == len(keys) {
+			if it.Success() {
+				t.Errorf("Find(%s) = %s, want invalid", target, entry())
+			}
+			continue
+		}
+		if !it.Success() || entry() != wantEntries[i] {
+			t.Fatalf("Find(%s) = %v, want %s", target, it.Success(), wantEntries[i])
+		}
+
+		// 改变方向后仍能得到相邻的 key.
+		it.Prev()
+		if i == 0 {
+			if it.Success() {
+				t.Errorf("Find(%s).Prev() = %s, want invalid", target, entry())
+			}
+			continue
+		}
+		if !it.Success() || entry() != wantEntries[i-1] {
+			t.Fatalf("Find(%s).Prev() = %v, want %s", target, it.Success(), wantEntries[i-1])
+		}
+		it.Next()
+		if !it.Success() || entry() != wantEntries[i] {
+			t.Fatalf("Find(%s).Prev().Next() = %v, want %s", target, it.Success(), wantEntries[i])
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Errorf("Err() = %v", err)
+	}
+}
+
+func TestDB_Iterator(t *testing.T) {
+	db, err := Open(t.TempDir(), &Options{
+		CreateIfMissing:      true,
+		WriteBufferSize:      2048,
+		L0CompactionTrigger:  2,
+		MaxBytesForLevelBase: 8 * 1024,
+		TargetFileSize:       2048,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	it, err := db.NewIterator(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIterator(t, it, iteratorModel{})
+	it.Release()
+
+	// 随机写入与删除, 数据分布在内存表与各层的 table 中.
+	model := iteratorModel{}
+	var (
+		snapshots []*Snapshot
+		models    []iteratorModel
+	)
+	rnd := rand.New(rand.NewSource(1))
+	for round := 0; round < 4; round++ {
+		for n := 0; n < 300; n++ {
+			key := fmt.Sprintf("key_%03d", rnd.Intn(100))
+			if rnd.Intn(4) == 0 {
+				runDBOperations(t, db, []*dbOperation{{name: "delete " + key, method: methodDelete, key: key}})
+				delete(model, key)
+				continue
+			}
+			value := fmt.Sprintf("value_%d_%d_%040d", round, n, 0)
+			runDBOperations(t, db, []*dbOperation{{name: "put " + key, method: methodPut, key: key, value: value}})
+			model[key] = value
+		}
+		snapshots = append(snapshots, db.GetSnapshot())
+		models = append(models, model.clone())
+	}
+	waitForBackgroundWork(db)
+	if countLiveFiles(db) == db.versions.Current().NumFiles(0) {
+		t.Fatalf("want files below level 0, level 0 files = %d", db.versions.Current().NumFiles(0))
+	}
+
+	// 迭代器创建后的写入不可见.
+	it, err = db.NewIterator(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runDBOperations(t, db, []*dbOperation{
+		{name: "put new key", method: methodPut, key: "key_000_new", value: "new"},
+		{name: "delete key_001", method: methodDelete, key: "key_001"},
+	})
+	checkIterator(t, it, model)
+	it.Release()
+
+	// 刷盘与压缩之后, 快照仍能遍历创建快照时的数据.
+	putOverwrites(t, db, 200, 2)
+	waitForBackgroundWork(db)
+	for i, s := range snapshots {
+		it, err := db.NewIterator(&ReadOptions{Snapshot: s})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkIterator(t, it, models[i])
+		it.Release()
+		db.ReleaseSnapshot(s)
+	}
+
+	// 迭代器释放后, 旧 Version 中的文件不再被视为在使用.
+	waitForBackgroundWork(db)
+	live := make(map[uint64]struct{})
+	db.mu.Lock()
+	db.versions.AddLiveFiles(live)
+	db.mu.Unlock()
+	if len(live) != countLiveFiles(db) {
+		t.Errorf("live files = %d, want %d", len(live), countLiveFiles(db))
+	}
+}
+
+func TestDB_IteratorClosed(t *testing.T) {
+	db := openTestDB(t, t.TempDir())
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.NewIterator(nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("NewIterator() => want err = %v, get err = %v", ErrClosed, err)
+	}
+}
@@ -0,0 +1,184 @@
+package db
+
+import (
+	"math"
+
+	"github.com/goleveldb/goleveldb/cache"
+	"github.com/goleveldb/goleveldb/comparator"
+	"github.com/goleveldb/goleveldb/compress"
+	"github.com/goleveldb/goleveldb/filter"
+	"github.com/goleveldb/goleveldb/ikey"
+	"github.com/goleveldb/goleveldb/table"
+	"github.com/goleveldb/goleveldb/version"
+)
+
+const (
+	// defaultWriteBufferSize 默认内存表大小上限.
+	defaultWriteBufferSize = 4 * 1024 * 1024
+	// defaultBlockCacheSize 默认数据块缓存大小.
+	defaultBlockCacheSize = 8 * 1024 * 1024
+	// defaultMaxOpenFiles 默认最多同时打开的文件数.
+	defaultMaxOpenFiles = 1000
+	// defaultL0CompactionTrigger 默认触发第 0 层压缩的文件数.
+	defaultL0CompactionTrigger = 4
+)
+
+// Options 控制 DB 的行为, 在 Open 时指定.
+type Options struct {
+	// Comparator 定义 key 的顺序, 为 nil 时使用 comparator.Bytewise.
+	// 打开已有的 DB 时必须使用与创建时相同的比较器.
+	Comparator comparator.Comparator
+	// CreateIfMissing 为 true 时, 若 DB 目录不存在则创建.
+	CreateIfMissing bool
+	// ErrorIfExists 为 true 时, 若 DB 已存在则返回错误.
+	ErrorIfExists bool
+	// ParanoidChecks 为 true 时, 恢复日志过程中发现任何损坏都会导致 Open 失败;
+	// 否则损坏的记录会被跳过.
+	ParanoidChecks bool
+	// FilterPolicy 为 nil 时 table 中不生成 filter block, 否则每次读取 table 前先通过 filter 判断 key 是否可能存在.
+	// 打开已有的 DB 时应当使用与创建时相同的 FilterPolicy, 否则已有 table 的 filter 不会生效.
+	FilterPolicy filter.FilterPolicy
+	// Compression 新写入的 table 中数据块的压缩方式, 默认不压缩.
+	// 读取时根据每个块记录的压缩类型解压, 因此修改该选项后已有的 table 仍可读取.
+	// LevelDB 不支持 compress.FlateCompression, 需要与 LevelDB 互通时不应使用.
+	Compression compress.Type
+	// TableFormat 新写入的 table 文件的格式, 默认为 TableFormatGoLevelDB.
+	// 读取时根据 table 的 footer 识别格式, 因此修改该选项后已有的 table 仍可读取.
+	TableFormat TableFormat
+	// WriteBufferSize 内存表占用内存的上限(字节), 超过后内存表会被写入 table 文件, 为 0 时使用默认值.
+	WriteBufferSize int
+	// BlockCache 缓存从 table 中读取的数据块, 按块的字节数计算容量, 为 nil 时使用 8MB 的 LRU 缓存.
+	// 多个 DB 可以共用同一个缓存.
+	BlockCache cache.Cache
+	// MaxOpenFiles DB 最多同时打开的文件数, 其中 10 个预留给日志等文件, 其余用于缓存打开的 table.
+	// 为 0 时使用默认值 1000, 小于 11 时按 11 处理.
+	MaxOpenFiles int
+	// L0CompactionTrigger 第 0 层的文件数达到该值时开始压缩, 达到该值的 3 倍时写操作等待压缩完成.
+	// 为 0 时使用默认值 4.
+	L0CompactionTrigger int
+	// MaxBytesForLevelBase 第 1 层的大小上限(字节), 之后每一层是上一层的 10 倍,
+	// 超过上限的层会被压缩到下一层. 为 0 时使用默认值 10MB.
+	MaxBytesForLevelBase int
+	// TargetFileSize 压缩生成的 table 文件大小(字节), 为 0 时使用默认值 2MB.
+	TargetFileSize int
+	// CompactionStyle 压缩策略, 默认为 CompactionStyleLeveled.
+	// 使用 CompactionStyleUniversal 时, sorted run 的数量达到 L0CompactionTrigger 时开始压缩.
+	CompactionStyle CompactionStyle
+	// Universal 使用 CompactionStyleUniversal 时的配置.
+	Universal UniversalOptions
+	// FIFO 使用 CompactionStyleFIFO 时的配置. 过期的 table 在之后的刷盘或重新打开 DB 时删除.
+	FIFO FIFOOptions
+}
+
+// TableFormat table 文件的格式.
+type TableFormat = table.Format
+
+const (
+	// TableFormatGoLevelDB 默认的 table 格式.
+	TableFormatGoLevelDB = table.FormatGoLevelDB
+	// TableFormatLevelDB 与 C++ LevelDB 相同的 table 格式, 可以与 LevelDB 互相读取.
+	TableFormatLevelDB = table.FormatLevelDB
+)
+
+// CompactionStyle 压缩策略.
+type CompactionStyle = version.CompactionStyle
+
+const (
+	// CompactionStyleLeveled 分层压缩, 读放大与空间放大较小.
+	CompactionStyleLeveled = version.CompactionStyleLeveled
+	// CompactionStyleUniversal 分级(size-tiered)压缩, 写放大较小.
+	CompactionStyleUniversal = version.CompactionStyleUniversal
+	// CompactionStyleFIFO 先进先出, 删除最旧的 table 而不重写数据.
+	CompactionStyleFIFO = version.CompactionStyleFIFO
+)
+
+// UniversalOptions 控制 CompactionStyleUniversal 的行为.
+type UniversalOptions = version.UniversalOptions
+
+// FIFOOptions 控制 CompactionStyleFIFO 的行为.
+type FIFOOptions = version.FIFOOptions
+
+// ReadOptions 控制读操作的行为.
+type ReadOptions struct {
+	// Snapshot 不为 nil 时, 读取该快照创建时的数据; 否则读取最新的数据.
+	Snapshot *Snapshot
+	// DontFillCache 为 true 时, 本次读取的数据块不会加入块缓存, 大范围扫描时使用以免淘汰常用的数据块.
+	DontFillCache bool
+}
+
+// WriteOptions 控制写操作的行为.
+type WriteOptions struct {
+	// Sync 为 true 时, 写操作在返回前会将日志同步到磁盘.
+	Sync bool
+}
+
+// tableOptions 返回读写 table 文件使用的配置, table 中的 key 为 internal key.
+func (o *Options) tableOptions() *table.Options {
+	return &table.Options{
+		Comparator:   ikey.NewComparator(o.Comparator),
+		FilterPolicy: ikey.NewFilterPolicy(o.FilterPolicy),
+		Compression:  o.Compression,
+		Format:       o.TableFormat,
+		BlockCache:   o.BlockCache,
+	}
+}
+
+// versionOptions 返回 VersionSet 使用的配置.
+func (o *Options) versionOptions() *version.Options {
+	return &version.Options{
+		Comparator:           o.Comparator,
+		L0CompactionTrigger:  o.L0CompactionTrigger,
+		MaxBytesForLevelBase: uint64(o.MaxBytesForLevelBase),
+		TargetFileSize:       uint64(o.TargetFileSize),
+		CompactionStyle:      o.CompactionStyle,
+		Universal:            o.Universal,
+		FIFO:                 o.FIFO,
+	}
+}
+
+// l0StopWritesTrigger 第 0 层的文件数达到该值时, 写操作等待压缩完成.
+// CompactionStyleFIFO 的所有 table 都在第 0 层, 写操作不需要等待.
+func (o *Options) l0StopWritesTrigger() int {
+	if o.CompactionStyle == CompactionStyleFIFO {
+		return math.MaxInt32
+	}
+
+	return 3 * o.L0CompactionTrigger
+}
+
+// sanitize 返回填充了默认值的 Options 副本.
+func (o *Options) sanitize() *Options {
+	res := Options{}
+	if o != nil {
+		res = *o
+	}
+
+	if res.Comparator == nil {
+		res.Comparator = comparator.Bytewise
+	}
+	if res.WriteBufferSize <= 0 {
+		res.WriteBufferSize = defaultWriteBufferSize
+	}
+	if res.MaxOpenFiles <= 0 {
+		res.MaxOpenFiles = defaultMaxOpenFiles
+	} else if res.MaxOpenFiles <= numNonTableCacheFiles {
+		res.MaxOpenFiles = numNonTableCacheFiles + 1
+	}
+	if res.L0CompactionTrigger <= 0 {
+		res.L0CompactionTrigger = defaultL0CompactionTrigger
+	}
+	if res.BlockCache == nil {
+		res.BlockCache = cache.NewLRUCache(defaultBlockCacheSize)
+	}
+
+	return &res
+}
+
+// tableReadOptions 返回读取 table 使用的配置.
+func (o *ReadOptions) tableReadOptions() *table.ReadOptions {
+	if o == nil {
+		return nil
+	}
+
+	return &table.ReadOptions{DontFillCache: o.DontFillCache}
+}
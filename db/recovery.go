@@ -0,0 +1,228 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/goleveldb/goleveldb/file"
+	"github.com/goleveldb/goleveldb/log"
+	"github.com/goleveldb/goleveldb/memtable"
+	"github.com/goleveldb/goleveldb/version"
+)
+
+// logReporter 收集恢复日志过程中发现的损坏, 由它决定损坏是否导致恢复失败.
+type logReporter struct {
+	fileName string
+	// paranoid 为 true 时, 任何损坏都会导致恢复失败.
+	paranoid bool
+	// err 第一个导致恢复失败的损坏.
+	err error
+}
+
+// Corruption 记录损坏, 非 paranoid 模式下损坏被忽略.
+func (r *logReporter) Corruption(bytes int, err error) {
+	if r.paranoid && r.err == nil {
+		r.err = fmt.Errorf("%s: %d bytes dropped: %w", r.fileName, bytes, err)
+	}
+}
+
+// recover 从 MANIFEST 恢复已刷盘的 table, 重放内容尚未写入 table 的日志, 并创建新的日志文件.
+// 目录下没有 CURRENT 文件时创建新的 MANIFEST;
+// 此时若目录下已有 table 文件, 无法得知它们所在的层与序列号, 返回 ErrCurrentMissing.
+func (db *DB) recover() error {
+	infos, err := ioutil.ReadDir(db.dir)
+	if err != nil {
+		return err
+	}
+
+	var (
+		hasCurrent                        bool
+		numbers, logNumbers, tableNumbers []uint64
+	)
+	for _, info := range infos {
+		number, t, ok := parseFileName(info.Name())
+		if !ok {
+			continue
+		}
+
+		numbers = append(numbers, number)
+		switch t {
+		case fileTypeCurrent:
+			hasCurrent = true
+		case fileTypeLog:
+			logNumbers = append(logNumbers, number)
+		case fileTypeTable:
+			tableNumbers = append(tableNumbers, number)
+		}
+	}
+
+	switch {
+	case hasCurrent:
+		if err := db.versions.Recover(); err != nil {
+			return err
+		}
+	case len(tableNumbers) > 0:
+		return fmt.Errorf("%s: %d table files: %w", db.dir, len(tableNumbers), ErrCurrentMissing)
+	}
+	for _, number := range numbers {
+		db.versions.MarkFileNumberUsed(number)
+	}
+
+	// 编号小于 LogNumber 的日志内容均已写入 table, 无需重放.
+	var replayLogNumbers []uint64
+	for _, number := range logNumbers {
+		if number >= db.versions.LogNumber() {
+			replayLogNumbers = append(replayLogNumbers, number)
+		}
+	}
+	edit := &version.VersionEdit{}
+	memLogNumber, err := db.recoverLogs(replayLogNumbers, edit)
+	if err != nil {
+		return err
+	}
+
+	if err := db.newLog(); err != nil {
+		return err
+	}
+
+	// 内存表中的内容仍需从日志中恢复, 保留这些日志.
+	if memLogNumber == 0 {
+		memLogNumber = db.logNumber
+	}
+	edit.SetLogNumber(memLogNumber)
+	if err := db.versions.LogAndApply(edit); err != nil {
+		return err
+	}
+
+	db.deleteObsoleteFiles()
+
+	return nil
+}
+
+// recoverLogs 按编号从旧到新重放日志文件, 恢复内存表与最大序列号.
+// 重放过程中内存表超过 WriteBufferSize 时会被写入 table 文件, 并记录在 edit 中.
+// 返回内容仍在内存表中的最早的日志编号, 内存表为空时返回 0.
+func (db *DB) recoverLogs(logNumbers []uint64, edit *version.VersionEdit) (memLogNumber uint64, err error) {
+	sort.Slice(logNumbers, func(i, j int) bool { return logNumbers[i] < logNumbers[j] })
+
+	for _, number := range logNumbers {
+		number := number
+		if db.mem.ApproximateMemoryUsage() == 0 {
+			memLogNumber = number
+		}
+
+		err := db.replayLog(number, func() error {
+			if db.mem.ApproximateMemoryUsage() <= db.opts.WriteBufferSize {
+				return nil
+			}
+
+			if err := db.writeLevel0Table(db.mem, edit); err != nil {
+				return err
+			}
+			db.mem = memtable.New(db.opts.Comparator)
+			memLogNumber = number
+
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if db.mem.ApproximateMemoryUsage() == 0 {
+		return 0, nil
+	}
+
+	return memLogNumber, nil
+}
+
+// replayLog 将日志文件中的 WriteBatch 依次写入内存表, 每写入一个 WriteBatch 后调用 afterBatch.
+func (db *DB) replayLog(number uint64, afterBatch func() error) error {
+	fileName := logFileName(db.dir, number)
+	reader, err := file.NewSequentialReader(fileName)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	reporter := &logReporter{fileName: fileName, paranoid: db.opts.ParanoidChecks}
+	// 日志中损坏的块被跳过, 由 reporter 决定损坏是否导致恢复失败.
+	logReader := log.NewReader(reader, reporter, nil)
+	batch := NewWriteBatch()
+	for reporter.err == nil {
+		record, err := logReader.ReadRecord()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		// 读取文件出错时错误已报告给 reporter, 日志的剩余部分无法继续读取.
+		if err != nil {
+			break
+		}
+
+		if err := batch.setContents(record); err != nil {
+			reporter.Corruption(len(record), err)
+			continue
+		}
+		if err := batch.insertInto(db.mem); err != nil {
+			reporter.Corruption(len(record), err)
+			continue
+		}
+
+		if lastSequence := batch.sequence() + uint64(batch.Len()) - 1; lastSequence > db.versions.LastSequence() {
+			db.versions.SetLastSequence(lastSequence)
+		}
+
+		if err := afterBatch(); err != nil {
+			return err
+		}
+	}
+
+	return reporter.err
+}
+
+// deleteObsoleteFiles 删除不再需要的文件: 内容已写入 table 的日志, 旧的 MANIFEST,
+// 不属于当前 Version 或任何被引用的 Version 的 table 以及临时文件. 调用时需持有 db.mu.
+func (db *DB) deleteObsoleteFiles() {
+	live := make(map[uint64]struct{})
+	db.versions.AddLiveFiles(live)
+
+	infos, err := ioutil.ReadDir(db.dir)
+	if err != nil {
+		return
+	}
+	for _, info := range infos {
+		number, t, ok := parseFileName(info.Name())
+		if !ok {
+			continue
+		}
+
+		keep := true
+		switch t {
+		case fileTypeLog:
+			keep = number >= db.versions.LogNumber() || number == db.logNumber
+		case fileTypeManifest:
+			keep = number >= db.versions.ManifestFileNumber()
+		case fileTypeTable:
+			_, keep = live[number]
+			if _, pending := db.pendingOutputs[number]; pending {
+				keep = true
+			}
+		case fileTypeTemp:
+			keep = false
+		}
+		if keep {
+			continue
+		}
+
+		if t == fileTypeTable {
+			db.tableCache.evict(number)
+		}
+		// 删除失败的文件会在下次打开 DB 时再次尝试删除.
+		os.Remove(filepath.Join(db.dir, info.Name()))
+	}
+}
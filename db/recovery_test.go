@@ -0,0 +1,416 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/goleveldb/goleveldb/comparator"
+	"github.com/goleveldb/goleveldb/compress"
+	"github.com/goleveldb/goleveldb/filter"
+	"github.com/goleveldb/goleveldb/version"
+)
+
+func countFiles(t *testing.T, dir string, ft fileType) int {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	for _, entry := range entries {
+		if _, got, ok := parseFileName(entry.Name()); ok && got == ft {
+			count++
+		}
+	}
+
+	return count
+}
+
+func TestDB_Recover(t *testing.T) {
+	dir := t.TempDir()
+	db := openTestDB(t, dir)
+	runDBOperations(t, db, []*dbOperation{
+		{name: "put foo", method: methodPut, key: "foo", value: "bar"},
+		{name: "put box", method: methodPut, key: "box", value: "baz"},
+		{name: "delete box", method: methodDelete, key: "box"},
+	})
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db = openTestDB(t, dir)
+	if db.versions.LastSequence() != 3 {
+		t.Errorf("lastSequence = %d, want 3", db.versions.LastSequence())
+	}
+	runDBOperations(t, db, []*dbOperation{
+		{name: "get recovered foo", method: methodGet, key: "foo", value: "bar"},
+		{name: "get recovered deleted box", method: methodGet, key: "box", wantErr: ErrNotFound},
+		{name: "put after recover", method: methodPut, key: "foo", value: "new"},
+	})
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 两个日志文件都应当被重放, 且较新的日志覆盖较旧的日志.
+	db = openTestDB(t, dir)
+	defer db.Close()
+	if db.versions.LastSequence() != 4 {
+		t.Errorf("lastSequence = %d, want 4", db.versions.LastSequence())
+	}
+	runDBOperations(t, db, []*dbOperation{
+		{name: "get overwritten foo", method: methodGet, key: "foo", value: "new"},
+	})
+}
+
+func TestDB_RecoverFlush(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{CreateIfMissing: true, WriteBufferSize: 1024, FilterPolicy: filter.NewBloomFilterPolicy(10)}
+	for round := 0; round < 2; round++ {
+		db, err := Open(dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("key_%d_%03d", round, i)
+			runDBOperations(t, db, []*dbOperation{
+				{name: "put " + key, method: methodPut, key: key, value: strings.Repeat("v", 32)},
+			})
+		}
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if countFiles(t, dir, fileTypeTable) == 0 {
+		t.Error("memtable larger than WriteBufferSize should be flushed to table during recovery")
+	}
+	// 已写入 table 的旧日志应当被删除, 只剩下未写入 table 的日志及新日志.
+	if got := countFiles(t, dir, fileTypeLog); got > 2 {
+		t.Errorf("log files = %d, want <= 2", got)
+	}
+	if db.versions.LastSequence() != 200 {
+		t.Errorf("lastSequence = %d, want 200", db.versions.LastSequence())
+	}
+
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("key_%d_%03d", round, i)
+			runDBOperations(t, db, []*dbOperation{
+				{name: "get " + key, method: methodGet, key: key, value: strings.Repeat("v", 32)},
+			})
+		}
+	}
+}
+
+func TestDB_RecoverFlushKeepsDeletions(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir, &Options{CreateIfMissing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filler := func(prefix string) []*dbOperation {
+		var ops []*dbOperation
+		for i := 0; i < 20; i++ {
+			key := fmt.Sprintf("%s_%02d", prefix, i)
+			ops = append(ops, &dbOperation{name: "put " + key, method: methodPut, key: key, value: strings.Repeat("f", 100)})
+		}
+		return ops
+	}
+	runDBOperations(t, db, []*dbOperation{{name: "put k", method: methodPut, key: "k", value: "v"}})
+	runDBOperations(t, db, filler("a"))
+	runDBOperations(t, db, []*dbOperation{{name: "delete k", method: methodDelete, key: "k"}})
+	runDBOperations(t, db, filler("b"))
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重放日志时 value 与删除标记被写入不同的 table, 删除标记不能被丢弃.
+	opts := &Options{WriteBufferSize: 1024, L0CompactionTrigger: 1000}
+	for round := 0; round < 2; round++ {
+		db, err = Open(dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if round == 0 && countVersions(t, db, "k") != 2 {
+			t.Errorf("versions of k in tables = %d, want 2", countVersions(t, db, "k"))
+		}
+		runDBOperations(t, db, []*dbOperation{{name: "get deleted k", method: methodGet, key: "k", wantErr: ErrNotFound}})
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDB_RecoverCorruption(t *testing.T) {
+	dir := t.TempDir()
+	db := openTestDB(t, dir)
+	runDBOperations(t, db, []*dbOperation{
+		{name: "put foo", method: methodPut, key: "foo", value: "bar"},
+	})
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 在日志尾部追加一条 checksum 错误的记录.
+	f, err := os.OpenFile(logFileName(dir, db.logNumber), os.O_APPEND|os.O_WRONLY, os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{1, 2, 3, 4, 3, 0, 1, 'b', 'a', 'd'}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if _, err := Open(dir, &Options{ParanoidChecks: true}); err == nil {
+		t.Error("Open() with ParanoidChecks => want err, get nil")
+	}
+
+	db, err = Open(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	runDBOperations(t, db, []*dbOperation{
+		{name: "get record before corruption", method: methodGet, key: "foo", value: "bar"},
+	})
+}
+
+func TestDB_RecoverTruncatedLog(t *testing.T) {
+	dir := t.TempDir()
+	db := openTestDB(t, dir)
+	runDBOperations(t, db, []*dbOperation{
+		{name: "put foo", method: methodPut, key: "foo", value: "bar"},
+		{name: "put hello", method: methodPut, key: "hello", value: "world"},
+	})
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 截掉最后一条记录的一部分, 模拟写日志时崩溃.
+	fileName := logFileName(dir, db.logNumber)
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(fileName, info.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	// 尾部不完整的记录视为日志正常结束, 不是损坏.
+	db, err = Open(dir, &Options{ParanoidChecks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	runDBOperations(t, db, []*dbOperation{
+		{name: "get record before truncation", method: methodGet, key: "foo", value: "bar"},
+		{name: "get truncated record", method: methodGet, key: "hello", wantErr: ErrNotFound},
+	})
+}
+
+func TestDB_RecoverCompressedTables(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{CreateIfMissing: true, WriteBufferSize: 1024, Compression: compress.SnappyCompression}
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key_%03d", i)
+		runDBOperations(t, db, []*dbOperation{
+			{name: "put " + key, method: methodPut, key: key, value: strings.Repeat("v", 32)},
+		})
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 恢复日志时以 snappy 压缩写入 table.
+	db, err = Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if countFiles(t, dir, fileTypeTable) == 0 {
+		t.Fatal("memtable larger than WriteBufferSize should be flushed to table during recovery")
+	}
+
+	// 压缩方式记录在每个块中, 使用不同的压缩选项重新打开后仍可读取已有的 table.
+	// 同时只打开一个 table, 读取时 table 会被反复淘汰与重新打开.
+	db, err = Open(dir, &Options{WriteBufferSize: 1024, Compression: compress.FlateCompression, MaxOpenFiles: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key_%03d", i)
+		runDBOperations(t, db, []*dbOperation{
+			{name: "get " + key, method: methodGet, key: key, value: strings.Repeat("v", 32)},
+		})
+	}
+}
+
+func TestDB_RecoverTableFormats(t *testing.T) {
+	dir := t.TempDir()
+	// 先以 LevelDB 格式写入 table, 再以默认格式写入新的 table.
+	for round, format := range []TableFormat{TableFormatLevelDB, TableFormatGoLevelDB} {
+		db, err := Open(dir, &Options{CreateIfMissing: true, WriteBufferSize: 1024, TableFormat: format})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("key_%d_%03d", round, i)
+			runDBOperations(t, db, []*dbOperation{
+				{name: "put " + key, method: methodPut, key: key, value: strings.Repeat("v", 32)},
+			})
+		}
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 格式从 table 的 footer 中识别, 两种格式的 table 都可以读取.
+	db, err := Open(dir, &Options{TableFormat: TableFormatLevelDB})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("key_%d_%03d", round, i)
+			runDBOperations(t, db, []*dbOperation{
+				{name: "get " + key, method: methodGet, key: key, value: strings.Repeat("v", 32)},
+			})
+		}
+	}
+}
+
+func TestDB_RecoverManifest(t *testing.T) {
+	dir := t.TempDir()
+	// 不触发压缩, 所有 table 都留在第 0 层.
+	opts := &Options{CreateIfMissing: true, WriteBufferSize: 1024, L0CompactionTrigger: 1000}
+	for round := 0; round < 3; round++ {
+		db, err := Open(dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("key_%d_%03d", round, i)
+			runDBOperations(t, db, []*dbOperation{
+				{name: "put " + key, method: methodPut, key: key, value: strings.Repeat("v", 32)},
+			})
+		}
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 不属于任何 Version 的 table 文件会被删除.
+	stray := writeTestTable(t, dir, 100000, "stray")
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := os.Stat(tableFileName(dir, stray.Number)); !os.IsNotExist(err) {
+		t.Errorf("stray table should be deleted, stat err = %v", err)
+	}
+	runDBOperations(t, db, []*dbOperation{
+		{name: "get stray key", method: methodGet, key: "stray", wantErr: ErrNotFound},
+	})
+	if got := countFiles(t, dir, fileTypeManifest); got != 1 {
+		t.Errorf("manifest files = %d, want 1", got)
+	}
+	if got := countFiles(t, dir, fileTypeCurrent); got != 1 {
+		t.Errorf("current files = %d, want 1", got)
+	}
+
+	tables := countFiles(t, dir, fileTypeTable)
+	if got := db.versions.Current().NumFiles(0); got == 0 || got != tables {
+		t.Errorf("level 0 files = %d, table files = %d", got, tables)
+	}
+	if db.versions.LastSequence() != 300 {
+		t.Errorf("lastSequence = %d, want 300", db.versions.LastSequence())
+	}
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("key_%d_%03d", round, i)
+			runDBOperations(t, db, []*dbOperation{
+				{name: "get " + key, method: methodGet, key: key, value: strings.Repeat("v", 32)},
+			})
+		}
+	}
+}
+
+func TestDB_RecoverComparatorMismatch(t *testing.T) {
+	dir := t.TempDir()
+	db := openTestDB(t, dir)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Open(dir, &Options{Comparator: comparator.Reverse(comparator.Bytewise)})
+	if !errors.Is(err, version.ErrComparatorMismatch) {
+		t.Errorf("Open() => want err = %v, get err = %v", version.ErrComparatorMismatch, err)
+	}
+}
+
+func TestDB_RecoverMissingCurrent(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{CreateIfMissing: true, WriteBufferSize: 1024}
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flushFiller(t, db, "a")
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tables := countFiles(t, dir, fileTypeTable)
+	if tables == 0 {
+		t.Fatal("want table files")
+	}
+
+	// 没有 CURRENT 时无法得知已有 table 的层与序列号, 打开失败且不删除任何 table.
+	if err := os.Remove(version.CurrentFileName(dir)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(dir, opts); !errors.Is(err, ErrCurrentMissing) {
+		t.Errorf("Open() => want err = %v, get err = %v", ErrCurrentMissing, err)
+	}
+	if got := countFiles(t, dir, fileTypeTable); got != tables {
+		t.Errorf("table files = %d, want %d", got, tables)
+	}
+
+	// 目录下没有 table 时, 创建新的 MANIFEST 并重放日志.
+	dir = t.TempDir()
+	db = openTestDB(t, dir)
+	runDBOperations(t, db, []*dbOperation{{name: "put foo", method: methodPut, key: "foo", value: "bar"}})
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(version.CurrentFileName(dir)); err != nil {
+		t.Fatal(err)
+	}
+	db = openTestDB(t, dir)
+	defer db.Close()
+	runDBOperations(t, db, []*dbOperation{{name: "get foo", method: methodGet, key: "foo", value: "bar"}})
+}
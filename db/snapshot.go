@@ -0,0 +1,79 @@
+package db
+
+// Snapshot 是 DB 在某一时刻的只读视图: 通过 ReadOptions.Snapshot 读取时,
+// 忽略创建快照之后写入的数据. 快照在调用 DB.ReleaseSnapshot 之前一直有效.
+type Snapshot struct {
+	sequence uint64
+
+	// 快照在 snapshotList 中的前后节点, 释放后为 nil.
+	prev, next *Snapshot
+}
+
+// snapshotList 按序列号从小到大排列的快照链表, 由 db.mu 保护.
+type snapshotList struct {
+	head Snapshot // 哨兵节点, head.next 为最旧的快照.
+}
+
+func (l *snapshotList) init() {
+	l.head.prev = &l.head
+	l.head.next = &l.head
+}
+
+func (l *snapshotList) empty() bool {
+	return l.head.next == &l.head
+}
+
+// oldest 返回最旧的快照, 链表为空时返回 nil.
+func (l *snapshotList) oldest() *Snapshot {
+	if l.empty() {
+		return nil
+	}
+
+	return l.head.next
+}
+
+// insert 在链表末尾加入序列号为 sequence 的快照, sequence 不能小于已有快照的序列号.
+func (l *snapshotList) insert(sequence uint64) *Snapshot {
+	s := &Snapshot{sequence: sequence, prev: l.head.prev, next: &l.head}
+	s.prev.next = s
+	s.next.prev = s
+
+	return s
+}
+
+// remove 将 s 移出链表, 重复移除没有影响.
+func (l *snapshotList) remove(s *Snapshot) {
+	if s.next == nil {
+		return
+	}
+
+	s.prev.next = s.next
+	s.next.prev = s.prev
+	s.prev, s.next = nil, nil
+}
+
+// smallestSnapshot 返回仍可能被读取的最小序列号: 最旧的快照的序列号, 没有快照时为最新的序列号.
+// 压缩时, 序列号不大于它的记录中只需保留每个 key 最新的一条. 调用时需持有 db.mu.
+func (db *DB) smallestSnapshot() uint64 {
+	if s := db.snapshots.oldest(); s != nil {
+		return s.sequence
+	}
+
+	return db.versions.LastSequence()
+}
+
+// GetSnapshot 创建当前 DB 的快照, 使用完毕后需调用 ReleaseSnapshot 释放.
+func (db *DB) GetSnapshot() *Snapshot {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.snapshots.insert(db.versions.LastSequence())
+}
+
+// ReleaseSnapshot 释放快照, 释放后快照不可再用于读取.
+func (db *DB) ReleaseSnapshot(s *Snapshot) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.snapshots.remove(s)
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDB_Snapshot(t *testing.T) {
+	db := openTestDB(t, t.TempDir())
+	defer db.Close()
+
+	runDBOperations(t, db, []*dbOperation{
+		{name: "put foo v1", method: methodPut, key: "foo", value: "v1"},
+		{name: "put bar v1", method: methodPut, key: "bar", value: "v1"},
+	})
+	s1 := db.GetSnapshot()
+	runDBOperations(t, db, []*dbOperation{
+		{name: "put foo v2", method: methodPut, key: "foo", value: "v2"},
+		{name: "delete bar", method: methodDelete, key: "bar"},
+		{name: "put baz v2", method: methodPut, key: "baz", value: "v2"},
+	})
+	s2 := db.GetSnapshot()
+	runDBOperations(t, db, []*dbOperation{
+		{name: "put foo v3", method: methodPut, key: "foo", value: "v3"},
+	})
+
+	tests := []struct {
+		snapshot *Snapshot
+		key      string
+		want     string // 为空表示 key 不存在
+	}{
+		{snapshot: s1, key: "foo", want: "v1"},
+		{snapshot: s1, key: "bar", want: "v1"},
+		{snapshot: s1, key: "baz"},
+		{snapshot: s2, key: "foo", want: "v2"},
+		{snapshot: s2, key: "bar"},
+		{snapshot: s2, key: "baz", want: "v2"},
+		{key: "foo", want: "v3"},
+		{key: "bar"},
+	}
+	for _, tt := range tests {
+		value, err := db.Get([]byte(tt.key), &ReadOptions{Snapshot: tt.snapshot})
+		if tt.want == "" {
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get(%s) at snapshot %v = (%s, %v), want ErrNotFound", tt.key, tt.snapshot, value, err)
+			}
+			continue
+		}
+		if err != nil || string(value) != tt.want {
+			t.Errorf("Get(%s) at snapshot %v = (%s, %v), want %s", tt.key, tt.snapshot, value, err, tt.want)
+		}
+	}
+
+	db.ReleaseSnapshot(s1)
+	db.ReleaseSnapshot(s2)
+	db.ReleaseSnapshot(s2)
+	if !db.snapshots.empty() {
+		t.Error("snapshots should be empty after released")
+	}
+}
+
+func TestSnapshotList(t *testing.T) {
+	var l snapshotList
+	l.init()
+
+	var snapshots []*Snapshot
+	for i := uint64(1); i <= 4; i++ {
+		snapshots = append(snapshots, l.insert(i))
+	}
+	l.remove(snapshots[0])
+	l.remove(snapshots[2])
+
+	var got []uint64
+	for s := l.head.next; s != &l.head; s = s.next {
+		got = append(got, s.sequence)
+	}
+	if fmt.Sprint(got) != "[2 4]" {
+		t.Errorf("snapshots = %v, want [2 4]", got)
+	}
+}
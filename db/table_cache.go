@@ -0,0 +1,107 @@
+package db
+
+import (
+	"encoding/binary"
+
+	"github.com/goleveldb/goleveldb/cache"
+	"github.com/goleveldb/goleveldb/common"
+	"github.com/goleveldb/goleveldb/file"
+	"github.com/goleveldb/goleveldb/slice"
+	"github.com/goleveldb/goleveldb/table"
+	"github.com/goleveldb/goleveldb/version"
+)
+
+// numNonTableCacheFiles 为日志等其他文件预留的文件句柄数.
+const numNonTableCacheFiles = 10
+
+// tableCache 按文件编号缓存打开的 table, 在第一次读取时才打开文件.
+// 打开的 table 数量超过上限时按 LRU 关闭最久未使用的 table,
+// 被淘汰的 table 在所有读取者释放后才会关闭文件.
+type tableCache struct {
+	dir   string
+	opts  *table.Options
+	cache cache.Cache
+}
+
+// newTableCache 创建最多同时打开 capacity 个 table 的缓存.
+func newTableCache(dir string, opts *table.Options, capacity int) *tableCache {
+	return &tableCache{
+		dir:   dir,
+		opts:  opts,
+		cache: cache.NewLRUCache(capacity),
+	}
+}
+
+// findTable 返回 f 对应的 table, 不在缓存中时打开 table 文件.
+// 返回的 handle 使用完毕后需调用 release 释放.
+func (c *tableCache) findTable(f *version.FileMetaData) (*cache.Handle, error) {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, f.Number)
+	if h := c.cache.Lookup(key); h != nil {
+		return h, nil
+	}
+
+	reader, err := file.NewRandomReader(tableFileName(c.dir, f.Number))
+	if err != nil {
+		return nil, err
+	}
+	t, err := table.New(reader, int(f.Size), c.opts)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+
+	return c.cache.Insert(key, t, 1, closeTable), nil
+}
+
+// closeTable 在 table 被淘汰且不再被使用后关闭其文件.
+func closeTable(_ slice.Slice, value interface{}) {
+	value.(*table.Table).File.Close()
+}
+
+// release 释放 findTable 返回的 handle.
+func (c *tableCache) release(h *cache.Handle) {
+	c.cache.Release(h)
+}
+
+// get 在 f 对应的 table 中查找第一条不小于 key 的记录, 见 table.Table.Seek.
+func (c *tableCache) get(f *version.FileMetaData, key slice.Slice, opts *table.ReadOptions) (foundKey, value slice.Slice, err error) {
+	h, err := c.findTable(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer c.release(h)
+
+	return h.Value().(*table.Table).Seek(key, opts)
+}
+
+// newIterator 返回 f 对应的 table 的迭代器, 迭代器释放前 table 不会被关闭.
+func (c *tableCache) newIterator(f *version.FileMetaData, opts *table.ReadOptions) (common.Iterator, error) {
+	h, err := c.findTable(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tableCacheIterator{
+		Iterator: h.Value().(*table.Table).NewIterator(opts),
+		release:  func() { c.release(h) },
+	}, nil
+}
+
+// evict 将编号为 number 的 table 移出缓存, 在删除 table 文件前调用.
+func (c *tableCache) evict(number uint64) {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, number)
+	c.cache.Erase(key)
+}
+
+// tableCacheIterator 在释放时同时释放 table 的 handle.
+type tableCacheIterator struct {
+	common.Iterator
+	release func()
+}
+
+func (i *tableCacheIterator) Release() {
+	i.Iterator.Release()
+	i.release()
+}
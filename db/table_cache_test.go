@@ -0,0 +1,117 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/goleveldb/goleveldb/file"
+	"github.com/goleveldb/goleveldb/slice"
+	"github.com/goleveldb/goleveldb/table"
+	"github.com/goleveldb/goleveldb/version"
+)
+
+// writeTestTable 写入编号为 number 的 table, 其中只有 key => value_<number> 一条记录.
+func writeTestTable(t *testing.T, dir string, number uint64, key string) *version.FileMetaData {
+	t.Helper()
+
+	fileName := tableFileName(dir, number)
+	fileWriter, err := file.NewWriter(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tableWriter := table.NewWriter(fileWriter, nil)
+	if err := tableWriter.Add(slice.Slice(key), slice.Slice(fmt.Sprintf("value_%d", number))); err != nil {
+		t.Fatal(err)
+	}
+	if err := tableWriter.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &version.FileMetaData{Number: number, Size: uint64(info.Size())}
+}
+
+func TestTableCache_Get(t *testing.T) {
+	dir := t.TempDir()
+	var files []*version.FileMetaData
+	for i := uint64(1); i <= 10; i++ {
+		files = append(files, writeTestTable(t, dir, i, "key"))
+	}
+
+	c := newTableCache(dir, &table.Options{}, 2)
+	for round := 0; round < 2; round++ {
+		for _, f := range files {
+			key, value, err := c.get(f, slice.Slice("key"), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := fmt.Sprintf("value_%d", f.Number); string(key) != "key" || string(value) != want {
+				t.Errorf("get from table %d = %s => %s, want key => %s", f.Number, key, value, want)
+			}
+		}
+	}
+
+	if _, _, err := c.get(files[0], slice.Slice("not exist"), nil); !errors.Is(err, table.ErrNoSuchKey) {
+		t.Errorf("get not exist key => want err = %v, get err = %v", table.ErrNoSuchKey, err)
+	}
+	if _, _, err := c.get(&version.FileMetaData{Number: 100, Size: 100}, slice.Slice("key"), nil); err == nil {
+		t.Error("get from missing table should fail")
+	}
+}
+
+func TestTableCache_EvictPinnedTable(t *testing.T) {
+	dir := t.TempDir()
+	var files []*version.FileMetaData
+	for i := uint64(1); i <= 4; i++ {
+		files = append(files, writeTestTable(t, dir, i, "key"))
+	}
+
+	c := newTableCache(dir, &table.Options{}, 1)
+	h, err := c.findTable(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinned := h.Value().(*table.Table)
+	iter, err := c.newIterator(files[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 其他 table 将第一个 table 淘汰出缓存, 但所有读取者释放前 table 仍然可用.
+	for _, f := range files[1:] {
+		if _, _, err := c.get(f, slice.Slice("key"), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.evict(files[0].Number)
+
+	if _, err := pinned.Get(slice.Slice("key"), nil); err != nil {
+		t.Errorf("get from evicted table => get err = %v", err)
+	}
+	c.release(h)
+	iter.SeekToFirst()
+	if !iter.Success() || string(iter.Value()) != "value_1" || iter.Err() != nil {
+		t.Errorf("iterate evicted table => success %v, err %v", iter.Success(), iter.Err())
+	}
+
+	// 最后一个读取者释放后, 被淘汰的 table 文件被关闭.
+	iter.Release()
+	if _, err := pinned.Get(slice.Slice("key"), nil); err == nil {
+		t.Error("table file should be closed after all readers release it")
+	}
+
+	// 再次读取时重新打开 table.
+	_, value, err := c.get(files[0], slice.Slice("key"), nil)
+	if err != nil || string(value) != "value_1" {
+		t.Errorf("get from reopened table = %s, err = %v", value, err)
+	}
+}
@@ -46,3 +46,8 @@ func (r *RandomReaderImpl) Read(offset, n uint64) (slice.Slice, error) {
 
 	return buffer, nil
 }
+
+// Close 关闭文件.
+func (r *RandomReaderImpl) Close() error {
+	return r.file.Close()
+}
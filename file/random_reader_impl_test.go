@@ -78,6 +78,10 @@ func Test_RandomReaderImpl_Read(t *testing.T) {
 				assert(t, content[i] == memContent[i])
 			}
 		}
+
+		assert(t, nil == reader.Close())
+		_, err = reader.Read(0, 1)
+		assert(t, err != nil)
 	}
 }
 
@@ -4,8 +4,11 @@ import "github.com/goleveldb/goleveldb/slice"
 
 // SequentialReader 定义顺序读取文件操作.
 type SequentialReader interface {
-	// Read 顺序读取文件 n 个 byte，以 Slice 的形式返回.
+	// Read 顺序读取文件 n 个 byte，以 Slice 的形式返回, 剩余内容不足 n 个 byte 时返回剩余内容.
+	// 到达文件末尾时返回 io.EOF.
 	Read(n int) (slice.Slice, error)
 	// Skip 跳过文件的 n 个 byte.
 	Skip(n int) error
+	// Close 关闭文件.
+	Close() error
 }
@@ -1,7 +1,9 @@
 package file
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/goleveldb/goleveldb/slice"
@@ -26,17 +28,19 @@ type sequentialReaderImpl struct {
 }
 
 // Read 顺序读取文件 n 个 byte，以 Slice 的形式返回.
+// 文件剩余内容不足 n 个 byte 时返回剩余的全部内容, 已到达文件末尾时返回 io.EOF.
 func (r *sequentialReaderImpl) Read(n int) (slice.Slice, error) {
 	if len(r.buf) < n {
 		r.buf = make([]byte, n)
 	}
 
-	if _, err := r.file.Read(r.buf); err != nil {
+	readLen, err := io.ReadFull(r.file, r.buf[:n])
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
 		return nil, err
 	}
 
-	res := make([]byte, n)
-	copy(res, r.buf[:n])
+	res := make([]byte, readLen)
+	copy(res, r.buf[:readLen])
 
 	return res, nil
 }
@@ -54,3 +58,8 @@ func (r *sequentialReaderImpl) Skip(n int) error {
 
 	return nil
 }
+
+// Close 关闭文件.
+func (r *sequentialReaderImpl) Close() error {
+	return r.file.Close()
+}
@@ -79,6 +79,26 @@ func TestAll(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:    "read_partial_at_tail",
+			initStr: "foobar1",
+			opeartions: []*sequentialReaderOperation{
+				{
+					name:      "read more than file size",
+					opera:     operaRead,
+					n:         10,
+					want:      "foobar1",
+					wantError: false,
+				},
+				{
+					name:      "read eof",
+					opera:     operaRead,
+					n:         10,
+					want:      "",
+					wantError: true,
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
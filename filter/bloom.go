@@ -0,0 +1,124 @@
+package filter
+
+import (
+	"encoding/binary"
+
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+const (
+	bloomHashSeed = 0xbc9f1d34
+
+	// filters with more probes than this are reserved for future encodings, they match every key
+	maxProbes = 30
+)
+
+// bloomFilterPolicy is a bloom filter compatible with LevelDB's builtin bloom filter.
+// A filter is a bit array followed by one byte recording the number of probes.
+type bloomFilterPolicy struct {
+	bitsPerKey int
+	probes     int
+}
+
+var _ FilterPolicy = (*bloomFilterPolicy)(nil)
+
+// NewBloomFilterPolicy: create a bloom filter policy using about bitsPerKey bits for each key.
+// 10 bits per key gives a false positive rate of about 1%.
+func NewBloomFilterPolicy(bitsPerKey int) FilterPolicy {
+	// 0.69 =~ ln(2), which minimizes the false positive rate
+	probes := int(float64(bitsPerKey) * 0.69)
+	if probes < 1 {
+		probes = 1
+	}
+	if probes > maxProbes {
+		probes = maxProbes
+	}
+
+	return &bloomFilterPolicy{
+		bitsPerKey: bitsPerKey,
+		probes:     probes,
+	}
+}
+
+func (*bloomFilterPolicy) Name() string {
+	return "leveldb.BuiltinBloomFilter2"
+}
+
+func (p *bloomFilterPolicy) CreateFilter(keys []slice.Slice) slice.Slice {
+	// a small filter has a high false positive rate, so use at least 64 bits
+	bits := len(keys) * p.bitsPerKey
+	if bits < 64 {
+		bits = 64
+	}
+	bytes := (bits + 7) / 8
+	bits = bytes * 8
+
+	filter := make(slice.Slice, bytes+1)
+	filter[bytes] = byte(p.probes)
+	for _, key := range keys {
+		// double hashing generates the sequence of hash values, see [Kirsch, Mitzenmacher 2006]
+		h := bloomHash(key)
+		delta := h>>17 | h<<15
+		for j := 0; j < p.probes; j++ {
+			bitPos := h % uint32(bits)
+			filter[bitPos/8] |= 1 << (bitPos % 8)
+			h += delta
+		}
+	}
+
+	return filter
+}
+
+func (*bloomFilterPolicy) KeyMayMatch(key, filter slice.Slice) bool {
+	if len(filter) < 2 {
+		return false
+	}
+
+	bits := uint32(len(filter)-1) * 8
+	probes := int(filter[len(filter)-1])
+	if probes > maxProbes {
+		return true
+	}
+
+	h := bloomHash(key)
+	delta := h>>17 | h<<15
+	for j := 0; j < probes; j++ {
+		bitPos := h % bits
+		if filter[bitPos/8]&(1<<(bitPos%8)) == 0 {
+			return false
+		}
+		h += delta
+	}
+
+	return true
+}
+
+// bloomHash: the murmur-like hash used by LevelDB
+func bloomHash(data slice.Slice) uint32 {
+	const (
+		m = 0xc6a4a793
+		r = 24
+	)
+
+	h := uint32(bloomHashSeed) ^ uint32(len(data))*m
+	for ; len(data) >= 4; data = data[4:] {
+		h += binary.LittleEndian.Uint32(data)
+		h *= m
+		h ^= h >> 16
+	}
+
+	switch len(data) {
+	case 3:
+		h += uint32(data[2]) << 16
+		fallthrough
+	case 2:
+		h += uint32(data[1]) << 8
+		fallthrough
+	case 1:
+		h += uint32(data[0])
+		h *= m
+		h ^= h >> r
+	}
+
+	return h
+}
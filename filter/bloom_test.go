@@ -0,0 +1,89 @@
+package filter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+func intKey(i int) slice.Slice {
+	key := make(slice.Slice, 4)
+	binary.LittleEndian.PutUint32(key, uint32(i))
+
+	return key
+}
+
+func TestBloomHash(t *testing.T) {
+	// expected values come from LevelDB's Hash(data, n, 0xbc9f1d34)
+	tests := []struct {
+		data slice.Slice
+		want uint32
+	}{
+		{slice.Slice{}, 0xbc9f1d34},
+		{slice.Slice{0x62}, 0xef1345c4},
+		{slice.Slice{0xc3, 0x97}, 0x5b663814},
+		{slice.Slice{0xe2, 0x99, 0xa5}, 0x323c078f},
+		{slice.Slice{0xe1, 0x80, 0xb9, 0x32}, 0xed21633a},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%x", tt.data), func(t *testing.T) {
+			if got := bloomHash(tt.data); got != tt.want {
+				t.Errorf("bloomHash(%x) = %#x, want %#x", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBloomFilter_Empty(t *testing.T) {
+	policy := NewBloomFilterPolicy(10)
+	filter := policy.CreateFilter(nil)
+	if policy.KeyMayMatch(slice.Slice("hello"), filter) || policy.KeyMayMatch(slice.Slice("world"), filter) {
+		t.Error("empty filter should match nothing")
+	}
+	if policy.KeyMayMatch(slice.Slice("hello"), nil) {
+		t.Error("nil filter should match nothing")
+	}
+}
+
+func TestBloomFilter_Small(t *testing.T) {
+	policy := NewBloomFilterPolicy(10)
+	filter := policy.CreateFilter([]slice.Slice{slice.Slice("hello"), slice.Slice("world")})
+	if !policy.KeyMayMatch(slice.Slice("hello"), filter) || !policy.KeyMayMatch(slice.Slice("world"), filter) {
+		t.Error("filter should match added keys")
+	}
+	if policy.KeyMayMatch(slice.Slice("x"), filter) || policy.KeyMayMatch(slice.Slice("foo"), filter) {
+		t.Error("filter should not match absent keys")
+	}
+}
+
+func TestBloomFilter_VaryingLengths(t *testing.T) {
+	policy := NewBloomFilterPolicy(10)
+	for _, length := range []int{1, 10, 100, 1000, 10000} {
+		keys := make([]slice.Slice, length)
+		for i := range keys {
+			keys[i] = intKey(i)
+		}
+		filter := policy.CreateFilter(keys)
+
+		if maxLen := length*10/8 + 40; len(filter) > maxLen {
+			t.Errorf("length %d => filter size %d > %d", length, len(filter), maxLen)
+		}
+		for _, key := range keys {
+			if !policy.KeyMayMatch(key, filter) {
+				t.Fatalf("length %d => filter does not match added key %x", length, key)
+			}
+		}
+
+		falsePositives := 0
+		for i := 0; i < 10000; i++ {
+			if policy.KeyMayMatch(intKey(i+1000000000), filter) {
+				falsePositives++
+			}
+		}
+		if rate := float64(falsePositives) / 10000; rate > 0.02 {
+			t.Errorf("length %d => false positive rate %.4f > 0.02", length, rate)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+// Package filter defines filter policies used to skip reading data blocks for absent keys.
+package filter
+
+import "github.com/goleveldb/goleveldb/slice"
+
+// FilterPolicy builds a compact summary of a set of keys, which can tell if a key is definitely not in the set.
+type FilterPolicy interface {
+	// Name returns the name of the policy, it is stored in the table to find the filter block.
+	// The name must change if the encoding of filters changes.
+	Name() string
+	// CreateFilter returns a filter summarizing keys.
+	CreateFilter(keys []slice.Slice) slice.Slice
+	// KeyMayMatch returns false if key is definitely not in the set summarized by filter.
+	// It may return true for keys not in the set, but must return true for all keys in the set.
+	KeyMayMatch(key, filter slice.Slice) bool
+}
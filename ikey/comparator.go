@@ -0,0 +1,104 @@
+package ikey
+
+import (
+	"github.com/goleveldb/goleveldb/comparator"
+	"github.com/goleveldb/goleveldb/filter"
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+// Comparator 比较 internal key: 先使用 user key 的比较器比较 user key,
+// user key 相同时序列号大(更新)的排在前面, 序列号相同时 valueType 大的排在前面.
+type Comparator struct {
+	user comparator.Comparator
+}
+
+var _ comparator.Comparator = (*Comparator)(nil)
+
+// NewComparator 返回使用 user 比较 user key 的 internal key 比较器.
+func NewComparator(user comparator.Comparator) *Comparator {
+	return &Comparator{user: user}
+}
+
+// UserComparator 返回比较 user key 的比较器.
+func (c *Comparator) UserComparator() comparator.Comparator {
+	return c.user
+}
+
+// Compare 比较 internal key a, b.
+func (c *Comparator) Compare(a, b slice.Slice) int {
+	if res := c.user.Compare(InternalKey(a).UserKey(), InternalKey(b).UserKey()); res != slice.CMPSame {
+		return res
+	}
+
+	tagA, tagB := InternalKey(a).tag(), InternalKey(b).tag()
+	switch {
+	case tagA > tagB:
+		return slice.CMPSmaller
+	case tagA < tagB:
+		return slice.CMPLarger
+	default:
+		return slice.CMPSame
+	}
+}
+
+// Name 返回比较器名称, 与 user key 比较器的名称不同, 只保存 user key 的 table 无法使用它打开.
+func (c *Comparator) Name() string {
+	return "goleveldb.InternalKeyComparator(" + c.user.Name() + ")"
+}
+
+// FindShortestSeparator 缩短 start 的 user key 部分, 缩短后的 user key 使用最大的序列号,
+// 使其不小于所有 user key 相同的 internal key. 无法缩短时返回 start.
+func (c *Comparator) FindShortestSeparator(start, limit slice.Slice) slice.Slice {
+	userStart, userLimit := InternalKey(start).UserKey(), InternalKey(limit).UserKey()
+	sep := c.user.FindShortestSeparator(userStart, userLimit)
+	if len(sep) < len(userStart) && c.user.Compare(userStart, sep) == slice.CMPSmaller {
+		return Append(nil, sep, MaxSequenceNumber, TypeForSeek)
+	}
+
+	return start
+}
+
+// FindShortSuccessor 缩短 key 的 user key 部分, 无法缩短时返回 key.
+func (c *Comparator) FindShortSuccessor(key slice.Slice) slice.Slice {
+	userKey := InternalKey(key).UserKey()
+	succ := c.user.FindShortSuccessor(userKey)
+	if len(succ) < len(userKey) && c.user.Compare(userKey, succ) == slice.CMPSmaller {
+		return Append(nil, succ, MaxSequenceNumber, TypeForSeek)
+	}
+
+	return key
+}
+
+// filterPolicy 使用 user key 构建与查询过滤器, 同一个 user key 的所有版本共用过滤器中的一项.
+type filterPolicy struct {
+	user filter.FilterPolicy
+}
+
+// NewFilterPolicy 返回对 internal key 的 user key 部分使用 user 的过滤策略, user 为 nil 时返回 nil.
+func NewFilterPolicy(user filter.FilterPolicy) filter.FilterPolicy {
+	if user == nil {
+		return nil
+	}
+
+	return filterPolicy{user: user}
+}
+
+// Name 返回 user 的名称, 过滤器的内容只与 user key 有关.
+func (p filterPolicy) Name() string {
+	return p.user.Name()
+}
+
+// CreateFilter 使用 keys 的 user key 部分构建过滤器.
+func (p filterPolicy) CreateFilter(keys []slice.Slice) slice.Slice {
+	userKeys := make([]slice.Slice, len(keys))
+	for i, key := range keys {
+		userKeys[i] = InternalKey(key).UserKey()
+	}
+
+	return p.user.CreateFilter(userKeys)
+}
+
+// KeyMayMatch 检查 key 的 user key 部分是否可能存在.
+func (p filterPolicy) KeyMayMatch(key, filter slice.Slice) bool {
+	return p.user.KeyMayMatch(InternalKey(key).UserKey(), filter)
+}
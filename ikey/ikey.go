@@ -0,0 +1,136 @@
+// Package ikey 定义 internal key: 在 user key 之后附加序列号与 valueType,
+// 使同一个 user key 的多个版本与删除标记可以在内存表与 table 中共存.
+package ikey
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+// ErrCorruptedKey internal key 格式错误.
+var ErrCorruptedKey = errors.New("corrupted internal key")
+
+// tagLen internal key 末尾 tag 占用的字节数.
+const tagLen = 8
+
+// MaxSequenceNumber 序列号的最大值, 序列号与 valueType 一起编码在 8 字节的 tag 中, 占用高 56 位.
+const MaxSequenceNumber = (uint64(1) << 56) - 1
+
+// ValueType 记录的类型.
+type ValueType byte
+
+const (
+	// TypeDeletion 表示记录为删除标记.
+	TypeDeletion ValueType = 0
+	// TypeValue 表示记录为有效数据.
+	TypeValue ValueType = 1
+	// TypeForSeek 查找时使用的类型. 序列号相同时 valueType 大的排在前面,
+	// 因此使用最大的 valueType 构造的 key 不大于所有序列号相同的记录.
+	TypeForSeek = TypeValue
+)
+
+// InternalKey 编码后的 internal key, 按照以下方式排列:
+// - user key data.
+// - sequenceNumber << 8 | valueType (uint64, little endian).
+type InternalKey slice.Slice
+
+// ParsedInternalKey 解析后的 internal key.
+type ParsedInternalKey struct {
+	UserKey   slice.Slice
+	Sequence  uint64
+	ValueType ValueType
+}
+
+// packTag 将序列号与 valueType 编码为 tag.
+func packTag(sequence uint64, t ValueType) uint64 {
+	return sequence<<8 | uint64(t)
+}
+
+// Append 将 (userKey, sequence, t) 编码为 internal key 追加到 dst 之后并返回.
+func Append(dst, userKey slice.Slice, sequence uint64, t ValueType) slice.Slice {
+	var tag [tagLen]byte
+	binary.LittleEndian.PutUint64(tag[:], packTag(sequence, t))
+	dst = append(dst, userKey...)
+
+	return append(dst, tag[:]...)
+}
+
+// MakeInternalKey 返回 (userKey, sequence, t) 编码后的 internal key.
+func MakeInternalKey(userKey slice.Slice, sequence uint64, t ValueType) InternalKey {
+	return InternalKey(Append(make(slice.Slice, 0, len(userKey)+tagLen), userKey, sequence, t))
+}
+
+// ParseInternalKey 解析 internal key, 长度不足或 valueType 未知时返回 ErrCorruptedKey.
+// 返回的 UserKey 与 key 共享数据.
+func ParseInternalKey(key slice.Slice) (ParsedInternalKey, error) {
+	if len(key) < tagLen {
+		return ParsedInternalKey{}, ErrCorruptedKey
+	}
+
+	tag := binary.LittleEndian.Uint64(key[len(key)-tagLen:])
+	res := ParsedInternalKey{
+		UserKey:   key[:len(key)-tagLen],
+		Sequence:  tag >> 8,
+		ValueType: ValueType(tag & 0xff),
+	}
+	if res.ValueType > TypeValue {
+		return ParsedInternalKey{}, ErrCorruptedKey
+	}
+
+	return res, nil
+}
+
+// UserKey 返回 internal key 中的 user key 部分, 与 k 共享数据. k 需为合法的 internal key.
+func (k InternalKey) UserKey() slice.Slice {
+	return slice.Slice(k[:len(k)-tagLen])
+}
+
+// Sequence 返回 internal key 中的序列号. k 需为合法的 internal key.
+func (k InternalKey) Sequence() uint64 {
+	return k.tag() >> 8
+}
+
+// ValueType 返回 internal key 中的 valueType. k 需为合法的 internal key.
+func (k InternalKey) ValueType() ValueType {
+	return ValueType(k.tag() & 0xff)
+}
+
+func (k InternalKey) tag() uint64 {
+	return binary.LittleEndian.Uint64(k[len(k)-tagLen:])
+}
+
+// LookupKey 用于在内存表与 table 中查找 user key 在某个序列号时的版本, 按照以下方式排列:
+// - internal key length (varint).
+// - user key data.
+// - sequenceNumber << 8 | TypeForSeek (uint64, little endian).
+type LookupKey struct {
+	rep slice.Slice
+	// keyStart internal key 在 rep 中的起始位置.
+	keyStart int
+}
+
+// MakeLookupKey 创建查找 userKey 序列号不大于 sequence 的最新版本的 LookupKey.
+func MakeLookupKey(userKey slice.Slice, sequence uint64) *LookupKey {
+	rep := make(slice.Slice, binary.MaxVarintLen32, binary.MaxVarintLen32+len(userKey)+tagLen)
+	keyStart := binary.PutUvarint(rep, uint64(len(userKey)+tagLen))
+	rep = Append(rep[:keyStart], userKey, sequence, TypeForSeek)
+
+	return &LookupKey{rep: rep, keyStart: keyStart}
+}
+
+// MemtableKey 返回带有长度前缀的 internal key, 可直接与内存表中的记录比较.
+func (k *LookupKey) MemtableKey() slice.Slice {
+	return k.rep
+}
+
+// InternalKey 返回 internal key, 用于在 table 中查找.
+func (k *LookupKey) InternalKey() InternalKey {
+	return InternalKey(k.rep[k.keyStart:])
+}
+
+// UserKey 返回 user key.
+func (k *LookupKey) UserKey() slice.Slice {
+	return k.rep[k.keyStart : len(k.rep)-tagLen]
+}
@@ -0,0 +1,172 @@
+package ikey
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/goleveldb/goleveldb/comparator"
+	"github.com/goleveldb/goleveldb/filter"
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+func TestParseInternalKey(t *testing.T) {
+	tests := []struct {
+		userKey   string
+		sequence  uint64
+		valueType ValueType
+	}{
+		{"", 0, TypeValue},
+		{"foo", 1, TypeDeletion},
+		{"foo", MaxSequenceNumber, TypeValue},
+		{"hello world", 1 << 40, TypeDeletion},
+	}
+	for _, tt := range tests {
+		key := MakeInternalKey(slice.Slice(tt.userKey), tt.sequence, tt.valueType)
+		parsed, err := ParseInternalKey(slice.Slice(key))
+		if err != nil {
+			t.Fatalf("ParseInternalKey(%q) => get err = %v", key, err)
+		}
+		want := ParsedInternalKey{UserKey: slice.Slice(tt.userKey), Sequence: tt.sequence, ValueType: tt.valueType}
+		if string(parsed.UserKey) != tt.userKey || parsed.Sequence != want.Sequence || parsed.ValueType != want.ValueType {
+			t.Errorf("ParseInternalKey() = %+v, want %+v", parsed, want)
+		}
+		if string(key.UserKey()) != tt.userKey || key.Sequence() != tt.sequence || key.ValueType() != tt.valueType {
+			t.Errorf("InternalKey accessors = (%q, %d, %d), want (%q, %d, %d)",
+				key.UserKey(), key.Sequence(), key.ValueType(), tt.userKey, tt.sequence, tt.valueType)
+		}
+	}
+
+	// tag 使用 little endian 编码, 与 LevelDB 相同.
+	if got := MakeInternalKey(slice.Slice("k"), 2, TypeValue); string(got) != "k\x01\x02\x00\x00\x00\x00\x00\x00" {
+		t.Errorf("MakeInternalKey() = %q", got)
+	}
+
+	for _, bad := range []slice.Slice{nil, slice.Slice("short"), Append(nil, slice.Slice("foo"), 1, 2)} {
+		if _, err := ParseInternalKey(bad); !errors.Is(err, ErrCorruptedKey) {
+			t.Errorf("ParseInternalKey(%q) => want err = %v, get err = %v", bad, ErrCorruptedKey, err)
+		}
+	}
+}
+
+func TestMakeLookupKey(t *testing.T) {
+	for _, userKey := range []string{"", "foo", string(make([]byte, 300))} {
+		k := MakeLookupKey(slice.Slice(userKey), 100)
+		if string(k.UserKey()) != userKey {
+			t.Errorf("UserKey() = %q, want %q", k.UserKey(), userKey)
+		}
+		ikey := k.InternalKey()
+		if string(ikey.UserKey()) != userKey || ikey.Sequence() != 100 || ikey.ValueType() != TypeForSeek {
+			t.Errorf("InternalKey() = %q", ikey)
+		}
+		memKey := k.MemtableKey()
+		if len(memKey) <= len(ikey) || string(memKey[len(memKey)-len(ikey):]) != string(ikey) {
+			t.Errorf("MemtableKey() = %q", memKey)
+		}
+	}
+}
+
+func TestComparator_Compare(t *testing.T) {
+	cmp := NewComparator(comparator.Bytewise)
+	// 按顺序排列的 internal key.
+	keys := []InternalKey{
+		MakeInternalKey(slice.Slice("a"), MaxSequenceNumber, TypeValue),
+		MakeInternalKey(slice.Slice("a"), 5, TypeValue),
+		MakeInternalKey(slice.Slice("a"), 5, TypeDeletion),
+		MakeInternalKey(slice.Slice("a"), 1, TypeValue),
+		MakeInternalKey(slice.Slice("b"), 100, TypeDeletion),
+		MakeInternalKey(slice.Slice("b"), 0, TypeValue),
+	}
+	for i := range keys {
+		for j := range keys {
+			want := slice.CMPSame
+			if i < j {
+				want = slice.CMPSmaller
+			} else if i > j {
+				want = slice.CMPLarger
+			}
+			if got := cmp.Compare(slice.Slice(keys[i]), slice.Slice(keys[j])); got != want {
+				t.Errorf("Compare(%q, %q) = %d, want %d", keys[i], keys[j], got, want)
+			}
+		}
+	}
+
+	if cmp.Name() == comparator.Bytewise.Name() {
+		t.Errorf("Name() should differ from the user comparator name")
+	}
+}
+
+func TestComparator_Shorten(t *testing.T) {
+	cmp := NewComparator(comparator.Bytewise)
+	tests := []struct {
+		name  string
+		start InternalKey
+		limit InternalKey
+		want  InternalKey
+	}{
+		{
+			name:  "shorten user key",
+			start: MakeInternalKey(slice.Slice("foo"), 100, TypeValue),
+			limit: MakeInternalKey(slice.Slice("hello"), 200, TypeValue),
+			want:  MakeInternalKey(slice.Slice("g"), MaxSequenceNumber, TypeForSeek),
+		},
+		{
+			name:  "same user key",
+			start: MakeInternalKey(slice.Slice("foo"), 100, TypeValue),
+			limit: MakeInternalKey(slice.Slice("foo"), 99, TypeValue),
+			want:  MakeInternalKey(slice.Slice("foo"), 100, TypeValue),
+		},
+		{
+			name:  "prefix",
+			start: MakeInternalKey(slice.Slice("foo"), 100, TypeValue),
+			limit: MakeInternalKey(slice.Slice("foobar"), 200, TypeValue),
+			want:  MakeInternalKey(slice.Slice("foo"), 100, TypeValue),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cmp.FindShortestSeparator(slice.Slice(tt.start), slice.Slice(tt.limit))
+			if string(got) != string(tt.want) {
+				t.Errorf("FindShortestSeparator() = %q, want %q", got, tt.want)
+			}
+			if cmp.Compare(got, slice.Slice(tt.start)) == slice.CMPSmaller || cmp.Compare(got, slice.Slice(tt.limit)) != slice.CMPSmaller {
+				t.Errorf("FindShortestSeparator() = %q, not in [%q, %q)", got, tt.start, tt.limit)
+			}
+		})
+	}
+
+	key := MakeInternalKey(slice.Slice("foo"), 100, TypeValue)
+	want := MakeInternalKey(slice.Slice("g"), MaxSequenceNumber, TypeForSeek)
+	if got := cmp.FindShortSuccessor(slice.Slice(key)); string(got) != string(want) {
+		t.Errorf("FindShortSuccessor() = %q, want %q", got, want)
+	}
+	key = MakeInternalKey(slice.Slice{0xff, 0xff}, 100, TypeValue)
+	if got := cmp.FindShortSuccessor(slice.Slice(key)); string(got) != string(key) {
+		t.Errorf("FindShortSuccessor() = %q, want %q", got, key)
+	}
+}
+
+func TestFilterPolicy(t *testing.T) {
+	if NewFilterPolicy(nil) != nil {
+		t.Errorf("NewFilterPolicy(nil) should return nil")
+	}
+
+	user := filter.NewBloomFilterPolicy(10)
+	policy := NewFilterPolicy(user)
+	if policy.Name() != user.Name() {
+		t.Errorf("Name() = %s, want %s", policy.Name(), user.Name())
+	}
+
+	f := policy.CreateFilter([]slice.Slice{
+		slice.Slice(MakeInternalKey(slice.Slice("foo"), 1, TypeValue)),
+		slice.Slice(MakeInternalKey(slice.Slice("bar"), 2, TypeDeletion)),
+	})
+	// 序列号不同的版本同样匹配.
+	for _, key := range []string{"foo", "bar"} {
+		if !policy.KeyMayMatch(slice.Slice(MakeLookupKey(slice.Slice(key), 100).InternalKey()), f) {
+			t.Errorf("KeyMayMatch(%s) = false, want true", key)
+		}
+	}
+	if policy.KeyMayMatch(slice.Slice(MakeLookupKey(slice.Slice("missing"), 100).InternalKey()), f) {
+		t.Errorf("KeyMayMatch(missing) = true, want false")
+	}
+}
@@ -34,6 +34,20 @@ func (m *MockSequentialReader) EXPECT() *MockSequentialReaderMockRecorder {
 	return m.recorder
 }
 
+// Close mocks base method.
+func (m *MockSequentialReader) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockSequentialReaderMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockSequentialReader)(nil).Close))
+}
+
 // Read mocks base method.
 func (m *MockSequentialReader) Read(arg0 int) (slice.Slice, error) {
 	m.ctrl.T.Helper()
@@ -34,13 +34,13 @@ func (m *MockReporter) EXPECT() *MockReporterMockRecorder {
 }
 
 // Corruption mocks base method.
-func (m *MockReporter) Corruption(arg0 error) {
+func (m *MockReporter) Corruption(arg0 int, arg1 error) {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "Corruption", arg0)
+	m.ctrl.Call(m, "Corruption", arg0, arg1)
 }
 
 // Corruption indicates an expected call of Corruption.
-func (mr *MockReporterMockRecorder) Corruption(arg0 interface{}) *gomock.Call {
+func (mr *MockReporterMockRecorder) Corruption(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Corruption", reflect.TypeOf((*MockReporter)(nil).Corruption), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Corruption", reflect.TypeOf((*MockReporter)(nil).Corruption), arg0, arg1)
 }
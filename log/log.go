@@ -1,7 +1,18 @@
-// Package log 实现日志读写操作.
+// Package log 实现日志读写操作, 日志格式与 LevelDB 相同.
+//
+// 日志由 BlockSize 大小的块组成, 每个块中包含若干物理 Record, 块尾部不足 HeaderSize 的空间以 0 填充.
+// 物理 Record 按照以下方式排列:
+// - checksum: type 与 data 的 CRC-32C 掩码 (uint32, little endian).
+// - length: data 的长度 (uint16, little endian).
+// - type: Record 类型 (1 byte).
+// - data.
 package log
 
+import "github.com/goleveldb/goleveldb/crc32c"
+
 const (
+	// RecordZeroType 预分配的文件区域中类型为 0 且长度为 0 的 Record, 读取时跳过.
+	RecordZeroType   = 0
 	RecordFullType   = 1
 	RecordFirstType  = 2
 	RecordMiddleType = 3
@@ -10,3 +21,8 @@ const (
 	BlockSize  = 32768
 	HeaderSize = 7 // 4 (checksum) + 2 (length) + 1 (type)
 )
+
+// recordChecksum 计算类型为 recordType 的物理 Record 的校验和.
+func recordChecksum(recordType int, data []byte) uint32 {
+	return crc32c.Mask(crc32c.Extend(crc32c.Value([]byte{byte(recordType)}), data))
+}
@@ -0,0 +1,89 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/goleveldb/goleveldb/file"
+	"github.com/goleveldb/goleveldb/internal/mock/mock_log"
+	"github.com/goleveldb/goleveldb/slice"
+	"github.com/pkg/errors"
+)
+
+// goldenFile 由 LevelDB 日志格式的参考实现生成, 依次包含 goldenRecords 中的 record.
+const goldenFile = "testdata/leveldb.log"
+
+// goldenRecords 覆盖了 FULL/FIRST/MIDDLE/LAST 类型的 Record, 块尾部的填充,
+// 以及块尾部恰好剩余 HeaderSize 字节时写入的长度为 0 的 FIRST Record.
+var goldenRecords = []slice.Slice{
+	slice.Slice("hello"),
+	slice.Slice(""),
+	slice.Slice(bytes.Repeat([]byte("a"), 1000)),
+	slice.Slice(bytes.Repeat([]byte("b"), 97270)),
+	slice.Slice(bytes.Repeat([]byte("c"), 32738)),
+	slice.Slice(bytes.Repeat([]byte("d"), 10)),
+	slice.Slice(bytes.Repeat([]byte("e"), 32737)),
+	slice.Slice(bytes.Repeat([]byte("f"), 10)),
+}
+
+func TestWriter_Golden(t *testing.T) {
+	want, err := ioutil.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatalf("read golden file => get err = %v", err)
+	}
+
+	fileName := filepath.Join(t.TempDir(), "000001.log")
+	fileWriter, err := file.NewWriter(fileName)
+	if err != nil {
+		t.Fatalf("file.NewWriter() => get err = %v", err)
+	}
+	w := NewWriter(fileWriter, 0)
+	for _, record := range goldenRecords {
+		if err := w.AddRecord(record); err != nil {
+			t.Fatalf("AddRecord() => get err = %v", err)
+		}
+	}
+	if err := fileWriter.Close(); err != nil {
+		t.Fatalf("Close() => get err = %v", err)
+	}
+
+	got, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("read log file => get err = %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("log file is not identical to %s, len = %d, want len = %d", goldenFile, len(got), len(want))
+	}
+}
+
+func TestReader_Golden(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockReporter := mock_log.NewMockReporter(mockCtrl)
+	mockReporter.EXPECT().Corruption(gomock.Any(), gomock.Any()).Times(0)
+
+	reader, err := file.NewSequentialReader(goldenFile)
+	if err != nil {
+		t.Fatalf("file.NewSequentialReader() => get err = %v", err)
+	}
+	defer reader.Close()
+
+	r := NewReader(reader, mockReporter, nil)
+	for i, want := range goldenRecords {
+		record, err := r.ReadRecord()
+		if err != nil {
+			t.Fatalf("ReadRecord() #%d => get err = %v", i, err)
+		}
+		if record.Compare(want) != slice.CMPSame {
+			t.Errorf("ReadRecord() #%d => len = %d, want len = %d", i, len(record), len(want))
+		}
+	}
+	if _, err := r.ReadRecord(); !errors.Is(err, io.EOF) {
+		t.Errorf("ReadRecord() => want err = %v, get err = %v", io.EOF, err)
+	}
+}
@@ -2,7 +2,7 @@ package log
 
 import (
 	"encoding/binary"
-	"hash/crc32"
+	"io"
 
 	"github.com/goleveldb/goleveldb/file"
 	"github.com/goleveldb/goleveldb/slice"
@@ -10,6 +10,16 @@ import (
 	"github.com/pkg/errors"
 )
 
+// ErrCorruptedRecord 日志中的 Record 损坏, 通过 Reporter 报告的损坏均包装该错误.
+var ErrCorruptedRecord = errors.New("corrupted log record")
+
+const (
+	// recordTypeEOF 读取时使用, 表示日志已读完.
+	recordTypeEOF = -1
+	// recordTypeBad 读取时使用, 表示物理 Record 损坏或无效, 已被丢弃.
+	recordTypeBad = -2
+)
+
 // Reader 定义读日志操作.
 type Reader interface {
 	// ReadRecord 读取一个逻辑 Record.
@@ -18,6 +28,17 @@ type Reader interface {
 	GetLastRecordOffset() int
 }
 
+// ReaderOptions 控制 ReaderImpl 的行为.
+type ReaderOptions struct {
+	// Strict 为 true 时, 读到损坏的 Record 后 ReadRecord 返回包装了 ErrCorruptedRecord 的错误;
+	// 否则丢弃损坏的块, 从下一个块继续读取.
+	// 两种模式下损坏都会通过 Reporter 报告, 文件尾部不完整的 Record 都被视为日志正常结束.
+	Strict bool
+	// InitialOffset 读取从该偏移量处或之后开始的第一个 Record 及其后的 Record.
+	// 读取时直接跳到该偏移量所在的块, 并跳过在该偏移量之前开始的 Record 的片段.
+	InitialOffset int
+}
+
 // ReaderImpl 实现 Reader 接口, 读取日志.
 type ReaderImpl struct {
 	file.SequentialReader
@@ -26,64 +47,128 @@ type ReaderImpl struct {
 	// 当前 buffer尾部 相对日志文件的偏移量.
 	endOfBufOffset int
 	buf            slice.Slice
+	// eof 为 true 时文件已读完, buf 中为文件最后的内容.
+	eof bool
+	// resyncing 为 true 时跳过开始于 InitialOffset 之前的 Record 的 MIDDLE 与 LAST 片段.
+	resyncing bool
 
 	reporter Reporter
+	opts     ReaderOptions
+}
+
+// NewReader 创建从 reader 读取日志的 ReaderImpl, 读取过程中发现的错误通过 reporter 报告.
+// opts 为 nil 时使用默认配置.
+func NewReader(reader file.SequentialReader, reporter Reporter, opts *ReaderOptions) *ReaderImpl {
+	r := &ReaderImpl{
+		SequentialReader: reader,
+		reporter:         reporter,
+	}
+	if opts != nil {
+		r.opts = *opts
+	}
+	r.resyncing = r.opts.InitialOffset > 0
+
+	return r
 }
 
-// ReadRecord 读取一个逻辑 Record.
+// ReadRecord 读取一个逻辑 Record, 日志读完时返回 io.EOF.
 func (r *ReaderImpl) ReadRecord() (record slice.Slice, err error) {
+	// 尚未读取文件时先跳到 InitialOffset 所在的块.
+	if r.opts.InitialOffset > 0 && r.endOfBufOffset == 0 {
+		if err := r.skipToInitialBlock(); err != nil {
+			return nil, err
+		}
+	}
+
 	inFragment := false
 	for {
 		data, recordType, err := r.readPhysicalRecord()
 		if err != nil {
-			r.reporter.Corruption(errors.Wrap(err, "read physical record error"))
-
 			return nil, err
 		}
 
+		// 跳过开始于 InitialOffset 之前的 Record 的剩余片段.
+		if r.resyncing {
+			switch recordType {
+			case RecordMiddleType, recordTypeBad:
+				continue
+			case RecordLastType:
+				r.resyncing = false
+				continue
+			default:
+				r.resyncing = false
+			}
+		}
+
 		// 当前物理 Record 的起始偏移量.
 		// 计算方法：当前 Record 起始地址 = buf 总偏移量 - 未读取数据长度 - 当前 Record 长度.
 		physicalRecordOffset := r.endOfBufOffset - len(r.buf) - HeaderSize - len(data)
 
 		switch recordType {
 		case RecordFullType:
-			if inFragment {
-				r.reporter.Corruption(errors.New("get full type record, but in_fragment"))
+			if inFragment && len(record) > 0 {
+				if err := r.reportCorruption(len(record), "get full type record, but in_fragment"); err != nil {
+					return nil, err
+				}
 			}
 
 			r.LastRecordOffset = physicalRecordOffset
 			return data, nil
 
 		case RecordFirstType:
-			if inFragment {
-				r.reporter.Corruption(errors.New("get first type record, but in_fragment"))
+			if inFragment && len(record) > 0 {
+				if err := r.reportCorruption(len(record), "get first type record, but in_fragment"); err != nil {
+					return nil, err
+				}
 			}
 
 			inFragment = true
 			r.LastRecordOffset = physicalRecordOffset
-			record = data
+			record = append(record[:0], data...)
 
 		case RecordMiddleType:
 			if !inFragment {
-				r.reporter.Corruption(errors.New("get middle type record, but not in_fragment"))
+				if err := r.reportCorruption(len(data), "get middle type record, but not in_fragment"); err != nil {
+					return nil, err
+				}
 			} else {
 				record = append(record, data...)
 			}
 
 		case RecordLastType:
 			if !inFragment {
-				r.reporter.Corruption(errors.New("get last type record, but not in_fragment"))
+				if err := r.reportCorruption(len(data), "get last type record, but not in_fragment"); err != nil {
+					return nil, err
+				}
 			} else {
 				record = append(record, data...)
 
 				return record, nil
 			}
 
-		default:
-			err = errors.New("unknown record type")
-			r.reporter.Corruption(err)
+		case recordTypeEOF:
+			// 写者可能在写完一个片段后崩溃, 尾部不完整的逻辑 Record 被忽略, 不视为损坏.
+			return nil, io.EOF
 
-			return nil, err
+		case recordTypeBad:
+			if inFragment {
+				if err := r.reportCorruption(len(record), "error in middle of record"); err != nil {
+					return nil, err
+				}
+				inFragment = false
+				record = record[:0]
+			}
+
+		default:
+			dropSize := len(data)
+			if inFragment {
+				dropSize += len(record)
+			}
+			if err := r.reportCorruption(dropSize, "unknown record type"); err != nil {
+				return nil, err
+			}
+			inFragment = false
+			record = record[:0]
 		}
 	}
 }
@@ -93,34 +178,103 @@ func (r *ReaderImpl) GetLastRecordOffset() int {
 	return r.LastRecordOffset
 }
 
-// readPhysicalRecord 读取一个物理 Record, 并返回该 Record 的 data 部分.
+// skipToInitialBlock 跳到 InitialOffset 所在的块, InitialOffset 位于块尾部的填充中时跳到下一个块.
+func (r *ReaderImpl) skipToInitialBlock() error {
+	offsetInBlock := r.opts.InitialOffset % BlockSize
+	blockStart := r.opts.InitialOffset - offsetInBlock
+	if offsetInBlock > BlockSize-HeaderSize {
+		blockStart += BlockSize
+	}
+	if blockStart == 0 {
+		return nil
+	}
+
+	if err := r.SequentialReader.Skip(blockStart); err != nil {
+		r.reporter.Corruption(blockStart, errors.Wrap(err, "skip to initial block error"))
+
+		return err
+	}
+	r.endOfBufOffset = blockStart
+
+	return nil
+}
+
+// readPhysicalRecord 读取一个物理 Record, 并返回该 Record 的 data 部分与类型.
+// 日志读完或文件尾部的 Record 不完整时返回 recordTypeEOF; Record 损坏时丢弃 buf 中剩余的内容
+// (即当前块的剩余部分), 报告损坏后返回 recordTypeBad. 读取文件失败或严格模式下发现损坏时返回错误.
+// 开始于 InitialOffset 之前的 Record 同样返回 recordTypeBad, 但不报告损坏.
 func (r *ReaderImpl) readPhysicalRecord() (record slice.Slice, recordType int, err error) {
 	for {
 		if len(r.buf) < HeaderSize {
+			if r.eof {
+				// 文件尾部不完整的头部是写者在写入头部时崩溃导致的, 不视为损坏.
+				r.buf = nil
+				return nil, recordTypeEOF, nil
+			}
+
+			// 上一次读取了完整的块, 剩余不足 HeaderSize 的部分是块尾部的填充.
 			buf, err := r.SequentialReader.Read(BlockSize)
+			if errors.Is(err, io.EOF) {
+				r.buf, r.eof = nil, true
+				continue
+			}
 			if err != nil {
+				r.buf, r.eof = nil, true
+				r.reporter.Corruption(BlockSize, errors.Wrap(err, "read physical record error"))
+
 				return nil, 0, err
 			}
 
 			r.buf = buf
 			r.endOfBufOffset += len(r.buf)
+			r.eof = len(r.buf) < BlockSize
 			continue
 		}
 
-		length := int(binary.BigEndian.Uint16(r.buf[4:6]))
+		length := int(binary.LittleEndian.Uint16(r.buf[4:6]))
+		recordType = int(r.buf[6])
 		if length+HeaderSize > len(r.buf) {
-			return nil, 0, errors.New("len(record) < header.length")
+			dropSize := len(r.buf)
+			r.buf = nil
+			if r.eof {
+				// 文件尾部的 Record 不完整, 是写者在写入 data 时崩溃导致的, 不视为损坏.
+				return nil, recordTypeEOF, nil
+			}
+
+			return nil, recordTypeBad, r.reportCorruption(dropSize, "len(record) < header.length")
+		}
+
+		if recordType == RecordZeroType && length == 0 {
+			// 预分配的文件区域中全为 0, 跳过且不报告损坏.
+			r.buf = nil
+			return nil, recordTypeBad, nil
 		}
 
 		record = r.buf[HeaderSize : HeaderSize+length]
-		crc := binary.BigEndian.Uint32(r.buf[:4])
-		if crc != crc32.ChecksumIEEE(record) {
-			return nil, 0, errors.New("checksum not equal")
+		if binary.LittleEndian.Uint32(r.buf[:4]) != recordChecksum(recordType, record) {
+			// 长度也可能已损坏, 丢弃当前块的剩余部分, 以免将 data 中的内容当作 Record 解析.
+			dropSize := len(r.buf)
+			r.buf = nil
+
+			return nil, recordTypeBad, r.reportCorruption(dropSize, "checksum not equal")
 		}
 
-		recordType = int(r.buf[6])
 		r.buf = r.buf[HeaderSize+length:]
+		if r.endOfBufOffset-len(r.buf)-HeaderSize-length < r.opts.InitialOffset {
+			return nil, recordTypeBad, nil
+		}
 
 		return record, recordType, nil
 	}
 }
+
+// reportCorruption 报告丢弃了 bytes 字节的损坏, 严格模式下返回该损坏.
+func (r *ReaderImpl) reportCorruption(bytes int, reason string) error {
+	err := errors.Wrap(ErrCorruptedRecord, reason)
+	r.reporter.Corruption(bytes, err)
+	if r.opts.Strict {
+		return err
+	}
+
+	return nil
+}
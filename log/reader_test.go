@@ -102,7 +102,7 @@ func testNormalReadAfterWrite_ReaderImpl_ReadRecord(t *testing.T) {
 			readCount = 0
 			blocks = generateLogFileBlocks(mockCtrl, tt.wantRecords)
 
-			mockReporter.EXPECT().Corruption(gomock.Any()).AnyTimes()
+			mockReporter.EXPECT().Corruption(gomock.Any(), gomock.Any()).AnyTimes()
 			mockSequentialReader.EXPECT().Read(gomock.Any()).AnyTimes().DoAndReturn(
 				func(n int) (slice.Slice, error) {
 					if tt.sequentialReaderError {
@@ -142,40 +142,68 @@ func testNormalReadAfterWrite_ReaderImpl_ReadRecord(t *testing.T) {
 	}
 }
 
-// generateLogFileBlocks 将多条 record 以 block 的形式 写入 blocks.
+// generateLogFileBlocks 将多条 record 写入日志, 并将日志内容按 BlockSize 切分为 blocks.
 func generateLogFileBlocks(mockCtrl *gomock.Controller, records []slice.Slice) (blocks []slice.Slice) {
-	block := make(slice.Slice, 0)
-	blocks = []slice.Slice{}
-	mockWriter := mock_file.NewMockWriter(mockCtrl)
+	data := generateLogFile(mockCtrl, records)
+	for len(data) > BlockSize {
+		blocks = append(blocks, data[:BlockSize])
+		data = data[BlockSize:]
+	}
 
-	mockWriter.EXPECT().Append(gomock.Any()).AnyTimes().DoAndReturn(func(data slice.Slice) error {
-		block = append(block, data...)
+	return append(blocks, data)
+}
 
-		return nil
-	})
+// generateLogFile 返回依次写入 records 后的日志内容.
+func generateLogFile(mockCtrl *gomock.Controller, records []slice.Slice) slice.Slice {
+	data := make(slice.Slice, 0)
+	mockWriter := mock_file.NewMockWriter(mockCtrl)
 
-	mockWriter.EXPECT().Flush().AnyTimes().DoAndReturn(func() error {
-		blocks = append(blocks, block)
-		block = make(slice.Slice, 0)
+	mockWriter.EXPECT().Append(gomock.Any()).AnyTimes().DoAndReturn(func(s slice.Slice) error {
+		data = append(data, s...)
 
 		return nil
 	})
+	mockWriter.EXPECT().Flush().AnyTimes().Return(nil)
 
 	writer := &WriterImpl{fileWriter: mockWriter}
-
 	for _, record := range records {
 		if err := writer.AddRecord(record); err != nil {
-			mockCtrl.T.Fatalf("add record error ", err)
+			mockCtrl.T.Fatalf("add record error %v", err)
 		}
 	}
 
-	if len(block) != 0 {
-		if err := mockWriter.Flush(); err != nil {
-			mockCtrl.T.Fatalf("flush error", err)
-		}
-	}
+	return data
+}
+
+// newMockFileReader 返回顺序读取 data 的 SequentialReader.
+func newMockFileReader(mockCtrl *gomock.Controller, data slice.Slice) *mock_file.MockSequentialReader {
+	mockSequentialReader := mock_file.NewMockSequentialReader(mockCtrl)
+	mockSequentialReader.EXPECT().Read(gomock.Any()).AnyTimes().DoAndReturn(
+		func(n int) (slice.Slice, error) {
+			if len(data) == 0 {
+				return nil, io.EOF
+			}
+			if n > len(data) {
+				n = len(data)
+			}
+			res := data[:n]
+			data = data[n:]
+
+			return res, nil
+		},
+	)
+	mockSequentialReader.EXPECT().Skip(gomock.Any()).AnyTimes().DoAndReturn(
+		func(n int) error {
+			if n > len(data) {
+				n = len(data)
+			}
+			data = data[n:]
+
+			return nil
+		},
+	)
 
-	return blocks
+	return mockSequentialReader
 }
 
 // testLogFileDamagedRead_ReaderImpl_ReadRecord 测试 log 文件损坏后的读取时 报错信息是否正常.
@@ -186,8 +214,6 @@ func testLogFileDamagedRead_ReaderImpl_ReadRecord(t *testing.T) {
 	mockReporter := mock_log.NewMockReporter(mockCtrl)
 	mockSequentialReader := mock_file.NewMockSequentialReader(mockCtrl)
 
-	generateLogFileBlocks(mockCtrl, []slice.Slice{make(slice.Slice, 2)})
-
 	// 本测试集中所有样例都期望通过reporter报告错误.
 	tests := []struct {
 		name         string
@@ -198,9 +224,9 @@ func testLogFileDamagedRead_ReaderImpl_ReadRecord(t *testing.T) {
 			name: "test full type in fragment",
 			block: []byte{
 				// first type record
-				65, 217, 18, 255, 0, 2, 2, 0, 0,
+				239, 72, 113, 133, 2, 0, 2, 0, 0,
 				// full type
-				65, 217, 18, 255, 0, 2, 1, 0, 0,
+				34, 117, 140, 4, 2, 0, 1, 0, 0,
 			},
 			errorKeyWord: "get full type record, but in_fragment",
 		},
@@ -208,9 +234,9 @@ func testLogFileDamagedRead_ReaderImpl_ReadRecord(t *testing.T) {
 			name: "test first type in fragment",
 			block: []byte{
 				// first type record
-				65, 217, 18, 255, 0, 2, 2, 0, 0,
+				239, 72, 113, 133, 2, 0, 2, 0, 0,
 				// first type
-				65, 217, 18, 255, 0, 2, 2, 0, 0,
+				239, 72, 113, 133, 2, 0, 2, 0, 0,
 			},
 			errorKeyWord: "get first type record, but in_fragment",
 		},
@@ -218,7 +244,7 @@ func testLogFileDamagedRead_ReaderImpl_ReadRecord(t *testing.T) {
 			name: "test middle type not in fragment",
 			block: []byte{
 				// middle type
-				65, 217, 18, 255, 0, 2, 3, 0, 0,
+				108, 255, 149, 104, 2, 0, 3, 0, 0,
 			},
 			errorKeyWord: "get middle type record, but not in_fragment",
 		},
@@ -226,31 +252,24 @@ func testLogFileDamagedRead_ReaderImpl_ReadRecord(t *testing.T) {
 			name: "test last type not in fragment",
 			block: []byte{
 				// last type
-				65, 217, 18, 255, 0, 2, 4, 0, 0,
+				76, 232, 68, 177, 2, 0, 4, 0, 0,
 			},
 			errorKeyWord: "get last type record, but not in_fragment",
 		},
 		{
 			name: "get unknown type record",
 			block: []byte{
-				65, 217, 18, 255, 0, 2, 5, 0, 0,
+				207, 162, 191, 204, 2, 0, 5, 0, 0,
 			},
 			errorKeyWord: "unknown record type",
 		},
 		{
 			name: "get checksum not equal record",
 			block: []byte{
-				66, 217, 18, 255, 0, 2, 1, 0, 0,
+				35, 117, 140, 4, 2, 0, 1, 0, 0,
 			},
 			errorKeyWord: "checksum not equal",
 		},
-		{
-			name: "get data not enouth record",
-			block: []byte{
-				66, 217, 18, 255, 0, 2, 1, 0,
-			},
-			errorKeyWord: "len(record) < header.length",
-		},
 	}
 
 	var readCount int
@@ -260,8 +279,8 @@ func testLogFileDamagedRead_ReaderImpl_ReadRecord(t *testing.T) {
 			readCount = 0
 			getExpectedKeyWord = false
 
-			mockReporter.EXPECT().Corruption(gomock.Any()).AnyTimes().DoAndReturn(
-				func(err error) {
+			mockReporter.EXPECT().Corruption(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+				func(bytes int, err error) {
 					if strings.Contains(err.Error(), tt.errorKeyWord) {
 						getExpectedKeyWord = true
 					}
@@ -296,3 +315,271 @@ func testLogFileDamagedRead_ReaderImpl_ReadRecord(t *testing.T) {
 		})
 	}
 }
+
+func TestReaderImpl_ReadRecordEOF(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockReporter := mock_log.NewMockReporter(mockCtrl)
+	mockSequentialReader := mock_file.NewMockSequentialReader(mockCtrl)
+
+	wantRecords := []slice.Slice{slice.Slice("foo"), make(slice.Slice, BlockSize)}
+	blocks := generateLogFileBlocks(mockCtrl, wantRecords)
+	readCount := 0
+
+	// 正常读到文件末尾时不应报告错误.
+	mockReporter.EXPECT().Corruption(gomock.Any(), gomock.Any()).Times(0)
+	mockSequentialReader.EXPECT().Read(gomock.Any()).AnyTimes().DoAndReturn(
+		func(n int) (slice.Slice, error) {
+			if readCount < len(blocks) {
+				readCount++
+				return blocks[readCount-1], nil
+			}
+
+			return nil, io.EOF
+		},
+	)
+
+	r := NewReader(mockSequentialReader, mockReporter, nil)
+	for _, want := range wantRecords {
+		record, err := r.ReadRecord()
+		if err != nil || record.Compare(want) != slice.CMPSame {
+			t.Fatalf("ReadRecord() => unexpected result, err = %v", err)
+		}
+	}
+
+	if _, err := r.ReadRecord(); !errors.Is(err, io.EOF) {
+		t.Errorf("ReadRecord() => want err = %v, get err = %v", io.EOF, err)
+	}
+}
+
+// resyncTestRecords 的布局:
+// - block 0: a (FULL, 偏移 0), b (FULL, 偏移 107), f 的 FIRST 片段 (偏移 214).
+// - block 1: f 的 LAST 片段 (data 长度 221), c (FULL, 偏移 BlockSize+228).
+func resyncTestRecords() []slice.Slice {
+	return []slice.Slice{
+		slice.Slice(strings.Repeat("a", 100)),
+		slice.Slice(strings.Repeat("b", 100)),
+		slice.Slice(strings.Repeat("f", BlockSize)),
+		slice.Slice(strings.Repeat("c", 100)),
+	}
+}
+
+type reportedCorruption struct {
+	bytes   int
+	keyWord string
+}
+
+func TestReaderImpl_Resync(t *testing.T) {
+	records := resyncTestRecords()
+	tests := []struct {
+		name        string
+		corrupt     func(data slice.Slice)
+		wantRecords []slice.Slice
+		wantReports []reportedCorruption
+	}{
+		{
+			name:        "checksum mismatch",
+			corrupt:     func(data slice.Slice) { data[107+HeaderSize] = 'x' },
+			wantRecords: []slice.Slice{records[0], records[3]},
+			wantReports: []reportedCorruption{
+				{BlockSize - 107, "checksum not equal"},
+				{221, "get last type record, but not in_fragment"},
+			},
+		},
+		{
+			name:        "bad length",
+			corrupt:     func(data slice.Slice) { data[107+4], data[107+5] = 0xff, 0xff },
+			wantRecords: []slice.Slice{records[0], records[3]},
+			wantReports: []reportedCorruption{
+				{BlockSize - 107, "len(record) < header.length"},
+				{221, "get last type record, but not in_fragment"},
+			},
+		},
+		{
+			// c 与 f 的 LAST 片段在同一个块中, 一起被丢弃.
+			name:        "corrupted last fragment",
+			corrupt:     func(data slice.Slice) { data[BlockSize+HeaderSize] = 'x' },
+			wantRecords: []slice.Slice{records[0], records[1]},
+			wantReports: []reportedCorruption{
+				{228 + HeaderSize + 100, "checksum not equal"},
+				{BlockSize - 214 - HeaderSize, "error in middle of record"},
+			},
+		},
+		{
+			name:        "no corruption",
+			corrupt:     func(data slice.Slice) {},
+			wantRecords: records,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			data := generateLogFile(mockCtrl, records)
+			tt.corrupt(data)
+
+			var reports []reportedCorruption
+			mockReporter := mock_log.NewMockReporter(mockCtrl)
+			mockReporter.EXPECT().Corruption(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+				func(bytes int, err error) {
+					if !errors.Is(err, ErrCorruptedRecord) {
+						t.Errorf("Corruption() => want err = %v, get err = %v", ErrCorruptedRecord, err)
+					}
+					reports = append(reports, reportedCorruption{bytes, err.Error()})
+				},
+			)
+
+			// 宽松模式下跳过损坏的块, 从下一个块继续读取.
+			r := NewReader(newMockFileReader(mockCtrl, data), mockReporter, nil)
+			for _, want := range tt.wantRecords {
+				record, err := r.ReadRecord()
+				if err != nil || record.Compare(want) != slice.CMPSame {
+					t.Fatalf("ReadRecord() => unexpected result, len = %d, err = %v", len(record), err)
+				}
+			}
+			if _, err := r.ReadRecord(); !errors.Is(err, io.EOF) {
+				t.Errorf("ReadRecord() => want err = %v, get err = %v", io.EOF, err)
+			}
+
+			if len(reports) != len(tt.wantReports) {
+				t.Fatalf("reported corruptions = %v, want %v", reports, tt.wantReports)
+			}
+			for i, want := range tt.wantReports {
+				if reports[i].bytes != want.bytes || !strings.Contains(reports[i].keyWord, want.keyWord) {
+					t.Errorf("reported corruption = %v, want %v", reports[i], want)
+				}
+			}
+			if len(tt.wantReports) == 0 {
+				return
+			}
+
+			// 严格模式下返回第一个损坏.
+			reports = nil
+			r = NewReader(newMockFileReader(mockCtrl, data), mockReporter, &ReaderOptions{Strict: true})
+			for {
+				_, err := r.ReadRecord()
+				if errors.Is(err, ErrCorruptedRecord) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("ReadRecord() => want err = %v, get err = %v", ErrCorruptedRecord, err)
+				}
+			}
+			want := tt.wantReports[0]
+			if len(reports) != 1 || reports[0].bytes != want.bytes || !strings.Contains(reports[0].keyWord, want.keyWord) {
+				t.Errorf("reported corruptions = %v, want %v", reports, tt.wantReports[:1])
+			}
+		})
+	}
+}
+
+func TestReaderImpl_TruncatedTail(t *testing.T) {
+	records := resyncTestRecords()
+	tests := []struct {
+		name        string
+		size        int
+		wantRecords []slice.Slice
+	}{
+		{"truncated header", BlockSize + 228 + 3, records[:3]},
+		{"truncated data", BlockSize + 228 + HeaderSize + 50, records[:3]},
+		{"truncated last fragment", BlockSize + 100, records[:2]},
+		{"truncated first fragment", 1000, records[:2]},
+		{"zero filled tail", -1, records},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			data := generateLogFile(mockCtrl, records)
+			if tt.size >= 0 {
+				data = data[:tt.size]
+			} else {
+				// 预分配的文件区域全为 0.
+				data = append(data, make(slice.Slice, 1000)...)
+			}
+
+			// 写日志时崩溃导致的不完整 Record 不视为损坏.
+			mockReporter := mock_log.NewMockReporter(mockCtrl)
+			mockReporter.EXPECT().Corruption(gomock.Any(), gomock.Any()).Times(0)
+
+			for _, opts := range []*ReaderOptions{nil, {Strict: true}} {
+				r := NewReader(newMockFileReader(mockCtrl, data), mockReporter, opts)
+				for _, want := range tt.wantRecords {
+					record, err := r.ReadRecord()
+					if err != nil || record.Compare(want) != slice.CMPSame {
+						t.Fatalf("ReadRecord() => unexpected result, len = %d, err = %v", len(record), err)
+					}
+				}
+				if _, err := r.ReadRecord(); !errors.Is(err, io.EOF) {
+					t.Errorf("ReadRecord() => want err = %v, get err = %v", io.EOF, err)
+				}
+			}
+		})
+	}
+}
+
+func TestReaderImpl_InitialOffset(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// 各 Record 的偏移量:
+	// - a: 0, b: 10007.
+	// - c: 20014, FIRST/MIDDLE/LAST 片段分别位于 block 0/1/2, LAST 片段结束于 2*BlockSize+19035.
+	// - d: 2*BlockSize+19035, e: 2*BlockSize+19043, block 2 尾部留有 3 字节的填充.
+	// - f: 3*BlockSize.
+	records := []slice.Slice{
+		slice.Slice(strings.Repeat("a", 10000)),
+		slice.Slice(strings.Repeat("b", 10000)),
+		slice.Slice(strings.Repeat("c", 2*BlockSize-1000)),
+		slice.Slice("d"),
+		slice.Slice(strings.Repeat("e", 13715)),
+		slice.Slice(strings.Repeat("f", 100)),
+	}
+	offsets := []int{0, 10007, 20014, 2*BlockSize + 19035, 2*BlockSize + 19043, 3 * BlockSize}
+	data := generateLogFile(mockCtrl, records)
+
+	mockReporter := mock_log.NewMockReporter(mockCtrl)
+	mockReporter.EXPECT().Corruption(gomock.Any(), gomock.Any()).Times(0)
+
+	tests := []struct {
+		name          string
+		initialOffset int
+		// wantFirst 第一个读到的 Record 的下标.
+		wantFirst int
+	}{
+		{"start of file", 0, 0},
+		{"inside first record", 1, 1},
+		{"start of second record", offsets[1], 1},
+		{"inside second record", offsets[1] + 1, 2},
+		{"start of fragmented record", offsets[2], 2},
+		{"inside first fragment", offsets[2] + 1, 3},
+		{"inside middle fragment", BlockSize + 100, 3},
+		{"start of middle fragment", BlockSize, 3},
+		{"start of record after last fragment", offsets[3], 3},
+		{"inside record before trailer", offsets[4] + 1, 5},
+		{"block trailer", 3*BlockSize - 2, 5},
+		{"start of block", offsets[5], 5},
+		{"inside last record", offsets[5] + 1, len(records)},
+		{"beyond end of file", 10 * BlockSize, len(records)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReader(newMockFileReader(mockCtrl, data), mockReporter, &ReaderOptions{InitialOffset: tt.initialOffset})
+			for i := tt.wantFirst; i < len(records); i++ {
+				record, err := r.ReadRecord()
+				if err != nil || record.Compare(records[i]) != slice.CMPSame {
+					t.Fatalf("ReadRecord() #%d => unexpected result, len = %d, err = %v", i, len(record), err)
+				}
+				if r.GetLastRecordOffset() != offsets[i] {
+					t.Errorf("GetLastRecordOffset() #%d = %d, want %d", i, r.GetLastRecordOffset(), offsets[i])
+				}
+			}
+			if _, err := r.ReadRecord(); !errors.Is(err, io.EOF) {
+				t.Errorf("ReadRecord() => want err = %v, get err = %v", io.EOF, err)
+			}
+		})
+	}
+}
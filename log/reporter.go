@@ -2,5 +2,6 @@ package log
 
 // Reporter 用于报告读日志时发生的错误.
 type Reporter interface {
-	Corruption(err error)
+	// Corruption 报告日志中检测到的损坏, bytes 为因损坏而丢弃的字节数(估计值).
+	Corruption(bytes int, err error)
 }
@@ -1,7 +1,7 @@
 package log
 
 import (
-	"hash/crc32"
+	"encoding/binary"
 
 	"github.com/goleveldb/goleveldb/file"
 	"github.com/goleveldb/goleveldb/slice"
@@ -20,12 +20,21 @@ type WriterImpl struct {
 	blockOffset int // 当前块内偏移.
 }
 
+// NewWriter 创建写日志对象, fileWriter 中已有长度为 existingLength 的日志, 新的 Record 追加在其后.
+// 新建的日志文件 existingLength 为 0; 重新打开已有的日志继续写入时, 需传入文件的长度以对齐块.
+func NewWriter(fileWriter file.Writer, existingLength int) *WriterImpl {
+	return &WriterImpl{
+		fileWriter:  fileWriter,
+		blockOffset: existingLength % BlockSize,
+	}
+}
+
 // AddRecord 将data写入日志， 写入失败时返回 error.
+// data 为空时同样写入一个长度为 0 的 Record.
 func (w *WriterImpl) AddRecord(data slice.Slice) error {
 	left := len(data)
 
-	first := true
-	for left != 0 {
+	for first := true; first || left != 0; first = false {
 		freeSize := BlockSize - w.blockOffset
 
 		if freeSize < HeaderSize {
@@ -63,7 +72,6 @@ func (w *WriterImpl) AddRecord(data slice.Slice) error {
 			return errors.Wrap(err, "add record error")
 		}
 
-		first = false
 		data = data[writeLen:]
 		left -= writeLen
 	}
@@ -77,13 +85,11 @@ func (w *WriterImpl) writeRecord(data slice.Slice, recordType int) error {
 		return errors.New("data toolong, can not write")
 	}
 
-	crc := crc32.ChecksumIEEE(data)
 	lendata := len(data)
-	header := []byte{
-		byte(crc >> 24), byte(crc >> 16), byte(crc >> 8), byte(crc),
-		byte(lendata >> 8), byte(lendata),
-		byte(recordType),
-	}
+	header := make(slice.Slice, HeaderSize)
+	binary.LittleEndian.PutUint32(header[:4], recordChecksum(recordType, data))
+	binary.LittleEndian.PutUint16(header[4:6], uint16(lendata))
+	header[6] = byte(recordType)
 
 	if err := w.fileWriter.Append(header); err != nil {
 		return err
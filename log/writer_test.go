@@ -1,13 +1,15 @@
 package log
 
 import (
+	"encoding/binary"
 	"errors"
-	"hash/crc32"
+	"io"
 	"testing"
 
 	"github.com/golang/mock/gomock"
 	"github.com/goleveldb/goleveldb/file"
 	mockfile "github.com/goleveldb/goleveldb/internal/mock/file"
+	"github.com/goleveldb/goleveldb/internal/mock/mock_log"
 	"github.com/goleveldb/goleveldb/slice"
 )
 
@@ -50,7 +52,7 @@ func TestWriterImpl_AddRecord(t *testing.T) {
 			wantAppendList: []record{
 				{
 					header: recordHeader{
-						crc:        crc32.ChecksumIEEE(make(slice.Slice, BlockSize-HeaderSize)),
+						crc:        recordChecksum(RecordFullType, make(slice.Slice, BlockSize-HeaderSize)),
 						length:     BlockSize - HeaderSize,
 						recordType: RecordFullType,
 					},
@@ -58,6 +60,24 @@ func TestWriterImpl_AddRecord(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "test add empty Record",
+			fields: fields{
+				fileWriter:  mockWriter,
+				blockOffset: 0,
+			},
+			data: slice.Slice{},
+			wantAppendList: []record{
+				{
+					header: recordHeader{
+						crc:        recordChecksum(RecordFullType, nil),
+						length:     0,
+						recordType: RecordFullType,
+					},
+					data: slice.Slice{},
+				},
+			},
+		},
 		{
 			name: "test add two part Record",
 			fields: fields{
@@ -68,7 +88,7 @@ func TestWriterImpl_AddRecord(t *testing.T) {
 			wantAppendList: []record{
 				{
 					header: recordHeader{
-						crc:        crc32.ChecksumIEEE(make(slice.Slice, BlockSize-HeaderSize)),
+						crc:        recordChecksum(RecordFirstType, make(slice.Slice, BlockSize-HeaderSize)),
 						length:     BlockSize - HeaderSize,
 						recordType: RecordFirstType,
 					},
@@ -76,7 +96,7 @@ func TestWriterImpl_AddRecord(t *testing.T) {
 				},
 				{
 					header: recordHeader{
-						crc:        crc32.ChecksumIEEE(make(slice.Slice, HeaderSize)),
+						crc:        recordChecksum(RecordLastType, make(slice.Slice, HeaderSize)),
 						length:     HeaderSize,
 						recordType: RecordLastType,
 					},
@@ -94,7 +114,7 @@ func TestWriterImpl_AddRecord(t *testing.T) {
 			wantAppendList: []record{
 				{
 					header: recordHeader{
-						crc:        crc32.ChecksumIEEE(make(slice.Slice, BlockSize-HeaderSize)),
+						crc:        recordChecksum(RecordFirstType, make(slice.Slice, BlockSize-HeaderSize)),
 						length:     BlockSize - HeaderSize,
 						recordType: RecordFirstType,
 					},
@@ -102,7 +122,7 @@ func TestWriterImpl_AddRecord(t *testing.T) {
 				},
 				{
 					header: recordHeader{
-						crc:        crc32.ChecksumIEEE(make(slice.Slice, BlockSize-HeaderSize)),
+						crc:        recordChecksum(RecordMiddleType, make(slice.Slice, BlockSize-HeaderSize)),
 						length:     BlockSize - HeaderSize,
 						recordType: RecordMiddleType,
 					},
@@ -110,7 +130,7 @@ func TestWriterImpl_AddRecord(t *testing.T) {
 				},
 				{
 					header: recordHeader{
-						crc:        crc32.ChecksumIEEE(make(slice.Slice, HeaderSize)),
+						crc:        recordChecksum(RecordLastType, make(slice.Slice, HeaderSize)),
 						length:     HeaderSize,
 						recordType: RecordLastType,
 					},
@@ -134,10 +154,10 @@ func TestWriterImpl_AddRecord(t *testing.T) {
 						recordType   = expectHeader.recordType
 					)
 
-					cmpList := make(slice.Slice, 0)
-					cmpList = append(cmpList, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
-					cmpList = append(cmpList, byte(length>>8), byte(length))
-					cmpList = append(cmpList, byte(recordType))
+					cmpList := make(slice.Slice, HeaderSize)
+					binary.LittleEndian.PutUint32(cmpList[:4], crc)
+					binary.LittleEndian.PutUint16(cmpList[4:6], uint16(length))
+					cmpList[6] = byte(recordType)
 
 					expData = cmpList
 				}
@@ -161,3 +181,53 @@ func TestWriterImpl_AddRecord(t *testing.T) {
 		})
 	}
 }
+
+func TestNewWriter_ExistingLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []slice.Slice
+	}{
+		{"empty log", nil},
+		{"inside first block", []slice.Slice{make(slice.Slice, 100)}},
+		{"block trailer", []slice.Slice{make(slice.Slice, BlockSize-HeaderSize-3)}},
+		{"block boundary", []slice.Slice{make(slice.Slice, BlockSize-HeaderSize)}},
+		{"after fragmented record", []slice.Slice{make(slice.Slice, 2*BlockSize)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			data := generateLogFile(mockCtrl, tt.existing)
+			mockWriter := mockfile.NewMockWriter(mockCtrl)
+			mockWriter.EXPECT().Append(gomock.Any()).AnyTimes().DoAndReturn(func(s slice.Slice) error {
+				data = append(data, s...)
+
+				return nil
+			})
+			mockWriter.EXPECT().Flush().AnyTimes().Return(nil)
+
+			// 重新打开日志, 在已有内容之后继续写入.
+			appended := []slice.Slice{slice.Slice("foo"), make(slice.Slice, BlockSize), slice.Slice("bar")}
+			w := NewWriter(mockWriter, len(data))
+			for _, record := range appended {
+				if err := w.AddRecord(record); err != nil {
+					t.Fatalf("AddRecord() => get err = %v", err)
+				}
+			}
+
+			mockReporter := mock_log.NewMockReporter(mockCtrl)
+			mockReporter.EXPECT().Corruption(gomock.Any(), gomock.Any()).Times(0)
+			r := NewReader(newMockFileReader(mockCtrl, data), mockReporter, nil)
+			for _, want := range append(tt.existing, appended...) {
+				record, err := r.ReadRecord()
+				if err != nil || record.Compare(want) != slice.CMPSame {
+					t.Fatalf("ReadRecord() => unexpected result, len = %d, err = %v", len(record), err)
+				}
+			}
+			if _, err := r.ReadRecord(); !errors.Is(err, io.EOF) {
+				t.Errorf("ReadRecord() => want err = %v, get err = %v", io.EOF, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package memtable
+
+import (
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+const (
+	// arenaBlockSize 分配记录使用的块大小.
+	arenaBlockSize = 4096
+	// arenaNodeBlockLen 每次分配的节点数.
+	arenaNodeBlockLen = 256
+	// arenaPointerBlockLen 每次分配的 next 指针数.
+	arenaPointerBlockLen = 1024
+)
+
+// arena 以块为单位批量分配记录与 skiplist 节点, 减少小对象的分配次数与 GC 压力.
+// 分配出的内存不会单独释放, 在内存表不再被引用后一起回收.
+// 分配只由写者进行, memoryUsage 可以与分配并发调用.
+type arena struct {
+	// 当前块中尚未分配的部分.
+	bytes    []byte
+	nodes    []node
+	pointers []unsafe.Pointer
+
+	// usage 已分配的字节数.
+	usage int64
+}
+
+// allocate 分配 n 字节, 返回的 slice 容量为 n.
+func (a *arena) allocate(n int) []byte {
+	a.addUsage(n)
+	if n > len(a.bytes) {
+		// 较大的记录单独分配, 避免浪费当前块剩余的空间.
+		if n > arenaBlockSize/4 {
+			return make([]byte, n)
+		}
+		a.bytes = make([]byte, arenaBlockSize)
+	}
+
+	res := a.bytes[:n:n]
+	a.bytes = a.bytes[n:]
+
+	return res
+}
+
+// newNode 分配 key 对应的高度为 height 的节点.
+func (a *arena) newNode(key slice.Slice, height int) *node {
+	if len(a.nodes) == 0 {
+		a.nodes = make([]node, arenaNodeBlockLen)
+	}
+	n := &a.nodes[0]
+	a.nodes = a.nodes[1:]
+
+	if height > len(a.pointers) {
+		a.pointers = make([]unsafe.Pointer, arenaPointerBlockLen)
+	}
+	n.key = key
+	n.next = a.pointers[:height:height]
+	a.pointers = a.pointers[height:]
+	a.addUsage(int(unsafe.Sizeof(node{})) + height*int(unsafe.Sizeof(unsafe.Pointer(nil))))
+
+	return n
+}
+
+func (a *arena) addUsage(n int) {
+	atomic.AddInt64(&a.usage, int64(n))
+}
+
+// memoryUsage 返回已分配的字节数.
+func (a *arena) memoryUsage() int {
+	return int(atomic.LoadInt64(&a.usage))
+}
@@ -0,0 +1,73 @@
+package memtable
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+func TestArena_Allocate(t *testing.T) {
+	a := &arena{}
+	if a.memoryUsage() != 0 {
+		t.Fatalf("memoryUsage() = %d, want 0", a.memoryUsage())
+	}
+
+	// 覆盖同一块内的分配、块剩余空间不足时切换新块, 以及单独分配的大记录.
+	sizes := []int{1, 100, 3000, 1000, arenaBlockSize, 0, 17}
+	var allocated [][]byte
+	total := 0
+	for i, size := range sizes {
+		b := a.allocate(size)
+		if len(b) != size || cap(b) != size {
+			t.Fatalf("allocate(%d) => len %d, cap %d", size, len(b), cap(b))
+		}
+		for j := range b {
+			b[j] = byte(i)
+		}
+		allocated = append(allocated, b)
+		total += size
+	}
+	if a.memoryUsage() != total {
+		t.Errorf("memoryUsage() = %d, want %d", a.memoryUsage(), total)
+	}
+
+	// 各次分配的内存互不重叠.
+	for i, b := range allocated {
+		for _, c := range b {
+			if c != byte(i) {
+				t.Fatalf("allocation %d is overwritten", i)
+			}
+		}
+	}
+}
+
+func TestArena_NewNode(t *testing.T) {
+	a := &arena{}
+	var nodes []*node
+	for i := 0; i < arenaNodeBlockLen*2; i++ {
+		height := i%maxHeight + 1
+		n := a.newNode(slice.Slice{byte(i)}, height)
+		if len(n.next) != height || cap(n.next) != height {
+			t.Fatalf("newNode(%d) => next len %d, cap %d", height, len(n.next), cap(n.next))
+		}
+		for level := range n.next {
+			n.storeNext(level, n)
+		}
+		nodes = append(nodes, n)
+	}
+
+	for i, n := range nodes {
+		if n.key[0] != byte(i) {
+			t.Fatalf("node %d key = %v", i, n.key)
+		}
+		for level := range n.next {
+			if n.loadNext(level) != n {
+				t.Fatalf("node %d next[%d] is overwritten", i, level)
+			}
+		}
+	}
+	if a.memoryUsage() < len(nodes)*int(unsafe.Sizeof(node{})) {
+		t.Errorf("memoryUsage() = %d, want >= %d", a.memoryUsage(), len(nodes)*int(unsafe.Sizeof(node{})))
+	}
+}
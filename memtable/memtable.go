@@ -5,36 +5,49 @@ import (
 	"encoding/binary"
 	"errors"
 
+	"github.com/goleveldb/goleveldb/comparator"
+	"github.com/goleveldb/goleveldb/ikey"
 	"github.com/goleveldb/goleveldb/slice"
 )
 
 // ErrNotFound 内存表中无法找到相应记录.
 var ErrNotFound = errors.New("key not found")
 
-const (
-	// int64类型占用字节数.
-	int64Len = 8
+// int64类型占用字节数, internal key 末尾的 tag 占用该长度.
+const int64Len = 8
+
+// LookupResult 在内存表中查找 key 的结果.
+type LookupResult int
 
-	// 当typeValue为1时数据有效.
-	typeValue  = 1
-	typeDelete = 0
+const (
+	// NotPresent 内存表中没有 key 的记录, 需要继续在更旧的数据中查找.
+	NotPresent LookupResult = iota
+	// Found 找到了 key 对应的 value.
+	Found
+	// Deleted key 最新的记录是删除标记, 无需继续查找.
+	Deleted
 )
 
 // Memtable 在内存中存储kv数据.
+// 写操作(Insert, Delete)需要由调用者保证互斥, 读操作无需加锁, 可以与写操作并发进行.
 type Memtable struct {
 	table *skiplist
+	cmp   *ikey.Comparator
+	// arena 分配记录与 skiplist 节点.
+	arena *arena
 }
 
-// New 创建并初始化 Memtable.
-func New() *Memtable {
-	return &Memtable{
-		table: &skiplist{
-			header: &node{
-				next: [maxHeight]*node{},
-			},
-			cmp: compareKey,
-		},
-	}
+// New 创建并初始化 Memtable, user key 按照 cmp 定义的顺序排列.
+func New(cmp comparator.Comparator) *Memtable {
+	t := &Memtable{cmp: ikey.NewComparator(cmp), arena: &arena{}}
+	t.table = newSkiplist(t.compareKey, t.arena)
+
+	return t
+}
+
+// ApproximateMemoryUsage 返回内存表中的记录与节点占用的字节数, 空的内存表返回 0. 可以与写操作并发调用.
+func (t *Memtable) ApproximateMemoryUsage() int {
+	return t.arena.memoryUsage()
 }
 
 // Iterator 创建用于遍历内存表的迭代器.
@@ -43,93 +56,83 @@ func (t *Memtable) Iterator() *Iterator {
 }
 
 // Insert 向内存表中插入一条包含序列号, valueType 的kv记录.
-// 插入的数据按照以下方式排列:
-// - key length (varint).
-// - key data.
-// - sequenceNumber & valueType (uint64).
-// - value length (varint).
+// 插入的数据按照以下方式排列, 与 LevelDB 的内存表相同:
+// - internal key length (uvarint, 无符号 varint 编码, 见 binary.PutUvarint).
+// - internal key data: user key & sequenceNumber & valueType, 见 ikey.InternalKey.
+// - value length (uvarint).
 // - value data.
-func (t *Memtable) Insert(sequenceNumber uint64, valueType byte, key, value slice.Slice) error {
-	totalLen := varintLen(len(key)) + len(key) + int64Len + varintLen(len(value)) + len(value)
-	record := make([]byte, totalLen)
-	curPos := 0
-
-	// 添加 varint 编码key长度.
-	byteLen := binary.PutVarint(record[curPos:], int64(len(key)))
-	curPos += byteLen
+func (t *Memtable) Insert(sequenceNumber uint64, valueType ikey.ValueType, key, value slice.Slice) error {
+	internalKeyLen := len(key) + int64Len
+	totalLen := varintLen(internalKeyLen) + internalKeyLen + varintLen(len(value)) + len(value)
+	record := t.arena.allocate(totalLen)
 
-	// 添加 key 数据.
-	copy(record[curPos:curPos+len(key)], key)
-	curPos += len(key)
-
-	binary.BigEndian.PutUint64(record[curPos:], ((sequenceNumber << 8) | uint64(valueType)))
-	curPos += int64Len
+	// 添加 varint 编码 internal key 长度.
+	curPos := binary.PutUvarint(record, uint64(internalKeyLen))
+	// 添加 internal key 数据.
+	ikey.Append(record[:curPos], key, sequenceNumber, valueType) // record 容量足够, 直接写入 record 中.
+	curPos += internalKeyLen
 
 	// 添加 varint 编码value长度.
-	byteLen = binary.PutVarint(record[curPos:], int64(len(value)))
-	curPos += byteLen
+	curPos += binary.PutUvarint(record[curPos:], uint64(len(value)))
 	// 添加 value 数据.
-	copy(record[curPos:curPos+len(value)], value)
+	copy(record[curPos:], value)
 
 	return t.table.insert(record)
 }
 
-// Get 从内存表中获取key对应的value.
-func (t *Memtable) Get(key slice.Slice) (value slice.Slice, err error) {
-	record, err := t.seekByKey(key)
-	if err != nil {
-		return nil, err
-	}
+// Delete 向内存表中插入 key 的删除标记.
+func (t *Memtable) Delete(sequenceNumber uint64, key slice.Slice) error {
+	return t.Insert(sequenceNumber, ikey.TypeDeletion, key, nil)
+}
 
-	// 检查获取的 record 中的 key 与期望的 key 是否相同.
-	recordKey, keyLength := loadKey(record)
-	if key.Compare(recordKey) != slice.CMPSame {
+// Get 从内存表中获取key对应的最新的value, key 不存在或已被删除时返回 ErrNotFound.
+func (t *Memtable) Get(key slice.Slice) (value slice.Slice, err error) {
+	value, res := t.Lookup(key, ikey.MaxSequenceNumber)
+	if res != Found {
 		return nil, ErrNotFound
 	}
 
-	return parseTagAndValue(record[keyLength:])
+	return value, nil
 }
 
-// seekByKey 通过 key 在内存表中查找 record.
-func (t *Memtable) seekByKey(key slice.Slice) (value slice.Slice, err error) {
-	// put key.
-	seekKey := make([]byte, varintLen(len(key)))
-	binary.PutVarint(seekKey, int64(len(key)))
-	seekKey = append(seekKey, key...)
-
-	// put tag.
-	tag := make([]byte, int64Len)
-	for i := 0; i < int64Len; i++ {
-		tag[i] = 0xff
+// Lookup 在序列号不大于 sequenceNumber 的记录中查找 key 最新的记录, 忽略之后写入的记录, 用于读取快照.
+// 仅当结果为 Found 时返回 value.
+func (t *Memtable) Lookup(key slice.Slice, sequenceNumber uint64) (slice.Slice, LookupResult) {
+	// 第一条不小于 lookupKey 的 record, 即 key 相同时序列号不大于 sequenceNumber 的最新的 record.
+	iter := t.table.iterator()
+	iter.Seek(ikey.MakeLookupKey(key, sequenceNumber).MemtableKey())
+	if !iter.Valid() {
+		return nil, NotPresent
+	}
+	record, err := iter.Key()
+	if err != nil {
+		return nil, NotPresent
 	}
-	seekKey = append(seekKey, tag...)
 
-	iter := t.table.iterator()
-	iter.Seek(seekKey)
+	// 检查获取的 record 中的 user key 与期望的 key 是否相同.
+	internalKey, value := ParseRecord(record)
+	if t.cmp.UserComparator().Compare(key, internalKey.UserKey()) != slice.CMPSame {
+		return nil, NotPresent
+	}
+	if internalKey.ValueType() != ikey.TypeValue {
+		return nil, Deleted
+	}
 
-	return iter.Key()
+	return value, Found
 }
 
-// parseTagAndValue 解析值.
-func parseTagAndValue(record slice.Slice) (slice.Slice, error) {
-	// 读取 sequenceNumber & valueType.
-	tag := binary.BigEndian.Uint64(record)
-	record = record[int64Len:]
-
-	if tag&0xff != typeValue {
-		return nil, ErrNotFound
-	}
+// ParseRecord 解析内存表迭代器返回的 record, 返回 internal key 及 value.
+func ParseRecord(record slice.Slice) (key ikey.InternalKey, value slice.Slice) {
+	internalKey, keyLength := loadKey(record)
+	record = record[keyLength:]
 
-	valueLength, varintLen := binary.Varint(record)
-	record = record[varintLen:]
-	if len(record) != int(valueLength) {
-		return nil, ErrNotFound
-	}
+	valueLength, varintLength := binary.Uvarint(record)
+	value = record[varintLength : varintLength+int(valueLength)]
 
-	return record, nil
+	return ikey.InternalKey(internalKey), value
 }
 
-// varintLen 返回 num 转化为 varintLen 类型后的字节数.
+// varintLen 返回 num 使用 uvarint 编码后的字节数.
 func varintLen(num int) int {
 	res := 0
 	for num != 0 {
@@ -145,29 +148,17 @@ func varintLen(num int) int {
 	return res
 }
 
-// compareKey 比较两个key的大小.
-func compareKey(a, b slice.Slice) int {
-	keyA, lenA := loadKey(a)
-	keyB, lenB := loadKey(b)
-
-	// not equal, return result.
-	if res := keyA.Compare(keyB); res != 0 {
-		return res
-	}
-
-	// key equal, sequenceNumber 大的排在前面.
-	if binary.BigEndian.Uint64(a[lenA:]) > binary.BigEndian.Uint64(b[lenB:]) {
-		return slice.CMPSmaller
-	} else if binary.BigEndian.Uint64(a[lenA:]) < binary.BigEndian.Uint64(b[lenB:]) {
-		return slice.CMPLarger
-	}
+// compareKey 比较两个record的大小, 使用 internal key 比较器比较 internal key 部分.
+func (t *Memtable) compareKey(a, b slice.Slice) int {
+	keyA, _ := loadKey(a)
+	keyB, _ := loadKey(b)
 
-	return slice.CMPSame
+	return t.cmp.Compare(keyA, keyB)
 }
 
-// 从一条record中读取 key 部分(指向的数据段相同).
+// 从一条record中读取 internal key 部分(指向的数据段相同).
 func loadKey(record slice.Slice) (slice.Slice, int) {
-	keyLength, varintLength := binary.Varint(record)
+	keyLength, varintLength := binary.Uvarint(record)
 
 	return record[varintLength : varintLength+int(keyLength)], varintLength + int(keyLength)
 }
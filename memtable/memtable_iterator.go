@@ -0,0 +1,85 @@
+package memtable
+
+import (
+	"encoding/binary"
+
+	"github.com/goleveldb/goleveldb/common"
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+// memtableIterator 按 internal key 的顺序遍历内存表, Key 返回 internal key, Value 返回对应的 value.
+type memtableIterator struct {
+	it *Iterator
+	// 当前 record 中的 internal key 与 value, 迭代器无效时为 nil.
+	key, value slice.Slice
+	// Find 时用于构造带有长度前缀的 internal key.
+	buf []byte
+}
+
+var _ common.Iterator = (*memtableIterator)(nil)
+
+// NewIterator 创建按 internal key 顺序遍历内存表的迭代器, 见 common.Iterator.
+// 迭代器可以与写操作并发使用, 遍历时可能看到创建迭代器之后插入的记录.
+func (t *Memtable) NewIterator() common.Iterator {
+	return &memtableIterator{it: t.table.iterator()}
+}
+
+func (i *memtableIterator) Success() bool {
+	return i.it.Valid()
+}
+
+func (i *memtableIterator) Next() {
+	i.it.Next()
+	i.parse()
+}
+
+func (i *memtableIterator) Prev() {
+	i.it.Prev()
+	i.parse()
+}
+
+// Find 访问第一条 internal key 不小于 key 的记录.
+func (i *memtableIterator) Find(key slice.Slice) {
+	i.buf = i.buf[:0]
+	var lenBuf [binary.MaxVarintLen64]byte
+	i.buf = append(i.buf, lenBuf[:binary.PutUvarint(lenBuf[:], uint64(len(key)))]...)
+	i.buf = append(i.buf, key...)
+	i.it.Seek(i.buf)
+	i.parse()
+}
+
+func (i *memtableIterator) SeekToFirst() {
+	i.it.SeekToFirst()
+	i.parse()
+}
+
+func (i *memtableIterator) SeekToLast() {
+	i.it.SeekToLast()
+	i.parse()
+}
+
+func (i *memtableIterator) Key() slice.Slice {
+	return i.key
+}
+
+func (i *memtableIterator) Value() slice.Slice {
+	return i.value
+}
+
+func (i *memtableIterator) Err() error {
+	return nil
+}
+
+func (i *memtableIterator) Release() {}
+
+// parse 解析当前 record.
+func (i *memtableIterator) parse() {
+	record, err := i.it.Key()
+	if err != nil {
+		i.key, i.value = nil, nil
+		return
+	}
+
+	key, value := ParseRecord(record)
+	i.key, i.value = slice.Slice(key), value
+}
@@ -0,0 +1,75 @@
+package memtable
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/goleveldb/goleveldb/comparator"
+	"github.com/goleveldb/goleveldb/ikey"
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+func TestMemtable_NewIterator(t *testing.T) {
+	table := New(comparator.Bytewise)
+	if err := table.Insert(2, ikey.TypeValue, slice.Slice("foo"), slice.Slice("v2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Delete(4, slice.Slice("foo")); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Insert(3, ikey.TypeValue, slice.Slice("bar"), slice.Slice("v3")); err != nil {
+		t.Fatal(err)
+	}
+
+	// user key 从小到大, 同一个 user key 的记录按序列号从新到旧.
+	want := []string{"bar@3:v3", "foo@4:", "foo@2:v2"}
+	entry := func(it interface {
+		Key() slice.Slice
+		Value() slice.Slice
+	}) string {
+		key := ikey.InternalKey(it.Key())
+		return fmt.Sprintf("%s@%d:%s", key.UserKey(), key.Sequence(), it.Value())
+	}
+
+	it := table.NewIterator()
+	defer it.Release()
+	var got []string
+	for it.SeekToFirst(); it.Success(); it.Next() {
+		got = append(got, entry(it))
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("forward = %v, want %v", got, want)
+	}
+
+	got = got[:0]
+	for it.SeekToLast(); it.Success(); it.Prev() {
+		got = append([]string{entry(it)}, got...)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("backward = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		key      slice.Slice
+		sequence uint64
+		want     string // 为空表示迭代器无效
+	}{
+		{key: slice.Slice("a"), sequence: ikey.MaxSequenceNumber, want: "bar@3:v3"},
+		{key: slice.Slice("foo"), sequence: ikey.MaxSequenceNumber, want: "foo@4:"},
+		{key: slice.Slice("foo"), sequence: 3, want: "foo@2:v2"},
+		{key: slice.Slice("foo"), sequence: 1},
+		{key: slice.Slice("zoo"), sequence: ikey.MaxSequenceNumber},
+	}
+	for _, tt := range tests {
+		it.Find(slice.Slice(ikey.MakeInternalKey(tt.key, tt.sequence, ikey.TypeForSeek)))
+		if tt.want == "" {
+			if it.Success() {
+				t.Errorf("Find(%s@%d) = %s, want invalid", tt.key, tt.sequence, entry(it))
+			}
+			continue
+		}
+		if !it.Success() || entry(it) != tt.want {
+			t.Errorf("Find(%s@%d) = %v, want %s", tt.key, tt.sequence, it.Success(), tt.want)
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package memtable
 
 import (
+	"errors"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/goleveldb/goleveldb/comparator"
+	"github.com/goleveldb/goleveldb/ikey"
 	"github.com/goleveldb/goleveldb/slice"
 )
 
@@ -10,7 +15,7 @@ const methodGet = "get"
 
 type insertArg struct {
 	sequenceNumber uint64
-	valueType      byte
+	valueType      ikey.ValueType
 	key            slice.Slice
 	value          slice.Slice
 
@@ -38,7 +43,7 @@ type memtableTestPoint struct {
 }
 
 func TestMemtable_Memtable_Iterator(t *testing.T) {
-	New().Iterator()
+	New(comparator.Bytewise).Iterator()
 }
 
 func TestMemtable_Memtable_All(t *testing.T) {
@@ -60,7 +65,7 @@ func TestMemtable_Memtable_All(t *testing.T) {
 					method: methodInsert,
 					insertArg: &insertArg{
 						sequenceNumber: 1,
-						valueType:      typeValue,
+						valueType:      ikey.TypeValue,
 						key:            slice.Slice("foo"),
 						value:          slice.Slice("bar"),
 						wantErr:        false,
@@ -80,7 +85,7 @@ func TestMemtable_Memtable_All(t *testing.T) {
 					method: methodInsert,
 					insertArg: &insertArg{
 						sequenceNumber: 2,
-						valueType:      typeDelete, // 0 means delete
+						valueType:      ikey.TypeDeletion,
 						key:            slice.Slice("foo"),
 						wantErr:        false,
 					},
@@ -104,7 +109,7 @@ func TestMemtable_Memtable_All(t *testing.T) {
 					method: methodInsert,
 					insertArg: &insertArg{
 						sequenceNumber: 1,
-						valueType:      typeValue,
+						valueType:      ikey.TypeValue,
 						key:            slice.Slice("foo"),
 						value:          slice.Slice("bar"),
 						wantErr:        false,
@@ -124,7 +129,7 @@ func TestMemtable_Memtable_All(t *testing.T) {
 					method: methodInsert,
 					insertArg: &insertArg{
 						sequenceNumber: 2,
-						valueType:      typeValue,
+						valueType:      ikey.TypeValue,
 						key:            slice.Slice("foo"),
 						value:          slice.Slice("var"),
 						wantErr:        false,
@@ -150,7 +155,7 @@ func TestMemtable_Memtable_All(t *testing.T) {
 
 // runTestPoint 运行测试点.
 func runTestPoint(t *testing.T, testPoint *memtableTestPoint) {
-	table := New()
+	table := New(comparator.Bytewise)
 
 	t.Run(testPoint.name, func(t *testing.T) {
 		for _, opera := range testPoint.operations {
@@ -171,3 +176,158 @@ func runTestPoint(t *testing.T, testPoint *memtableTestPoint) {
 		}
 	})
 }
+
+func TestMemtable_Memtable_Comparator(t *testing.T) {
+	table := New(comparator.Reverse(comparator.Bytewise))
+	keys := []string{"a", "c", "b"}
+	for i, key := range keys {
+		if err := table.Insert(uint64(i+1), ikey.TypeValue, slice.Slice(key), slice.Slice(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 逆序比较器下, key 应当按照 c, b, a 的顺序排列.
+	wantOrder := []string{"c", "b", "a"}
+	it := table.Iterator()
+	for _, want := range wantOrder {
+		record, err := it.Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, _ := ParseRecord(record); string(got.UserKey()) != want {
+			t.Errorf("Memtable iterator => want key %s, get %s", want, string(got))
+		}
+		it.Next()
+	}
+
+	for _, key := range keys {
+		if got, err := table.Get(slice.Slice(key)); err != nil || string(got) != key {
+			t.Errorf("Memtable.Get(%s) => get = (%s, %v)", key, string(got), err)
+		}
+	}
+}
+
+func TestParseRecord(t *testing.T) {
+	table := New(comparator.Bytewise)
+	if err := table.Insert(7, ikey.TypeValue, slice.Slice("foo"), slice.Slice("bar")); err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := table.Iterator().Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, value := ParseRecord(record)
+	if string(key.UserKey()) != "foo" || key.Sequence() != 7 || key.ValueType() != ikey.TypeValue || string(value) != "bar" {
+		t.Errorf("ParseRecord() = (%s, %d, %d, %s), want (foo, 7, %d, bar)",
+			string(key.UserKey()), key.Sequence(), key.ValueType(), string(value), ikey.TypeValue)
+	}
+}
+
+func TestMemtable_Memtable_LongRecords(t *testing.T) {
+	table := New(comparator.Bytewise)
+	// 覆盖长度需要 1, 2, 3 字节 varint 编码的 key 与 value.
+	lengths := []int{0, 1, 63, 64, 100, 127, 128, 300, 1 << 14, 1<<14 + 1}
+	for i, length := range lengths {
+		key := strings.Repeat("k", length) + strconv.Itoa(i)
+		value := strings.Repeat("v", length)
+		if err := table.Insert(uint64(i+1), ikey.TypeValue, slice.Slice(key), slice.Slice(value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, length := range lengths {
+		key := strings.Repeat("k", length) + strconv.Itoa(i)
+		if got, err := table.Get(slice.Slice(key)); err != nil || string(got) != strings.Repeat("v", length) {
+			t.Errorf("Memtable.Get() with key length %d => get value length %d, err %v", len(key), len(got), err)
+		}
+	}
+}
+
+func TestMemtable_RecordLayout(t *testing.T) {
+	table := New(comparator.Bytewise)
+	// internal key 长 64 字节, value 长 1 字节: uvarint 编码均为 1 字节, 有符号(zigzag)编码则需要 2 字节.
+	key, value := strings.Repeat("k", 64-int64Len), "v"
+	if err := table.Insert(7, ikey.TypeValue, slice.Slice(key), slice.Slice(value)); err != nil {
+		t.Fatal(err)
+	}
+
+	it := table.Iterator()
+	record, err := it.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte{64}, ikey.MakeInternalKey(slice.Slice(key), 7, ikey.TypeValue)...)
+	want = append(want, 1, 'v')
+	if string(record) != string(want) {
+		t.Errorf("record = %v, want %v", record, want)
+	}
+}
+
+func TestMemtable_Lookup(t *testing.T) {
+	table := New(comparator.Bytewise)
+	if err := table.Insert(2, ikey.TypeValue, slice.Slice("foo"), slice.Slice("v2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Delete(4, slice.Slice("foo")); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Insert(6, ikey.TypeValue, slice.Slice("foo"), slice.Slice("v6")); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Delete(3, slice.Slice("bar")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key            string
+		sequenceNumber uint64
+		want           string
+		wantRes        LookupResult
+	}{
+		{key: "foo", sequenceNumber: 1, wantRes: NotPresent},
+		{key: "foo", sequenceNumber: 2, want: "v2", wantRes: Found},
+		{key: "foo", sequenceNumber: 3, want: "v2", wantRes: Found},
+		{key: "foo", sequenceNumber: 4, wantRes: Deleted},
+		{key: "foo", sequenceNumber: 5, wantRes: Deleted},
+		{key: "foo", sequenceNumber: 6, want: "v6", wantRes: Found},
+		{key: "foo", sequenceNumber: ikey.MaxSequenceNumber, want: "v6", wantRes: Found},
+		{key: "bar", sequenceNumber: 2, wantRes: NotPresent},
+		{key: "bar", sequenceNumber: ikey.MaxSequenceNumber, wantRes: Deleted},
+		{key: "baz", sequenceNumber: ikey.MaxSequenceNumber, wantRes: NotPresent},
+	}
+	for _, tt := range tests {
+		got, res := table.Lookup(slice.Slice(tt.key), tt.sequenceNumber)
+		if string(got) != tt.want || res != tt.wantRes {
+			t.Errorf("Lookup(%s, %d) = (%s, %v), want (%s, %v)", tt.key, tt.sequenceNumber, got, res, tt.want, tt.wantRes)
+		}
+	}
+
+	if _, err := table.Get(slice.Slice("bar")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(bar) => get err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemtable_ApproximateMemoryUsage(t *testing.T) {
+	table := New(comparator.Bytewise)
+	if got := table.ApproximateMemoryUsage(); got != 0 {
+		t.Fatalf("ApproximateMemoryUsage() of empty memtable = %d, want 0", got)
+	}
+
+	last := 0
+	for i := 0; i < 1000; i++ {
+		key, value := slice.Slice("key_"+strconv.Itoa(i)), slice.Slice(strings.Repeat("v", 100))
+		if err := table.Insert(uint64(i+1), ikey.TypeValue, key, value); err != nil {
+			t.Fatal(err)
+		}
+
+		// 每条记录至少占用 key, value 与 tag 的大小.
+		got := table.ApproximateMemoryUsage()
+		if got < last+len(key)+len(value)+8 {
+			t.Fatalf("ApproximateMemoryUsage() = %d after insert, want >= %d", got, last+len(key)+len(value)+8)
+		}
+		last = got
+	}
+}
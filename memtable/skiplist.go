@@ -3,26 +3,66 @@ package memtable
 import (
 	"errors"
 	"math/rand"
+	"sync/atomic"
+	"unsafe"
 
 	"github.com/goleveldb/goleveldb/slice"
 )
 
-// skiplist 最大高度.
-const maxHeight = 12
+const (
+	// skiplist 最大高度.
+	maxHeight = 12
+	// branching 节点高度每增加一层的概率为 1/branching.
+	branching = 4
+)
 
 // compareKeyMethod 比较两个 slice.
 type compareKeyMethod func(slice.Slice, slice.Slice) int
 
 // skiplist 实现跳表，进行kv存储.
+// 写操作需要由调用者保证互斥, 读操作无需加锁, 可以与一个写操作并发进行:
+// 新节点在完全初始化后才通过原子写入链接到各层, 读者通过原子读取访问 next 指针,
+// 总能看到完整的节点.
+// 节点插入后不会被删除.
 type skiplist struct {
 	header *node
 	cmp    compareKeyMethod
+	// arena 分配新节点.
+	arena *arena
+
+	// height 当前所有节点的最大高度, 只由写者修改.
+	// 读者可能读到新的高度但还未看到该层链接的节点, 此时 header 在该层的 next 为 nil, 不影响查找结果.
+	height int32
+	// rnd 生成节点高度, 只由写者使用.
+	rnd *rand.Rand
 }
 
 // node 描述 skiplist 节点.
 type node struct {
-	key  slice.Slice
-	next [maxHeight]*node
+	key slice.Slice
+	// next 各层的下一个节点(*node), 长度为节点的高度, 通过 loadNext 与 storeNext 原子地访问.
+	next []unsafe.Pointer
+}
+
+// loadNext 原子地读取 level 层的下一个节点.
+func (n *node) loadNext(level int) *node {
+	return (*node)(atomic.LoadPointer(&n.next[level]))
+}
+
+// storeNext 原子地设置 level 层的下一个节点, 使 next 对之后读取到它的读者完整可见.
+func (n *node) storeNext(level int, next *node) {
+	atomic.StorePointer(&n.next[level], unsafe.Pointer(next))
+}
+
+// newSkiplist 创建空的 skiplist, 节点按 cmp 定义的顺序排列, 新节点从 arena 中分配.
+func newSkiplist(cmp compareKeyMethod, arena *arena) *skiplist {
+	return &skiplist{
+		header: &node{next: make([]unsafe.Pointer, maxHeight)},
+		cmp:    cmp,
+		arena:  arena,
+		height: 1,
+		rnd:    rand.New(rand.NewSource(0xdeadbeef)),
+	}
 }
 
 // iterator 创建 skiplist 迭代器.
@@ -34,27 +74,42 @@ func (l *skiplist) iterator() *Iterator {
 	return it
 }
 
-// insert 将 key 插入 skiplist, key 不可重复.
-func (l *skiplist) insert(key slice.Slice) error {
-	var prevs [maxHeight]*node
+// getHeight 返回当前的最大高度.
+func (l *skiplist) getHeight() int {
+	return int(atomic.LoadInt32(&l.height))
+}
 
-	cur := l.header
-	for level := maxHeight - 1; level >= 0; level-- {
-		for cur.next[level] != nil && l.cmp(cur.next[level].key, key) != slice.CMPLarger {
-			cur = cur.next[level]
-		}
-		prevs[level] = cur
+// randomHeight 返回新节点的高度, 高度为 h 的概率为 (1/branching)^(h-1) * (1 - 1/branching).
+func (l *skiplist) randomHeight() int {
+	height := 1
+	for height < maxHeight && l.rnd.Intn(branching) == 0 {
+		height++
 	}
 
-	if l.cmp(prevs[0].key, key) == slice.CMPSame {
+	return height
+}
+
+// insert 将 key 插入 skiplist, key 不可重复. 同一时刻只能有一个写者.
+func (l *skiplist) insert(key slice.Slice) error {
+	var prevs [maxHeight]*node
+	next := l.findGreaterOrEqual(key, &prevs)
+	if next != nil && l.cmp(next.key, key) == slice.CMPSame {
 		return errors.New("equal key")
 	}
 
-	insertedNode := &node{key: key}
+	height := l.randomHeight()
+	if curHeight := l.getHeight(); height > curHeight {
+		for level := curHeight; level < height; level++ {
+			prevs[level] = l.header
+		}
+		atomic.StoreInt32(&l.height, int32(height))
+	}
 
-	for level := 0; level <= (rand.Int() % maxHeight); level++ {
-		insertedNode.next[level] = prevs[level].next[level]
-		prevs[level].next[level] = insertedNode
+	insertedNode := l.arena.newNode(key, height)
+	for level := 0; level < height; level++ {
+		// 先设置新节点的 next, 再将其链接到 prev 之后, 读者看到新节点时其 next 已经有效.
+		insertedNode.storeNext(level, prevs[level].loadNext(level))
+		prevs[level].storeNext(level, insertedNode)
 	}
 
 	return nil
@@ -67,12 +122,30 @@ func (l *skiplist) contains(key slice.Slice) bool {
 	return res != nil && l.cmp(res.key, key) == slice.CMPSame
 }
 
-// seekLessThanRule 获取小于 target 的第一个节点.
+// findGreaterOrEqual 获取大于等于 target 的第一个节点, 不存在时返回 nil.
+// prevs 不为 nil 时, 记录每一层中小于 target 的最后一个节点.
+func (l *skiplist) findGreaterOrEqual(target slice.Slice, prevs *[maxHeight]*node) *node {
+	cur := l.header
+	for level := l.getHeight() - 1; ; level-- {
+		next := cur.loadNext(level)
+		for next != nil && l.cmp(next.key, target) == slice.CMPSmaller {
+			cur, next = next, next.loadNext(level)
+		}
+		if prevs != nil {
+			prevs[level] = cur
+		}
+		if level == 0 {
+			return next
+		}
+	}
+}
+
+// seekLessThanRule 获取小于 target 的最后一个节点, 不存在时返回 header.
 func (l *skiplist) seekLessThan(target slice.Slice) *node {
 	cur := l.header
-	for level := maxHeight - 1; level >= 0; level-- {
-		for cur.next[level] != nil && l.cmp(cur.next[level].key, target) == slice.CMPSmaller {
-			cur = cur.next[level]
+	for level := l.getHeight() - 1; level >= 0; level-- {
+		for next := cur.loadNext(level); next != nil && l.cmp(next.key, target) == slice.CMPSmaller; next = cur.loadNext(level) {
+			cur = next
 		}
 	}
 
@@ -81,15 +154,15 @@ func (l *skiplist) seekLessThan(target slice.Slice) *node {
 
 // seekGreaterOrEqualRule 获取大于等于 target 的第一个节点.
 func (l *skiplist) seekGreaterOrEqual(target slice.Slice) *node {
-	return l.seekLessThan(target).next[0]
+	return l.findGreaterOrEqual(target, nil)
 }
 
 // seekLastRule 获取 skiplist 尾部的节点.
 func (l *skiplist) seekLast() *node {
 	cur := l.header
-	for level := maxHeight - 1; level >= 0; level-- {
-		for cur.next[level] != nil {
-			cur = cur.next[level]
+	for level := l.getHeight() - 1; level >= 0; level-- {
+		for next := cur.loadNext(level); next != nil; next = cur.loadNext(level) {
+			cur = next
 		}
 	}
 
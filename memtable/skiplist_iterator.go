@@ -35,7 +35,7 @@ func (it *Iterator) Next() {
 		return
 	}
 
-	it.node = it.node.next[0]
+	it.node = it.node.loadNext(0)
 }
 
 // Prev 访问上一个节点.
@@ -57,7 +57,7 @@ func (it *Iterator) Seek(target slice.Slice) {
 
 // SeekToFirst 访问 skiplist 第一个节点.
 func (it *Iterator) SeekToFirst() {
-	it.node = it.list.header.next[0]
+	it.node = it.list.header.loadNext(0)
 }
 
 // SeekToLast 访问 skiplist 最后一个节点.
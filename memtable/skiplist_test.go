@@ -2,6 +2,10 @@
 package memtable
 
 import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/goleveldb/goleveldb/slice"
@@ -17,12 +21,7 @@ func compareString(a, b slice.Slice) int {
 }
 
 func newTestSkipList() *skiplist {
-	return &skiplist{
-		header: &node{
-			next: [maxHeight]*node{},
-		},
-		cmp: compareString,
-	}
+	return newSkiplist(compareString, &arena{})
 }
 
 // skiplistOpreation 记录操作方法及其内容.
@@ -84,3 +83,79 @@ func generateTestPointFunc(operations []*skiplistOpreation) func(t *testing.T) {
 		}
 	}
 }
+
+func TestMemtable_skiplist_RandomHeight(t *testing.T) {
+	list := newTestSkipList()
+	const total = 100000
+	var counts [maxHeight + 1]int
+	for i := 0; i < total; i++ {
+		height := list.randomHeight()
+		if height < 1 || height > maxHeight {
+			t.Fatalf("randomHeight() = %d, want in [1, %d]", height, maxHeight)
+		}
+		counts[height]++
+	}
+
+	// 每增加一层, 节点数约为上一层的 1/branching.
+	for height := 1; height <= 3; height++ {
+		want := float64(total) * (1 - 1.0/branching)
+		for i := 1; i < height; i++ {
+			want /= branching
+		}
+		if got := float64(counts[height]); got < want*0.9 || got > want*1.1 {
+			t.Errorf("nodes of height %d = %v, want about %v", height, got, want)
+		}
+	}
+}
+
+func TestMemtable_skiplist_ConcurrentReadWrite(t *testing.T) {
+	const numKeys = 5000
+	keys := rand.New(rand.NewSource(1)).Perm(numKeys)
+	testKey := func(i int) slice.Slice { return slice.Slice(fmt.Sprintf("key_%05d", i)) }
+
+	list := newTestSkipList()
+	// inserted 为已插入 skiplist 的 key 数, 读者可以确定 keys[:inserted] 均可见.
+	var inserted int64
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(r)))
+			for atomic.LoadInt64(&inserted) < numKeys {
+				n := atomic.LoadInt64(&inserted)
+				if n > 0 {
+					if key := testKey(keys[rnd.Int63n(n)]); !list.contains(key) {
+						t.Errorf("inserted key %s is not visible", key)
+						return
+					}
+				}
+
+				// 遍历的结果有序, 且至少包含开始遍历前插入的 key.
+				var last slice.Slice
+				count := int64(0)
+				for it := list.iterator(); it.Valid(); it.Next() {
+					key, _ := it.Key()
+					if last != nil && key.Compare(last) != slice.CMPLarger {
+						t.Errorf("keys out of order: %s after %s", key, last)
+						return
+					}
+					last = key
+					count++
+				}
+				if count < n {
+					t.Errorf("iterated %d keys, want >= %d", count, n)
+					return
+				}
+			}
+		}(r)
+	}
+
+	for _, i := range keys {
+		if err := list.insert(testKey(i)); err != nil {
+			t.Fatal(err)
+		}
+		atomic.AddInt64(&inserted, 1)
+	}
+	wg.Wait()
+}
@@ -10,8 +10,8 @@ const (
 // Slice 是基于[]byte的切片类型，实现了其比较操作.
 type Slice []byte
 
-// Compare 比较两个切片，返回两者比较结果.
-// TODO user defined comparator
+// Compare 按字节序比较两个切片，返回两者比较结果.
+// 自定义排序规则见 comparator.Comparator.
 func (s Slice) Compare(b Slice) int {
 	if string(s) > string(b) {
 		return CMPLarger
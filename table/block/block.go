@@ -10,13 +10,17 @@ type Block struct {
 	Content        slice.Slice // all data in the block
 	NumRestarts    uint32
 	RestartsOffset uint32
+	order          binary.ByteOrder // byte order of the restart array
 }
 
-func New(content slice.Slice) *Block {
-	numRestarts := binary.BigEndian.Uint32(content[len(content)-4:])
+// New: parse a block built in the given format
+func New(content slice.Slice, format Format) *Block {
+	order := format.ByteOrder()
+	numRestarts := order.Uint32(content[len(content)-4:])
 	return &Block{
 		Content:        content,
 		NumRestarts:    numRestarts,
 		RestartsOffset: uint32(len(content)) - 4 - 4*numRestarts,
+		order:          order,
 	}
 }
@@ -0,0 +1,49 @@
+package block
+
+import (
+	"encoding/binary"
+
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+// Format: the encoding of restart arrays and block handles
+type Format byte
+
+const (
+	// FormatGoLevelDB: big-endian restart arrays and fixed 16-byte big-endian handles
+	FormatGoLevelDB Format = iota
+	// FormatLevelDB: little-endian restart arrays and varint handles, byte-compatible with C++ LevelDB
+	FormatLevelDB
+)
+
+// ByteOrder: the byte order of fixed-length integers in the format, such as restart arrays
+func (f Format) ByteOrder() binary.ByteOrder {
+	if f == FormatLevelDB {
+		return binary.LittleEndian
+	}
+
+	return binary.BigEndian
+}
+
+// EncodeHandle: serialize handle in the format
+func (f Format) EncodeHandle(handle *Handle) slice.Slice {
+	if f == FormatLevelDB {
+		return handle.ToVarintSlice()
+	}
+
+	return handle.ToSlice()
+}
+
+// DecodeHandle: parse a handle serialized by EncodeHandle from the beginning of bytes,
+// returns the handle and the number of bytes it takes
+func (f Format) DecodeHandle(bytes []byte) (*Handle, int, error) {
+	if f == FormatLevelDB {
+		return NewVarintHandle(bytes)
+	}
+
+	if len(bytes) < HandleLength {
+		return nil, 0, ErrCorruptedHandle
+	}
+
+	return NewHandle(bytes), HandleLength, nil
+}
@@ -2,6 +2,7 @@ package block
 
 import (
 	"encoding/binary"
+	"errors"
 
 	"github.com/goleveldb/goleveldb/slice"
 )
@@ -17,6 +18,8 @@ const (
 	MaxBlockHandleLength = 20    // 序列化blockHandle所需要的最大空间 = 20B
 )
 
+var ErrCorruptedHandle = errors.New("corrupted block handle")
+
 func NewHandle(bytes []byte) *Handle {
 	handle := Handle{}
 	handle.Offset = binary.BigEndian.Uint64(bytes)
@@ -33,3 +36,27 @@ func (b *Handle) ToSlice() slice.Slice {
 
 	return handle
 }
+
+// ToVarintSlice: serialize the handle as two varints, the LevelDB block handle format
+func (b *Handle) ToVarintSlice() slice.Slice {
+	handle := make([]byte, MaxBlockHandleLength)
+	n := binary.PutUvarint(handle, b.Offset)
+	n += binary.PutUvarint(handle[n:], b.Size)
+
+	return handle[:n]
+}
+
+// NewVarintHandle: parse a handle serialized by ToVarintSlice from the beginning of bytes,
+// returns the handle and the number of bytes it takes
+func NewVarintHandle(bytes []byte) (*Handle, int, error) {
+	offset, n := binary.Uvarint(bytes)
+	if n <= 0 {
+		return nil, 0, ErrCorruptedHandle
+	}
+	size, m := binary.Uvarint(bytes[n:])
+	if m <= 0 {
+		return nil, 0, ErrCorruptedHandle
+	}
+
+	return &Handle{Offset: offset, Size: size}, n + m, nil
+}
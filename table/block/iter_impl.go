@@ -4,13 +4,16 @@ import (
 	"encoding/binary"
 
 	"github.com/goleveldb/goleveldb/common"
+	"github.com/goleveldb/goleveldb/comparator"
 	"github.com/goleveldb/goleveldb/slice"
 )
 
 type blockIteratorImpl struct {
+	cmp            comparator.Comparator
 	content        []byte
 	numRestarts    uint32
 	restartsOffset uint32
+	order          binary.ByteOrder
 
 	current        uint32
 	currentRestart uint32
@@ -21,11 +24,14 @@ type blockIteratorImpl struct {
 
 var _ common.Iterator = (*blockIteratorImpl)(nil)
 
-func NewIter(blk *Block) common.Iterator {
+// NewIter: create an iterator over blk, whose keys are ordered by cmp
+func NewIter(blk *Block, cmp comparator.Comparator) common.Iterator {
 	return &blockIteratorImpl{
+		cmp:            cmp,
 		content:        blk.Content,
 		numRestarts:    blk.NumRestarts,
 		restartsOffset: blk.RestartsOffset,
+		order:          blk.order,
 	}
 }
 
@@ -114,7 +120,7 @@ func (i *blockIteratorImpl) fail() {
 }
 
 func (i *blockIteratorImpl) getRestartOffset(restartIndex uint32) uint32 {
-	return binary.BigEndian.Uint32(i.content[i.restartsOffset+4*restartIndex:])
+	return i.order.Uint32(i.content[i.restartsOffset+4*restartIndex:])
 }
 
 func (i *blockIteratorImpl) Next() {
@@ -138,7 +144,7 @@ func (i *blockIteratorImpl) Find(key slice.Slice) {
 		midOffset := i.getRestartOffset(mid)
 		_, _, _, midK, _ := parseEntry(i.content[midOffset:])
 
-		if midK.Compare(key) > 0 {
+		if i.cmp.Compare(midK, key) > 0 {
 			right = mid - 1
 		} else {
 			left = mid
@@ -151,7 +157,20 @@ func (i *blockIteratorImpl) Find(key slice.Slice) {
 	// for data block kv, k.CompareTo(key) == 0 satisfies our needs
 	// to summarize the false condition is k.CompareTo(key) >= 0
 	i.gotoRestart(left)
-	for i.parseCurrent() && i.Key().Compare(key) < 0 {
+	for i.parseCurrent() && i.cmp.Compare(i.Key(), key) < 0 {
+		i.gotoNext()
+	}
+}
+
+func (i *blockIteratorImpl) SeekToFirst() {
+	i.gotoRestart(0)
+	i.parseCurrent()
+}
+
+func (i *blockIteratorImpl) SeekToLast() {
+	i.gotoRestart(i.numRestarts - 1)
+	// linear search from the last restart point
+	for i.parseCurrent() && i.current+i.entryLen < i.restartsOffset {
 		i.gotoNext()
 	}
 }
@@ -163,3 +182,9 @@ func (i *blockIteratorImpl) Key() slice.Slice {
 func (i *blockIteratorImpl) Value() slice.Slice {
 	return i.value
 }
+
+func (i *blockIteratorImpl) Err() error {
+	return nil
+}
+
+func (i *blockIteratorImpl) Release() {}
@@ -2,9 +2,11 @@ package block
 
 import (
 	"fmt"
-	"github.com/goleveldb/goleveldb/common"
 	"testing"
 
+	"github.com/goleveldb/goleveldb/common"
+	"github.com/goleveldb/goleveldb/comparator"
+	"github.com/goleveldb/goleveldb/config"
 	"github.com/goleveldb/goleveldb/slice"
 )
 
@@ -37,7 +39,7 @@ func Test_Iter(t *testing.T) {
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
-			blockWriter := NewWriter()
+			blockWriter := NewWriter(comparator.Bytewise, FormatGoLevelDB, config.BLOCK_RESTART_INTERVAL)
 			sortEntries(testCase.writeEntries)
 			for _, entry := range testCase.writeEntries {
 				if err := blockWriter.AddEntry(entry.key, entry.value); err != nil {
@@ -45,7 +47,7 @@ func Test_Iter(t *testing.T) {
 				}
 			}
 
-			iter := NewIter(New(blockWriter.Finish()))
+			iter := NewIter(New(blockWriter.Finish(), FormatGoLevelDB), comparator.Bytewise)
 			for i, length := 0, len(testCase.writeEntries); i < length; i++ {
 				doTest(t, i, testCase.writeEntries, iter)
 			}
@@ -131,3 +133,28 @@ func makeEntry(key, value string) *entry {
 		value: []byte(value),
 	}
 }
+
+func Test_IterSeekToFirstAndLast(t *testing.T) {
+	entries := entriesWithFixValue("wdnmd", "wdnmd_%d", 100)
+	blockWriter := NewWriter(comparator.Bytewise, FormatGoLevelDB, config.BLOCK_RESTART_INTERVAL)
+	for _, entry := range entries {
+		assertTrue(t, blockWriter.AddEntry(entry.key, entry.value) == nil, "add entry failed")
+	}
+
+	iter := NewIter(New(blockWriter.Finish(), FormatGoLevelDB), comparator.Bytewise)
+	iter.SeekToFirst()
+	assertTrue(t, iter.Success() && iter.Key().Compare(entries[0].key) == 0, "SeekToFirst() should point to the first entry")
+	testNext(t, iter, entries, 0)
+
+	iter.SeekToLast()
+	assertTrue(t, iter.Success() && iter.Key().Compare(entries[len(entries)-1].key) == 0,
+		"SeekToLast() should point to the last entry")
+	testPrev(t, iter, entries, len(entries)-1)
+
+	emptyBlock := NewWriter(comparator.Bytewise, FormatGoLevelDB, config.BLOCK_RESTART_INTERVAL).Finish()
+	emptyIter := NewIter(New(emptyBlock, FormatGoLevelDB), comparator.Bytewise)
+	emptyIter.SeekToFirst()
+	assertFalse(t, emptyIter.Success(), "SeekToFirst() on empty block should fail")
+	emptyIter.SeekToLast()
+	assertFalse(t, emptyIter.Success(), "SeekToLast() on empty block should fail")
+}
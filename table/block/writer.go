@@ -4,7 +4,7 @@ import (
 	"encoding/binary"
 	"errors"
 
-	"github.com/goleveldb/goleveldb/config"
+	"github.com/goleveldb/goleveldb/comparator"
 	"github.com/goleveldb/goleveldb/slice"
 )
 
@@ -13,39 +13,54 @@ type Writer interface {
 	Finish() slice.Slice
 	Reset()
 	Size() int
+	Empty() bool
 }
 
 type writerImpl struct {
-	content       []byte      // data in current block waiting to be finished
-	restartPoints []uint32    // stores all indexes where lastInsertKey is recalculated
-	lastInsertKey slice.Slice // used for prefix compression
-	counter       uint32      // used for prefix compression, see config.BLOCK_RESTART_INTERVAL
-	isFinished    bool
+	content         []byte      // data in current block waiting to be finished
+	restartPoints   []uint32    // stores all indexes where lastInsertKey is recalculated
+	lastInsertKey   slice.Slice // used for prefix compression
+	counter         uint32      // used for prefix compression, reset every restartInterval keys
+	restartInterval uint32
+	isFinished      bool
+	cmp             comparator.Comparator // keys must be added in increasing order of cmp
+	order           binary.ByteOrder      // byte order of the restart array
 }
 
 var _ Writer = (*writerImpl)(nil)
 
-// NewWriter: create a concrete instance of Writer interface
-func NewWriter() Writer {
+// NewWriter: create a concrete instance of Writer interface, keys are ordered by cmp
+// and the block is built in the given format, a restart point is added every restartInterval keys
+// (see config.BLOCK_RESTART_INTERVAL and config.INDEX_RESTART_INTERVAL)
+func NewWriter(cmp comparator.Comparator, format Format, restartInterval int) Writer {
 	restartPoints := []uint32{0}
 	return &writerImpl{
-		restartPoints: restartPoints,
+		restartPoints:   restartPoints,
+		restartInterval: uint32(restartInterval),
+		cmp:             cmp,
+		order:           format.ByteOrder(),
 	}
 }
 
-var ErrBlockFinished = errors.New("unable to perform actions on finished block")
+var (
+	ErrBlockFinished = errors.New("unable to perform actions on finished block")
+	ErrKeyOrder      = errors.New("keys must be added in increasing order")
+)
 
 // AddEntry: append a new entry to the pending data block in BlockWriter
 func (b *writerImpl) AddEntry(key, value slice.Slice) error {
 	if b.isFinished {
 		return ErrBlockFinished
 	}
+	if !b.Empty() && b.cmp.Compare(key, b.lastInsertKey) <= 0 {
+		return ErrKeyOrder
+	}
 	// get the prefix length of current key and last insert key
 	share := 0
-	if b.counter == config.BLOCK_RESTART_INTERVAL {
+	if b.counter == b.restartInterval {
 		b.counter = 0
 		b.restartPoints = append(b.restartPoints, uint32(len(b.content)))
-		b.lastInsertKey = nil
+		b.lastInsertKey = b.lastInsertKey[:0]
 	} else {
 		minKeyLen := minInt(len(key), len(b.lastInsertKey))
 		for share < minKeyLen {
@@ -74,7 +89,7 @@ func (b *writerImpl) AddEntry(key, value slice.Slice) error {
 	newPos += copy(newContent[newPos:], key[share:])
 	copy(newContent[newPos:], value)
 
-	b.lastInsertKey = key
+	b.lastInsertKey = append(b.lastInsertKey[:0], key...)
 	b.content = newContent
 	b.counter++
 
@@ -89,10 +104,10 @@ func (b *writerImpl) Finish() slice.Slice {
 	newPos += copy(newContent, b.content)
 
 	for _, restartPoint := range b.restartPoints {
-		binary.BigEndian.PutUint32(newContent[newPos:], restartPoint)
+		b.order.PutUint32(newContent[newPos:], restartPoint)
 		newPos += 4
 	}
-	binary.BigEndian.PutUint32(newContent[newPos:], uint32(len(b.restartPoints)))
+	b.order.PutUint32(newContent[newPos:], uint32(len(b.restartPoints)))
 
 	return slice.Slice(newContent)
 }
@@ -102,7 +117,7 @@ func (b *writerImpl) Reset() {
 	b.isFinished = false
 	b.content = nil
 	b.counter = 0
-	b.lastInsertKey = nil
+	b.lastInsertKey = b.lastInsertKey[:0]
 	b.restartPoints = []uint32{0}
 }
 
@@ -111,6 +126,11 @@ func (b *writerImpl) Size() int {
 	return len(b.content) + len(b.restartPoints)*4 + 4
 }
 
+// Empty: return true if no entry has been added since the last Reset()
+func (b *writerImpl) Empty() bool {
+	return len(b.content) == 0
+}
+
 func varintLen(a int) int {
 	if a == 0 {
 		return 1
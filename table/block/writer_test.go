@@ -0,0 +1,105 @@
+package block
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/goleveldb/goleveldb/comparator"
+	"github.com/goleveldb/goleveldb/config"
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+func Test_Writer_KeyOrder(t *testing.T) {
+	blockWriter := NewWriter(comparator.Bytewise, FormatGoLevelDB, config.BLOCK_RESTART_INTERVAL)
+	assertTrue(t, blockWriter.Empty(), "new block should be empty")
+	assertTrue(t, blockWriter.AddEntry(slice.Slice("b"), slice.Slice("1")) == nil, "add b failed")
+	assertFalse(t, blockWriter.Empty(), "block should not be empty after AddEntry")
+
+	err := blockWriter.AddEntry(slice.Slice("a"), slice.Slice("2"))
+	assertTrue(t, errors.Is(err, ErrKeyOrder), "adding a smaller key should fail")
+	err = blockWriter.AddEntry(slice.Slice("b"), slice.Slice("2"))
+	assertTrue(t, errors.Is(err, ErrKeyOrder), "adding a duplicated key should fail")
+
+	blockWriter.Reset()
+	assertTrue(t, blockWriter.Empty(), "block should be empty after Reset")
+}
+
+func Test_IterWithReverseComparator(t *testing.T) {
+	cmp := comparator.Reverse(comparator.Bytewise)
+	entries := entriesWithFixValue("wdnmd", "wdnmd_%d", 100)
+	blockWriter := NewWriter(cmp, FormatGoLevelDB, config.BLOCK_RESTART_INTERVAL)
+	for i := len(entries) - 1; i >= 0; i-- {
+		assertTrue(t, blockWriter.AddEntry(entries[i].key, entries[i].value) == nil, "add entry failed")
+	}
+
+	iter := NewIter(New(blockWriter.Finish(), FormatGoLevelDB), cmp)
+	for _, entry := range entries {
+		iter.Find(entry.key)
+		assertTrue(t, iter.Success(), "key not found: "+string(entry.key))
+		assertTrue(t, iter.Key().Compare(entry.key) == 0, "found key not equal: "+string(iter.Key()))
+	}
+}
+
+func Test_Writer_LevelDBFormat(t *testing.T) {
+	entries := entriesWithFixValue("wdnmd", "wdnmd_%d", 100)
+	blockWriter := NewWriter(comparator.Bytewise, FormatLevelDB, config.BLOCK_RESTART_INTERVAL)
+	for _, entry := range entries {
+		assertTrue(t, blockWriter.AddEntry(entry.key, entry.value) == nil, "add entry failed")
+	}
+
+	// restart points and their number are stored in little endian
+	content := blockWriter.Finish()
+	numRestarts := binary.LittleEndian.Uint32(content[len(content)-4:])
+	assertTrue(t, numRestarts == 7, fmt.Sprintf("want 7 restart points, got %d", numRestarts))
+	assertTrue(t, binary.LittleEndian.Uint32(content[len(content)-8:]) < uint32(len(content)), "bad restart point")
+
+	iter := NewIter(New(content, FormatLevelDB), comparator.Bytewise)
+	for _, entry := range entries {
+		iter.Find(entry.key)
+		assertTrue(t, iter.Success(), "key not found: "+string(entry.key))
+		assertTrue(t, iter.Value().Compare(entry.value) == 0, "found value not equal: "+string(iter.Value()))
+	}
+}
+
+func Test_Writer_RestartInterval(t *testing.T) {
+	entries := entriesWithFixValue("wdnmd", "wdnmd_%d", 100)
+	blockWriter := NewWriter(comparator.Bytewise, FormatGoLevelDB, config.INDEX_RESTART_INTERVAL)
+	for _, entry := range entries {
+		assertTrue(t, blockWriter.AddEntry(entry.key, entry.value) == nil, "add entry failed")
+	}
+
+	// every key is a restart point, no prefix is shared
+	content := blockWriter.Finish()
+	numRestarts := binary.BigEndian.Uint32(content[len(content)-4:])
+	assertTrue(t, numRestarts == 100, fmt.Sprintf("want 100 restart points, got %d", numRestarts))
+
+	iter := NewIter(New(content, FormatGoLevelDB), comparator.Bytewise)
+	for _, entry := range entries {
+		iter.Find(entry.key)
+		assertTrue(t, iter.Success(), "key not found: "+string(entry.key))
+		assertTrue(t, iter.Value().Compare(entry.value) == 0, "found value not equal: "+string(iter.Value()))
+	}
+}
+
+func Test_FormatHandle(t *testing.T) {
+	handles := []*Handle{{0, 0}, {127, 128}, {1 << 40, 4096}, {^uint64(0), ^uint64(0)}}
+	for _, format := range []Format{FormatGoLevelDB, FormatLevelDB} {
+		for _, handle := range handles {
+			encoded := format.EncodeHandle(handle)
+			got, n, err := format.DecodeHandle(encoded)
+			assertTrue(t, err == nil && n == len(encoded), fmt.Sprintf("decode %v: %v", encoded, err))
+			assertTrue(t, *got == *handle, fmt.Sprintf("want %v, got %v", handle, got))
+		}
+	}
+
+	// varint handles: offset 127 takes 1 byte, size 128 takes 2 bytes
+	assertTrue(t, string(FormatLevelDB.EncodeHandle(&Handle{127, 128})) == "\x7f\x80\x01", "bad varint handle")
+	assertTrue(t, len(FormatGoLevelDB.EncodeHandle(&Handle{127, 128})) == HandleLength, "bad fixed handle")
+
+	_, _, err := FormatLevelDB.DecodeHandle([]byte{0x80})
+	assertTrue(t, errors.Is(err, ErrCorruptedHandle), "truncated handle should be corrupted")
+	_, _, err = FormatGoLevelDB.DecodeHandle(make([]byte, HandleLength-1))
+	assertTrue(t, errors.Is(err, ErrCorruptedHandle), "truncated handle should be corrupted")
+}
@@ -0,0 +1,144 @@
+package table
+
+import (
+	"encoding/binary"
+
+	"github.com/goleveldb/goleveldb/filter"
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+const (
+	// a new filter is generated for every 2KB (1 << filterBaseLg) of data block offset
+	filterBaseLg = 11
+	filterBase   = 1 << filterBaseLg
+
+	filterBlockKeyPrefix = "filter."
+)
+
+// filterBlockWriter builds the filter block of a table, the format is:
+//   - filter_0 ... filter_N-1 : Slice
+//   - offset of filter_i : uint32 (little endian) * N
+//   - offset of the offset array : uint32 (little endian)
+//   - filterBaseLg : uint8
+//
+// filter_i summarizes the keys of all data blocks whose offset is in [i*filterBase, (i+1)*filterBase)
+type filterBlockWriter struct {
+	policy        filter.FilterPolicy
+	keys          []byte // flattened contents of the keys added since the last generated filter
+	start         []int  // start index of each key in keys
+	result        []byte // filters generated so far
+	filterOffsets []uint32
+}
+
+func newFilterBlockWriter(policy filter.FilterPolicy) *filterBlockWriter {
+	return &filterBlockWriter{policy: policy}
+}
+
+// startBlock: called before adding keys of the data block starting at blockOffset
+func (w *filterBlockWriter) startBlock(blockOffset uint64) {
+	filterIndex := int(blockOffset / filterBase)
+	for filterIndex > len(w.filterOffsets) {
+		w.generateFilter()
+	}
+}
+
+// addKey: the key is copied, the caller may reuse its memory afterwards
+func (w *filterBlockWriter) addKey(key slice.Slice) {
+	w.start = append(w.start, len(w.keys))
+	w.keys = append(w.keys, key...)
+}
+
+// finish: return the content of the filter block
+func (w *filterBlockWriter) finish() slice.Slice {
+	if len(w.start) > 0 {
+		w.generateFilter()
+	}
+
+	arrayOffset := uint32(len(w.result))
+	for _, offset := range w.filterOffsets {
+		w.result = appendUint32(w.result, offset)
+	}
+	w.result = appendUint32(w.result, arrayOffset)
+
+	return append(w.result, filterBaseLg)
+}
+
+func (w *filterBlockWriter) generateFilter() {
+	w.filterOffsets = append(w.filterOffsets, uint32(len(w.result)))
+	// an empty filter for offsets without any data block
+	if len(w.start) == 0 {
+		return
+	}
+
+	keys := make([]slice.Slice, len(w.start))
+	for i, start := range w.start {
+		limit := len(w.keys)
+		if i+1 < len(w.start) {
+			limit = w.start[i+1]
+		}
+		keys[i] = slice.Slice(w.keys[start:limit])
+	}
+	w.result = append(w.result, w.policy.CreateFilter(keys)...)
+	w.keys = w.keys[:0]
+	w.start = w.start[:0]
+}
+
+func appendUint32(dst []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+
+	return append(dst, buf[:]...)
+}
+
+// filterBlockReader answers whether a key may be in a data block using the filter block
+type filterBlockReader struct {
+	policy      filter.FilterPolicy
+	content     slice.Slice
+	arrayOffset uint32 // offset of the filter offset array
+	num         uint32 // number of filters
+	baseLg      byte
+}
+
+// newFilterBlockReader: returns nil if content is not a valid filter block
+func newFilterBlockReader(policy filter.FilterPolicy, content slice.Slice) *filterBlockReader {
+	n := uint32(len(content))
+	// at least 4 bytes for the array offset and 1 byte for baseLg
+	if n < 5 {
+		return nil
+	}
+
+	arrayOffset := binary.LittleEndian.Uint32(content[n-5:])
+	if arrayOffset > n-5 {
+		return nil
+	}
+
+	return &filterBlockReader{
+		policy:      policy,
+		content:     content,
+		arrayOffset: arrayOffset,
+		num:         (n - 5 - arrayOffset) / 4,
+		baseLg:      content[n-1],
+	}
+}
+
+// keyMayMatch: return false if key is definitely not in the data block starting at blockOffset
+func (r *filterBlockReader) keyMayMatch(blockOffset uint64, key slice.Slice) bool {
+	index := blockOffset >> r.baseLg
+	if index >= uint64(r.num) {
+		// errors are treated as potential matches
+		return true
+	}
+
+	pos := r.arrayOffset + uint32(index)*4
+	start := binary.LittleEndian.Uint32(r.content[pos:])
+	limit := binary.LittleEndian.Uint32(r.content[pos+4:])
+	if start < limit && limit <= r.arrayOffset {
+		return r.policy.KeyMayMatch(key, r.content[start:limit])
+	}
+	if start == limit {
+		// empty filters do not match any key
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,64 @@
+package table
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/goleveldb/goleveldb/filter"
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+func Test_FilterBlock_Empty(t *testing.T) {
+	writer := newFilterBlockWriter(filter.NewBloomFilterPolicy(10))
+	content := writer.finish()
+	assertTrue(t, string(content) == "\x00\x00\x00\x00\x0b", fmt.Sprintf("unexpected empty filter block %x", content))
+
+	reader := newFilterBlockReader(filter.NewBloomFilterPolicy(10), content)
+	assertTrue(t, reader.keyMayMatch(0, slice.Slice("foo")), "out of range offset should match")
+	assertTrue(t, reader.keyMayMatch(100000, slice.Slice("foo")), "out of range offset should match")
+}
+
+func Test_FilterBlock_MultiBlocks(t *testing.T) {
+	policy := filter.NewBloomFilterPolicy(10)
+	writer := newFilterBlockWriter(policy)
+
+	// first filter
+	writer.startBlock(0)
+	writer.addKey(slice.Slice("foo"))
+	writer.startBlock(2000)
+	writer.addKey(slice.Slice("bar"))
+
+	// second filter
+	writer.startBlock(3100)
+	writer.addKey(slice.Slice("box"))
+
+	// third filter is empty
+
+	// last filter
+	writer.startBlock(9000)
+	writer.addKey(slice.Slice("box"))
+	writer.addKey(slice.Slice("hello"))
+
+	reader := newFilterBlockReader(policy, writer.finish())
+	tests := []struct {
+		offset uint64
+		key    string
+		want   bool
+	}{
+		{0, "foo", true}, {2000, "bar", true}, {0, "box", false}, {0, "hello", false},
+		{3100, "box", true}, {3100, "foo", false}, {3100, "bar", false}, {3100, "hello", false},
+		{4100, "foo", false}, {4100, "bar", false}, {4100, "box", false}, {4100, "hello", false},
+		{9000, "box", true}, {9000, "hello", true}, {9000, "foo", false}, {9000, "bar", false},
+	}
+	for _, tt := range tests {
+		got := reader.keyMayMatch(tt.offset, slice.Slice(tt.key))
+		assertTrue(t, got == tt.want, fmt.Sprintf("keyMayMatch(%d, %s) = %v, want %v", tt.offset, tt.key, got, tt.want))
+	}
+}
+
+func Test_FilterBlock_Invalid(t *testing.T) {
+	policy := filter.NewBloomFilterPolicy(10)
+	assertTrue(t, newFilterBlockReader(policy, slice.Slice{1, 2, 3}) == nil, "too short filter block should be rejected")
+	assertTrue(t, newFilterBlockReader(policy, slice.Slice{0xff, 0, 0, 0, 11}) == nil,
+		"filter block with invalid array offset should be rejected")
+}
@@ -8,7 +8,14 @@ import (
 	"github.com/goleveldb/goleveldb/table/block"
 )
 
+// footer: the fixed-length tail of a table, laid out as
+//   - FormatGoLevelDB: index handle | metaindex handle | padding | magic (big endian)
+//   - FormatLevelDB: metaindex handle | index handle | padding | magic (little endian),
+//     handles are varints and padded to 2*block.MaxBlockHandleLength bytes
+//
+// the byte order of the magic number tells the format of the table
 type footer struct {
+	format          Format
 	indexHandle     *block.Handle
 	metaIndexHandle *block.Handle
 }
@@ -25,24 +32,45 @@ func newFooter(bytes []byte) (*footer, error) {
 	if len(bytes) != footerLength {
 		return nil, errInvalidSSTable
 	}
-	if tableMagicNumber != binary.BigEndian.Uint64(bytes[2*block.MaxBlockHandleLength:]) {
-		return nil, errInvalidSSTable
-	}
 
 	res := footer{}
-	res.indexHandle = block.NewHandle(bytes)
-	res.metaIndexHandle = block.NewHandle(bytes[block.HandleLength:])
+	magic := bytes[2*block.MaxBlockHandleLength:]
+	switch tableMagicNumber {
+	case binary.BigEndian.Uint64(magic):
+		res.format = FormatGoLevelDB
+		res.indexHandle = block.NewHandle(bytes)
+		res.metaIndexHandle = block.NewHandle(bytes[block.HandleLength:])
+	case binary.LittleEndian.Uint64(magic):
+		res.format = FormatLevelDB
+		metaIndexHandle, n, err := block.NewVarintHandle(bytes)
+		if err != nil {
+			return nil, errInvalidSSTable
+		}
+		indexHandle, _, err := block.NewVarintHandle(bytes[n:])
+		if err != nil {
+			return nil, errInvalidSSTable
+		}
+		res.indexHandle, res.metaIndexHandle = indexHandle, metaIndexHandle
+	default:
+		return nil, errInvalidSSTable
+	}
 
 	return &res, nil
 }
 
 func (f *footer) toSlice() slice.Slice {
 	res := make([]byte, footerLength)
+	if f.format == FormatLevelDB {
+		offset := copy(res, f.metaIndexHandle.ToVarintSlice())
+		copy(res[offset:], f.indexHandle.ToVarintSlice())
+		binary.LittleEndian.PutUint64(res[2*block.MaxBlockHandleLength:], tableMagicNumber)
+
+		return slice.Slice(res)
+	}
+
 	offset := 0
 	offset += copy(res, f.indexHandle.ToSlice())
-	// TODO resolve meta index instead of adding 16
-	//offset += copy(res[offset:], f.metaIndexHandle.ToSlice())
-	offset += block.HandleLength
+	offset += copy(res[offset:], f.metaIndexHandle.ToSlice())
 	offset += footerPaddingLength
 	binary.BigEndian.PutUint64(res[offset:], tableMagicNumber)
 
@@ -0,0 +1,164 @@
+package table
+
+import (
+	"bytes"
+
+	"github.com/goleveldb/goleveldb/common"
+	"github.com/goleveldb/goleveldb/slice"
+	"github.com/goleveldb/goleveldb/table/block"
+)
+
+// tableIterator is a two-level iterator: it walks the entries of the index block,
+// and lazily opens the data block each index entry points to
+type tableIterator struct {
+	table     *Table
+	ropts     *ReadOptions
+	indexIter common.Iterator
+	dataIter  common.Iterator
+	// handle of the data block dataIter iterates over, in its serialized form
+	dataHandle slice.Slice
+	err        error
+}
+
+var _ common.Iterator = (*tableIterator)(nil)
+
+// NewIterator: create an iterator over all entries of the table, the iterator is not positioned initially,
+// ropts may be nil
+func (t *Table) NewIterator(ropts *ReadOptions) common.Iterator {
+	return &tableIterator{
+		table:     t,
+		ropts:     ropts,
+		indexIter: block.NewIter(t.IndexBlock, t.cmp),
+	}
+}
+
+func (i *tableIterator) Success() bool {
+	return i.dataIter != nil && i.dataIter.Success()
+}
+
+func (i *tableIterator) SeekToFirst() {
+	i.indexIter.SeekToFirst()
+	i.initDataBlock()
+	if i.dataIter != nil {
+		i.dataIter.SeekToFirst()
+	}
+	i.skipEmptyDataBlocksForward()
+}
+
+func (i *tableIterator) SeekToLast() {
+	i.indexIter.SeekToLast()
+	i.initDataBlock()
+	if i.dataIter != nil {
+		i.dataIter.SeekToLast()
+	}
+	i.skipEmptyDataBlocksBackward()
+}
+
+func (i *tableIterator) Find(key slice.Slice) {
+	// the index key of a data block is >= every key in that block,
+	// so the first index entry >= key points to the only block that may contain key
+	i.indexIter.Find(key)
+	i.initDataBlock()
+	if i.dataIter != nil {
+		i.dataIter.Find(key)
+	}
+	i.skipEmptyDataBlocksForward()
+}
+
+func (i *tableIterator) Next() {
+	if !i.Success() {
+		return
+	}
+
+	i.dataIter.Next()
+	i.skipEmptyDataBlocksForward()
+}
+
+func (i *tableIterator) Prev() {
+	if !i.Success() {
+		return
+	}
+
+	i.dataIter.Prev()
+	i.skipEmptyDataBlocksBackward()
+}
+
+func (i *tableIterator) Key() slice.Slice {
+	return i.dataIter.Key()
+}
+
+func (i *tableIterator) Value() slice.Slice {
+	return i.dataIter.Value()
+}
+
+func (i *tableIterator) Err() error {
+	return i.err
+}
+
+func (i *tableIterator) Release() {
+	i.indexIter.Release()
+	if i.dataIter != nil {
+		i.dataIter.Release()
+		i.dataIter = nil
+	}
+}
+
+// skipEmptyDataBlocksForward: move to the first entry of the following data blocks until a valid entry is found
+func (i *tableIterator) skipEmptyDataBlocksForward() {
+	for i.dataIter == nil || !i.dataIter.Success() {
+		if !i.indexIter.Success() {
+			i.dataIter = nil
+			return
+		}
+
+		i.indexIter.Next()
+		i.initDataBlock()
+		if i.dataIter != nil {
+			i.dataIter.SeekToFirst()
+		}
+	}
+}
+
+// skipEmptyDataBlocksBackward: move to the last entry of the preceding data blocks until a valid entry is found
+func (i *tableIterator) skipEmptyDataBlocksBackward() {
+	for i.dataIter == nil || !i.dataIter.Success() {
+		if !i.indexIter.Success() {
+			i.dataIter = nil
+			return
+		}
+
+		i.indexIter.Prev()
+		i.initDataBlock()
+		if i.dataIter != nil {
+			i.dataIter.SeekToLast()
+		}
+	}
+}
+
+// initDataBlock: open the data block the index iterator points to, the block is reused if it is already open
+func (i *tableIterator) initDataBlock() {
+	if !i.indexIter.Success() {
+		i.dataIter = nil
+		return
+	}
+
+	handle := i.indexIter.Value()
+	if i.dataIter != nil && bytes.Equal(handle, i.dataHandle) {
+		return
+	}
+
+	var dataBlock *block.Block
+	blockHandle, err := i.table.decodeHandle(handle)
+	if err == nil {
+		dataBlock, err = i.table.readDataBlock(blockHandle, i.ropts)
+	}
+	if err != nil {
+		// remember the error and skip the broken block
+		i.err = err
+		i.dataIter = nil
+		return
+	}
+
+	i.dataHandle = append(i.dataHandle[:0], handle...)
+	i.dataIter = block.NewIter(dataBlock, i.table.cmp)
+}
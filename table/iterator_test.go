@@ -0,0 +1,89 @@
+package table
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+func buildTestTable(t *testing.T, entries []*entry) *Table {
+	t.Helper()
+
+	fileReader := newStringReader()
+	tableWriter := NewWriter(newStringWriter(fileReader), nil)
+	for _, entry := range entries {
+		assertTrue(t, nil == tableWriter.Add(entry.key, entry.value), "append failed")
+	}
+	assertTrue(t, nil == tableWriter.Finish(), "finish failed")
+
+	return newTable(t, fileReader)
+}
+
+func TestTable_NewIterator(t *testing.T) {
+	// values are long enough to span many data blocks
+	entries := entriesWithFixedValue(string(make([]byte, 100)), "wdnmd_%05d", 2000)
+	iter := buildTestTable(t, entries).NewIterator(nil)
+
+	index := 0
+	for iter.SeekToFirst(); iter.Success(); iter.Next() {
+		assertTrue(t, iter.Key().Compare(entries[index].key) == 0,
+			fmt.Sprintf("forward: iter.key %s != entries[%d].key %s", iter.Key(), index, entries[index].key))
+		index++
+	}
+	assertTrue(t, index == len(entries), fmt.Sprintf("forward: got %d entries, want %d", index, len(entries)))
+
+	index = len(entries) - 1
+	for iter.SeekToLast(); iter.Success(); iter.Prev() {
+		assertTrue(t, iter.Key().Compare(entries[index].key) == 0,
+			fmt.Sprintf("backward: iter.key %s != entries[%d].key %s", iter.Key(), index, entries[index].key))
+		assertTrue(t, iter.Value().Compare(entries[index].value) == 0, "backward: value not equal")
+		index--
+	}
+	assertTrue(t, index == -1, fmt.Sprintf("backward: %d entries left", index+1))
+	assertTrue(t, iter.Err() == nil, fmt.Sprintf("unexpected error %v", iter.Err()))
+}
+
+func TestTable_IteratorFind(t *testing.T) {
+	var entries []*entry
+	for i := 0; i < 2000; i += 2 {
+		entries = append(entries, makeEntry(fmt.Sprintf("key_%05d", i), fmt.Sprintf("value_%05d", i)))
+	}
+	iter := buildTestTable(t, entries).NewIterator(nil)
+
+	for i := 0; i < 2000; i++ {
+		iter.Find(slice.Slice(fmt.Sprintf("key_%05d", i)))
+		// odd keys do not exist, Find stops at the next even key
+		want := i + i%2
+		if want >= 2000 {
+			assertFalse(t, iter.Success(), "Find() beyond the last key should fail")
+			continue
+		}
+
+		assertTrue(t, iter.Success() && string(iter.Key()) == fmt.Sprintf("key_%05d", want),
+			fmt.Sprintf("Find(key_%05d) => got %s", i, iter.Key()))
+
+		// step across block boundaries in both directions
+		if want > 0 {
+			iter.Prev()
+			assertTrue(t, iter.Success() && string(iter.Key()) == fmt.Sprintf("key_%05d", want-2),
+				fmt.Sprintf("Prev() after Find(key_%05d) => got %s", i, iter.Key()))
+			iter.Next()
+		}
+		if want < 1998 {
+			iter.Next()
+			assertTrue(t, iter.Success() && string(iter.Key()) == fmt.Sprintf("key_%05d", want+2),
+				fmt.Sprintf("Next() after Find(key_%05d) => got %s", i, iter.Key()))
+		}
+	}
+}
+
+func TestTable_IteratorEmpty(t *testing.T) {
+	iter := buildTestTable(t, nil).NewIterator(nil)
+	iter.SeekToFirst()
+	assertFalse(t, iter.Success(), "SeekToFirst() on empty table should fail")
+	iter.SeekToLast()
+	assertFalse(t, iter.Success(), "SeekToLast() on empty table should fail")
+	iter.Find(slice.Slice("foo"))
+	assertFalse(t, iter.Success(), "Find() on empty table should fail")
+}
@@ -0,0 +1,61 @@
+package table
+
+import (
+	"github.com/goleveldb/goleveldb/cache"
+	"github.com/goleveldb/goleveldb/comparator"
+	"github.com/goleveldb/goleveldb/compress"
+	"github.com/goleveldb/goleveldb/filter"
+	"github.com/goleveldb/goleveldb/table/block"
+)
+
+// Format: the on-disk format of a table
+type Format = block.Format
+
+const (
+	// FormatGoLevelDB: the default format, with fixed-length big-endian block handles and IEEE CRC32 checksums
+	FormatGoLevelDB = block.FormatGoLevelDB
+	// FormatLevelDB: the format of C++ LevelDB, with varint block handles and masked CRC32C checksums.
+	// Tables in this format can be exchanged with LevelDB, given the same entries and options they are
+	// byte-identical with LevelDB's output. They have no properties block, so the comparator is not checked on open
+	FormatLevelDB = block.FormatLevelDB
+)
+
+// Options: options used to build and read tables, a table must be read with the options it is built with
+type Options struct {
+	// Comparator defines the order of keys, comparator.Bytewise is used if nil
+	Comparator comparator.Comparator
+	// FilterPolicy is used to build and query the filter block, no filter block is built if nil
+	FilterPolicy filter.FilterPolicy
+	// Compression is the compression type of data blocks, readers detect it from the block trailer
+	Compression compress.Type
+	// Format is the format tables are written in, readers detect it from the footer
+	Format Format
+	// BlockCache caches uncompressed data blocks, charged by their sizes in bytes, blocks are not cached if nil.
+	// A cache may be shared by many tables.
+	BlockCache cache.Cache
+}
+
+// ReadOptions: options of reads from a table
+type ReadOptions struct {
+	// DontFillCache prevents data blocks read from being added to the block cache,
+	// so that big scans do not evict frequently used blocks
+	DontFillCache bool
+}
+
+// sanitize: return a copy of opts with default values filled in
+func (o *Options) sanitize() *Options {
+	res := Options{}
+	if o != nil {
+		res = *o
+	}
+
+	if res.Comparator == nil {
+		res.Comparator = comparator.Bytewise
+	}
+
+	return &res
+}
+
+func (o *ReadOptions) fillCache() bool {
+	return o == nil || !o.DontFillCache
+}
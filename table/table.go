@@ -4,8 +4,9 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"hash/crc32"
 
+	"github.com/goleveldb/goleveldb/comparator"
+	"github.com/goleveldb/goleveldb/compress"
 	"github.com/goleveldb/goleveldb/file"
 	"github.com/goleveldb/goleveldb/slice"
 	"github.com/goleveldb/goleveldb/table/block"
@@ -14,14 +15,23 @@ import (
 type Table struct {
 	IndexBlock *block.Block
 	File       file.RandomReader
+	opts       *Options
+	format     Format // detected from the footer, opts.Format is ignored by readers
+	cmp        comparator.Comparator
+	filter     *filterBlockReader // nil if the table has no filter block for opts.FilterPolicy
+	cacheID    uint64             // prefix of the keys of this table in opts.BlockCache
 }
 
 var (
-	ErrCrcValidation = errors.New("read block failed for crc32 is not consistent")
-	ErrNoSuchKey     = errors.New("no such key")
+	ErrCrcValidation      = errors.New("read block failed for crc32 is not consistent")
+	ErrNoSuchKey          = errors.New("no such key")
+	ErrComparatorMismatch = errors.New("table is built with a different comparator")
 )
 
-func New(file file.RandomReader, size int) (*Table, error) {
+// New: open a table of the given size, opts must match the options the table is built with
+func New(file file.RandomReader, size int, opts *Options) (*Table, error) {
+	opts = opts.sanitize()
+
 	// decode footer information
 	footerBytes, err := file.Read(uint64(size-footerLength), footerLength)
 	if err != nil {
@@ -33,55 +43,207 @@ func New(file file.RandomReader, size int) (*Table, error) {
 		return nil, err
 	}
 
-	indexBlockSlice, err := readBlock(footer.indexHandle, file)
+	indexBlockSlice, err := readBlock(footer.indexHandle, file, footer.format)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Table{
-		IndexBlock: block.New(indexBlockSlice),
+	t := &Table{
+		IndexBlock: block.New(indexBlockSlice, footer.format),
 		File:       file,
-	}, nil
+		opts:       opts,
+		format:     footer.format,
+		cmp:        opts.Comparator,
+	}
+	if opts.BlockCache != nil {
+		t.cacheID = opts.BlockCache.NewID()
+	}
+
+	if err := t.readMeta(footer.metaIndexHandle); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// readMeta: check the comparator recorded in the properties block and load the filter block
+func (t *Table) readMeta(metaIndexHandle *block.Handle) error {
+	if metaIndexHandle.Size == 0 {
+		return nil
+	}
+
+	metaIndexSlice, err := readBlock(metaIndexHandle, t.File, t.format)
+	if err != nil {
+		return err
+	}
+	metaIndex := block.New(metaIndexSlice, t.format)
+
+	properties, err := t.readMetaBlock(metaIndex, propertiesBlockKey)
+	if err != nil {
+		return err
+	}
+	if err := t.checkComparator(properties); err != nil {
+		return err
+	}
+
+	if t.opts.FilterPolicy == nil {
+		return nil
+	}
+
+	filterContent, err := t.readMetaBlock(metaIndex, filterBlockKeyPrefix+t.opts.FilterPolicy.Name())
+	if err != nil {
+		return err
+	}
+	if filterContent != nil {
+		t.filter = newFilterBlockReader(t.opts.FilterPolicy, filterContent)
+	}
+
+	return nil
+}
+
+// checkComparator: compare the comparator name recorded in the properties block with the table comparator
+// tables without a properties block are accepted as is
+func (t *Table) checkComparator(properties slice.Slice) error {
+	if properties == nil {
+		return nil
+	}
+
+	iter := block.NewIter(block.New(properties, t.format), comparator.Bytewise)
+	iter.Find(slice.Slice(comparatorKey))
+	if !iter.Success() || string(iter.Key()) != comparatorKey {
+		return nil
+	}
+	if name := string(iter.Value()); name != t.cmp.Name() {
+		return fmt.Errorf("%w: table %s, given %s", ErrComparatorMismatch, name, t.cmp.Name())
+	}
+
+	return nil
+}
+
+// readMetaBlock: read the content of the meta block named name, returns nil if it does not exist
+func (t *Table) readMetaBlock(metaIndex *block.Block, name string) (slice.Slice, error) {
+	iter := block.NewIter(metaIndex, comparator.Bytewise)
+	iter.Find(slice.Slice(name))
+	if !iter.Success() || string(iter.Key()) != name {
+		return nil, nil
+	}
+
+	handle, err := t.decodeHandle(iter.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	return readBlock(handle, t.File, t.format)
+}
+
+// decodeHandle: parse a block handle stored in the index block or the metaindex block
+func (t *Table) decodeHandle(value slice.Slice) (*block.Handle, error) {
+	handle, _, err := t.format.DecodeHandle(value)
+
+	return handle, err
 }
 
-func readBlock(handle *block.Handle, file file.RandomReader) (slice.Slice, error) {
+// readBlock: read the block of handle from a table in the given format, and verify its checksum
+func readBlock(handle *block.Handle, file file.RandomReader, format Format) (slice.Slice, error) {
 	content, err := file.Read(handle.Offset, handle.Size+blockTailSize)
 	if err != nil {
 		return nil, err
 	}
 
-	crc := binary.BigEndian.Uint32(content[handle.Size+1:])
-	contentCrc := crc32.ChecksumIEEE(content[:handle.Size+1])
-	if crc != contentCrc {
+	crc := format.ByteOrder().Uint32(content[handle.Size+1:])
+	if crc != blockChecksum(format, content[:handle.Size], content[handle.Size]) {
 		return nil, ErrCrcValidation
 	}
 
-	return content[:handle.Size], nil
+	return compress.Decompress(compress.Type(content[handle.Size]), content[:handle.Size])
 }
 
-func (t *Table) Get(key slice.Slice) (slice.Slice, error) {
-	blockIter := block.NewIter(t.IndexBlock)
-	blockIter.Find(key)
-	if !blockIter.Success() {
-		return nil, fmt.Errorf("%s:%w", key, ErrNoSuchKey)
+// readDataBlock: read the data block of handle, through the block cache if it is configured
+func (t *Table) readDataBlock(handle *block.Handle, ropts *ReadOptions) (*block.Block, error) {
+	blockCache := t.opts.BlockCache
+	if blockCache == nil {
+		content, err := readBlock(handle, t.File, t.format)
+		if err != nil {
+			return nil, err
+		}
+
+		return block.New(content, t.format), nil
 	}
 
-	handle := block.NewHandle(blockIter.Value())
-	blockContent, err := readBlock(handle, t.File)
+	cacheKey := make([]byte, 16)
+	binary.BigEndian.PutUint64(cacheKey, t.cacheID)
+	binary.BigEndian.PutUint64(cacheKey[8:], handle.Offset)
+	if h := blockCache.Lookup(cacheKey); h != nil {
+		// blocks are immutable and garbage collected, it is safe to use the block after releasing the handle
+		dataBlock := h.Value().(*block.Block)
+		blockCache.Release(h)
+
+		return dataBlock, nil
+	}
+
+	content, err := readBlock(handle, t.File, t.format)
 	if err != nil {
 		return nil, err
 	}
+	dataBlock := block.New(content, t.format)
+	if ropts.fillCache() {
+		blockCache.Release(blockCache.Insert(cacheKey, dataBlock, len(content), nil))
+	}
 
-	dataBlock := block.New(blockContent)
-	dataBlockIter := block.NewIter(dataBlock)
-	dataBlockIter.Find(key)
-	if !dataBlockIter.Success() {
-		return nil, fmt.Errorf("%s:%w", key, ErrNoSuchKey)
+	return dataBlock, nil
+}
+
+// Get: get the value of key, ropts may be nil
+func (t *Table) Get(key slice.Slice, ropts *ReadOptions) (slice.Slice, error) {
+	foundKey, value, err := t.Seek(key, ropts)
+	if err != nil {
+		return nil, err
 	}
 	// revalidate the keys
-	if key.Compare(dataBlockIter.Key()) > 0 {
+	if t.cmp.Compare(key, foundKey) != 0 {
 		return nil, fmt.Errorf("%s:%w", key, ErrNoSuchKey)
 	}
 
-	return dataBlockIter.Value(), nil
+	return value, nil
+}
+
+// Seek: get the first entry whose key is not smaller than key, ropts may be nil.
+// The filter of the data block which may contain key is consulted first, so ErrNoSuchKey is returned
+// if the filter rules key out, even though there may be larger keys in the table.
+func (t *Table) Seek(key slice.Slice, ropts *ReadOptions) (foundKey, value slice.Slice, err error) {
+	indexIter := block.NewIter(t.IndexBlock, t.cmp)
+	indexIter.Find(key)
+	if !indexIter.Success() {
+		return nil, nil, fmt.Errorf("%s:%w", key, ErrNoSuchKey)
+	}
+
+	handle, err := t.decodeHandle(indexIter.Value())
+	if err != nil {
+		return nil, nil, err
+	}
+	if t.filter != nil && !t.filter.keyMayMatch(handle.Offset, key) {
+		return nil, nil, fmt.Errorf("%s:%w", key, ErrNoSuchKey)
+	}
+
+	for {
+		dataBlock, err := t.readDataBlock(handle, ropts)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		dataBlockIter := block.NewIter(dataBlock, t.cmp)
+		dataBlockIter.Find(key)
+		if dataBlockIter.Success() {
+			return dataBlockIter.Key(), dataBlockIter.Value(), nil
+		}
+
+		// key is larger than all keys of the block but not its index key, the entry is in the following blocks
+		indexIter.Next()
+		if !indexIter.Success() {
+			return nil, nil, fmt.Errorf("%s:%w", key, ErrNoSuchKey)
+		}
+		if handle, err = t.decodeHandle(indexIter.Value()); err != nil {
+			return nil, nil, err
+		}
+	}
 }
@@ -1,10 +1,23 @@
 package table
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/goleveldb/goleveldb/cache"
+	"github.com/goleveldb/goleveldb/comparator"
+	"github.com/goleveldb/goleveldb/compress"
+	"github.com/goleveldb/goleveldb/crc32c"
 	"github.com/goleveldb/goleveldb/file"
+	"github.com/goleveldb/goleveldb/filter"
 	"github.com/goleveldb/goleveldb/slice"
-	"testing"
+	"github.com/goleveldb/goleveldb/table/block"
 )
 
 type entry struct {
@@ -50,12 +63,14 @@ func newStringWriter(s *stringReader) file.Writer {
 }
 
 type stringReader struct {
-	data []byte
+	data  []byte
+	reads int // number of Read calls
 }
 
 var _ file.RandomReader = (*stringReader)(nil)
 
 func (s *stringReader) Read(offset, n uint64) (slice.Slice, error) {
+	s.reads++
 	if offset+n > uint64(len(s.data)) {
 		return nil, file.ErrOutOfBoundary
 	}
@@ -63,6 +78,10 @@ func (s *stringReader) Read(offset, n uint64) (slice.Slice, error) {
 	return s.data[offset : offset+n], nil
 }
 
+func (s *stringReader) Close() error {
+	return nil
+}
+
 func newStringReader() *stringReader {
 	return &stringReader{}
 }
@@ -78,7 +97,7 @@ func assertFalse(t *testing.T, boolVal bool, assertMsg string) {
 }
 
 func newTable(t *testing.T, stringReader *stringReader) *Table {
-	table, err := New(stringReader, len(stringReader.data))
+	table, err := New(stringReader, len(stringReader.data), nil)
 	assertTrue(t, err == nil, fmt.Sprintf("%s", err))
 
 	return table
@@ -95,17 +114,19 @@ func TestTable_Get(t *testing.T) {
 		t.Run(testCase.name, func(t *testing.T) {
 			fileReader := newStringReader()
 			fileWriter := newStringWriter(fileReader)
-			tableWriter := NewWriter(fileWriter)
+			tableWriter := NewWriter(fileWriter, nil)
 			for _, entry := range testCase.writeEntries {
 				assertTrue(t, nil == tableWriter.Add(entry.key, entry.value), "append failed")
 			}
 
 			err := tableWriter.Finish()
 			assertTrue(t, nil == err, fmt.Sprintf("%v", err))
+			assertTrue(t, tableWriter.FileSize() == uint64(len(fileReader.data)),
+				fmt.Sprintf("file size %d, written %d", tableWriter.FileSize(), len(fileReader.data)))
 
 			table := newTable(t, fileReader)
 			for _, entry := range testCase.writeEntries {
-				getVal, err := table.Get(entry.key)
+				getVal, err := table.Get(entry.key, nil)
 				assertTrue(t, nil == err, fmt.Sprintf("write %s, gotErr %s", entry.key, err))
 				assertTrue(t, getVal.Compare(entry.value) == 0,
 					fmt.Sprintf("write %s, got %s", entry.key, getVal))
@@ -114,6 +135,363 @@ func TestTable_Get(t *testing.T) {
 	}
 }
 
+func TestTable_Seek(t *testing.T) {
+	entries := entriesWithFixedValue("gggggg", "wdnmd_%d", 20480)
+	fileReader := newStringReader()
+	tableWriter := NewWriter(newStringWriter(fileReader), nil)
+	for _, entry := range entries {
+		assertTrue(t, nil == tableWriter.Add(entry.key, entry.value), "append failed")
+	}
+	assertTrue(t, nil == tableWriter.Finish(), "finish failed")
+	table := newTable(t, fileReader)
+
+	for i, entry := range entries {
+		foundKey, value, err := table.Seek(entry.key, nil)
+		assertTrue(t, nil == err, fmt.Sprintf("seek %s, gotErr %s", entry.key, err))
+		assertTrue(t, foundKey.Compare(entry.key) == 0 && value.Compare(entry.value) == 0,
+			fmt.Sprintf("seek %s, got %s", entry.key, foundKey))
+
+		// an absent key finds the next entry, which may be the first entry of the next data block
+		absent := slice.Slice(string(entry.key) + "\x00")
+		foundKey, _, err = table.Seek(absent, nil)
+		if i == len(entries)-1 {
+			assertTrue(t, errors.Is(err, ErrNoSuchKey), fmt.Sprintf("want ErrNoSuchKey, got %v", err))
+			continue
+		}
+		assertTrue(t, nil == err, fmt.Sprintf("seek %s, gotErr %s", absent, err))
+		assertTrue(t, foundKey.Compare(entries[i+1].key) == 0,
+			fmt.Sprintf("seek %s, got %s, want %s", absent, foundKey, entries[i+1].key))
+	}
+}
+
+func TestTable_Comparator(t *testing.T) {
+	cmp := comparator.Reverse(comparator.Bytewise)
+	entries := entriesWithFixedValue("gggggg", "wdnmd_%d", 2048)
+
+	fileReader := newStringReader()
+	tableWriter := NewWriter(newStringWriter(fileReader), &Options{Comparator: cmp})
+	assertTrue(t, nil == tableWriter.Add(entries[0].key, entries[0].value), "append failed")
+	assertTrue(t, errors.Is(tableWriter.Add(entries[1].key, entries[1].value), ErrKeyOrder),
+		"keys out of comparator order should be rejected")
+
+	fileReader = newStringReader()
+	tableWriter = NewWriter(newStringWriter(fileReader), &Options{Comparator: cmp})
+	for i := len(entries) - 1; i >= 0; i-- {
+		assertTrue(t, nil == tableWriter.Add(entries[i].key, entries[i].value), "append failed")
+	}
+	assertTrue(t, nil == tableWriter.Finish(), "finish failed")
+
+	_, err := New(fileReader, len(fileReader.data), nil)
+	assertTrue(t, errors.Is(err, ErrComparatorMismatch), fmt.Sprintf("want ErrComparatorMismatch, got %v", err))
+
+	table, err := New(fileReader, len(fileReader.data), &Options{Comparator: cmp})
+	assertTrue(t, err == nil, fmt.Sprintf("%s", err))
+	for _, entry := range entries {
+		getVal, err := table.Get(entry.key, nil)
+		assertTrue(t, nil == err, fmt.Sprintf("write %s, gotErr %s", entry.key, err))
+		assertTrue(t, getVal.Compare(entry.value) == 0, fmt.Sprintf("write %s, got %s", entry.key, getVal))
+	}
+
+	_, err = table.Get(slice.Slice("not exist"), nil)
+	assertTrue(t, errors.Is(err, ErrNoSuchKey), fmt.Sprintf("want ErrNoSuchKey, got %v", err))
+}
+
+func TestTable_GetWithFilter(t *testing.T) {
+	opts := &Options{FilterPolicy: filter.NewBloomFilterPolicy(10)}
+	entries := entriesWithFixedValue("gggggg", "wdnmd_%d", 20480)
+
+	fileReader := newStringReader()
+	tableWriter := NewWriter(newStringWriter(fileReader), opts)
+	for _, entry := range entries {
+		assertTrue(t, nil == tableWriter.Add(entry.key, entry.value), "append failed")
+	}
+	assertTrue(t, nil == tableWriter.Finish(), "finish failed")
+
+	table, err := New(fileReader, len(fileReader.data), opts)
+	assertTrue(t, err == nil, fmt.Sprintf("%s", err))
+	assertTrue(t, table.filter != nil, "filter block should be loaded")
+
+	for _, entry := range entries {
+		getVal, err := table.Get(entry.key, nil)
+		assertTrue(t, nil == err, fmt.Sprintf("write %s, gotErr %s", entry.key, err))
+		assertTrue(t, getVal.Compare(entry.value) == 0, fmt.Sprintf("write %s, got %s", entry.key, getVal))
+	}
+
+	// most absent keys should be ruled out by the filter without reading data blocks
+	fileReader.reads = 0
+	missing := 10000
+	for i := 0; i < missing; i++ {
+		_, err := table.Get(slice.Slice(fmt.Sprintf("wdnmd_%d_missing", i)), nil)
+		assertTrue(t, errors.Is(err, ErrNoSuchKey), fmt.Sprintf("want ErrNoSuchKey, got %v", err))
+	}
+	assertTrue(t, fileReader.reads < missing/50, fmt.Sprintf("%d data blocks read for %d absent keys", fileReader.reads, missing))
+
+	// a table opened without the filter policy still works
+	table, err = New(fileReader, len(fileReader.data), nil)
+	assertTrue(t, err == nil && table.filter == nil, fmt.Sprintf("%s", err))
+	getVal, err := table.Get(entries[0].key, nil)
+	assertTrue(t, err == nil && getVal.Compare(entries[0].value) == 0, fmt.Sprintf("%s", err))
+}
+
+func TestTable_ReusedKeyBuffer(t *testing.T) {
+	opts := &Options{FilterPolicy: filter.NewBloomFilterPolicy(10)}
+	entries := entriesWithFixedValue("gggggg", "wdnmd_%d", 20480)
+
+	// the writer must not keep the key passed to Add, the caller overwrites it before the next call
+	fileReader := newStringReader()
+	tableWriter := NewWriter(newStringWriter(fileReader), opts)
+	var buf []byte
+	for _, entry := range entries {
+		buf = append(buf[:0], entry.key...)
+		assertTrue(t, nil == tableWriter.Add(slice.Slice(buf), entry.value), "append failed")
+		for i := range buf {
+			buf[i] = 0xff
+		}
+	}
+	assertTrue(t, nil == tableWriter.Finish(), "finish failed")
+
+	table, err := New(fileReader, len(fileReader.data), opts)
+	assertTrue(t, err == nil, fmt.Sprintf("%s", err))
+	for _, entry := range entries {
+		getVal, err := table.Get(entry.key, nil)
+		assertTrue(t, nil == err, fmt.Sprintf("write %s, gotErr %s", entry.key, err))
+		assertTrue(t, getVal.Compare(entry.value) == 0, fmt.Sprintf("write %s, got %s", entry.key, getVal))
+	}
+
+	it := table.NewIterator(nil)
+	defer it.Release()
+	i := 0
+	for it.SeekToFirst(); it.Success(); it.Next() {
+		assertTrue(t, it.Key().Compare(entries[i].key) == 0, fmt.Sprintf("iterate %d, got %s, want %s", i, it.Key(), entries[i].key))
+		i++
+	}
+	assertTrue(t, i == len(entries), fmt.Sprintf("iterated %d entries, want %d", i, len(entries)))
+}
+
+func TestTable_Compression(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression compress.Type
+		randomValue bool
+		wantType    compress.Type
+	}{
+		{name: "no_compression", compression: compress.NoCompression, wantType: compress.NoCompression},
+		{name: "snappy", compression: compress.SnappyCompression, wantType: compress.SnappyCompression},
+		{name: "flate", compression: compress.FlateCompression, wantType: compress.FlateCompression},
+		// random values can not be compressed, blocks fall back to be stored uncompressed
+		{name: "snappy_fallback", compression: compress.SnappyCompression, randomValue: true, wantType: compress.NoCompression},
+		{name: "flate_fallback", compression: compress.FlateCompression, randomValue: true, wantType: compress.NoCompression},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &Options{Compression: tt.compression}
+			entries := entriesWithFixedValue(strings.Repeat("gg", 50), "wdnmd_%05d", 2000)
+			if tt.randomValue {
+				for _, entry := range entries {
+					entry.value = randomValue(100)
+				}
+			}
+
+			fileReader := newStringReader()
+			tableWriter := NewWriter(newStringWriter(fileReader), opts)
+			for _, entry := range entries {
+				assertTrue(t, nil == tableWriter.Add(entry.key, entry.value), "append failed")
+			}
+			assertTrue(t, nil == tableWriter.Finish(), "finish failed")
+
+			// the first data block starts at offset 0, check the compression type in its trailer
+			table := newTable(t, fileReader)
+			iter := block.NewIter(table.IndexBlock, comparator.Bytewise)
+			iter.SeekToFirst()
+			assertTrue(t, iter.Success(), "index block should not be empty")
+			handle := block.NewHandle(iter.Value())
+			gotType := compress.Type(fileReader.data[handle.Offset+handle.Size])
+			assertTrue(t, gotType == tt.wantType, fmt.Sprintf("want compression %s, got %s", tt.wantType, gotType))
+
+			// the reader decompresses blocks by their trailers, regardless of the options
+			for _, entry := range entries {
+				getVal, err := table.Get(entry.key, nil)
+				assertTrue(t, nil == err, fmt.Sprintf("write %s, gotErr %s", entry.key, err))
+				assertTrue(t, getVal.Compare(entry.value) == 0, fmt.Sprintf("write %s, got %s", entry.key, getVal))
+			}
+		})
+	}
+}
+
+func TestTable_BlockCache(t *testing.T) {
+	entries := entriesWithFixedValue("gggggg", "wdnmd_%05d", 20480)
+	fileReader := newStringReader()
+	tableWriter := NewWriter(newStringWriter(fileReader), nil)
+	for _, entry := range entries {
+		assertTrue(t, nil == tableWriter.Add(entry.key, entry.value), "append failed")
+	}
+	assertTrue(t, nil == tableWriter.Finish(), "finish failed")
+
+	blockCache := cache.NewLRUCache(1 << 20)
+	table, err := New(fileReader, len(fileReader.data), &Options{BlockCache: blockCache})
+	assertTrue(t, err == nil, fmt.Sprintf("%s", err))
+
+	// reads with DontFillCache do not add blocks to the cache
+	noFill := &ReadOptions{DontFillCache: true}
+	iter := table.NewIterator(noFill)
+	for iter.SeekToFirst(); iter.Success(); iter.Next() {
+	}
+	_, err = table.Get(entries[0].key, noFill)
+	assertTrue(t, err == nil, fmt.Sprintf("%s", err))
+	assertTrue(t, blockCache.TotalCharge() == 0, fmt.Sprintf("cache charge %d after reads without filling cache", blockCache.TotalCharge()))
+
+	for _, entry := range entries {
+		getVal, err := table.Get(entry.key, nil)
+		assertTrue(t, nil == err, fmt.Sprintf("write %s, gotErr %s", entry.key, err))
+		assertTrue(t, getVal.Compare(entry.value) == 0, fmt.Sprintf("write %s, got %s", entry.key, getVal))
+	}
+	misses := blockCache.Misses()
+	assertTrue(t, misses > 1 && blockCache.Hits() > misses, fmt.Sprintf("hits %d, misses %d", blockCache.Hits(), misses))
+
+	// all data blocks are cached now, reads do not touch the file
+	fileReader.reads = 0
+	for _, entry := range entries {
+		getVal, err := table.Get(entry.key, nil)
+		assertTrue(t, nil == err, fmt.Sprintf("write %s, gotErr %s", entry.key, err))
+		assertTrue(t, getVal.Compare(entry.value) == 0, fmt.Sprintf("write %s, got %s", entry.key, getVal))
+	}
+	iter = table.NewIterator(nil)
+	count := 0
+	for iter.SeekToFirst(); iter.Success(); iter.Next() {
+		count++
+	}
+	assertTrue(t, count == len(entries), fmt.Sprintf("iterate %d entries, want %d", count, len(entries)))
+	assertTrue(t, fileReader.reads == 0, fmt.Sprintf("%d blocks read with a warm cache", fileReader.reads))
+	assertTrue(t, blockCache.Misses() == misses, fmt.Sprintf("misses %d, want %d", blockCache.Misses(), misses))
+
+	// tables sharing the cache do not see the blocks of each other
+	other, err := New(fileReader, len(fileReader.data), &Options{BlockCache: blockCache})
+	assertTrue(t, err == nil, fmt.Sprintf("%s", err))
+	_, err = other.Get(entries[0].key, nil)
+	assertTrue(t, err == nil, fmt.Sprintf("%s", err))
+	assertTrue(t, blockCache.Misses() == misses+1, fmt.Sprintf("misses %d, want %d", blockCache.Misses(), misses+1))
+}
+
+// goldenLevelDBTable is built by a reference implementation of the C++ LevelDB table builder with
+// the bytewise comparator, a 10 bits per key bloom filter and no compression.
+// It contains key%05d => value%05d repeated i%7+1 times, for i in [0, 1000)
+const goldenLevelDBTable = "testdata/leveldb.ldb"
+
+func goldenLevelDBEntries() []*entry {
+	entries := make([]*entry, 1000)
+	for i := range entries {
+		entries[i] = makeEntry(fmt.Sprintf("key%05d", i), strings.Repeat(fmt.Sprintf("value%05d", i), i%7+1))
+	}
+
+	return entries
+}
+
+func TestTable_ReadLevelDBFormat(t *testing.T) {
+	content, err := ioutil.ReadFile(goldenLevelDBTable)
+	assertTrue(t, err == nil, fmt.Sprintf("read golden table: %v", err))
+
+	fileReader := &stringReader{data: content}
+	table, err := New(fileReader, len(content), &Options{FilterPolicy: filter.NewBloomFilterPolicy(10)})
+	assertTrue(t, err == nil, fmt.Sprintf("open golden table: %v", err))
+	assertTrue(t, table.format == FormatLevelDB, "the format should be detected from the footer")
+	assertTrue(t, table.filter != nil, "the filter block should be loaded")
+
+	entries := goldenLevelDBEntries()
+	iter := table.NewIterator(nil)
+	iter.SeekToFirst()
+	for _, entry := range entries {
+		assertTrue(t, iter.Success(), fmt.Sprintf("iterator stops before %s", entry.key))
+		assertTrue(t, iter.Key().Compare(entry.key) == 0 && iter.Value().Compare(entry.value) == 0,
+			fmt.Sprintf("want %s, got %s", entry.key, iter.Key()))
+		iter.Next()
+	}
+	assertFalse(t, iter.Success(), "iterator should be exhausted")
+	assertTrue(t, iter.Err() == nil, fmt.Sprintf("%v", iter.Err()))
+
+	for _, entry := range entries {
+		getVal, err := table.Get(entry.key, nil)
+		assertTrue(t, nil == err, fmt.Sprintf("get %s, gotErr %s", entry.key, err))
+		assertTrue(t, getVal.Compare(entry.value) == 0, fmt.Sprintf("get %s, got %s", entry.key, getVal))
+	}
+
+	// most missing keys are ruled out by the filter without reading data blocks
+	reads := fileReader.reads
+	for i := 0; i < 1000; i++ {
+		_, err := table.Get(slice.Slice(fmt.Sprintf("key%05d.missing", i)), nil)
+		assertTrue(t, errors.Is(err, ErrNoSuchKey), fmt.Sprintf("want %v, got %v", ErrNoSuchKey, err))
+	}
+	assertTrue(t, fileReader.reads-reads < 50, fmt.Sprintf("%d data blocks read for missing keys", fileReader.reads-reads))
+}
+
+func TestTable_WriteLevelDBFormat(t *testing.T) {
+	entries := goldenLevelDBEntries()
+	fileReader := newStringReader()
+	tableWriter := NewWriter(newStringWriter(fileReader), &Options{
+		FilterPolicy: filter.NewBloomFilterPolicy(10),
+		Format:       FormatLevelDB,
+	})
+	for _, entry := range entries {
+		assertTrue(t, nil == tableWriter.Add(entry.key, entry.value), "append failed")
+	}
+	assertTrue(t, nil == tableWriter.Finish(), "finish failed")
+
+	golden, err := ioutil.ReadFile(goldenLevelDBTable)
+	assertTrue(t, err == nil, fmt.Sprintf("read golden table: %v", err))
+	if !bytes.Equal(fileReader.data, golden) {
+		n := 0
+		for n < len(golden) && n < len(fileReader.data) && golden[n] == fileReader.data[n] {
+			n++
+		}
+		t.Fatalf("table differs from %s at offset %d, size %d, want %d", goldenLevelDBTable, n, len(fileReader.data), len(golden))
+	}
+
+	// footer: varint metaindex handle | varint index handle | padding | magic (little endian)
+	data := fileReader.data
+	footerBytes := data[len(data)-footerLength:]
+	assertTrue(t, binary.LittleEndian.Uint64(footerBytes[footerLength-8:]) == tableMagicNumber, "magic should be little endian")
+	metaIndexHandle, n, err := block.NewVarintHandle(footerBytes)
+	assertTrue(t, err == nil, fmt.Sprintf("%v", err))
+	indexHandle, _, err := block.NewVarintHandle(footerBytes[n:])
+	assertTrue(t, err == nil, fmt.Sprintf("%v", err))
+
+	// block trailers: type | masked crc32c of content and type (little endian)
+	for _, handle := range []*block.Handle{metaIndexHandle, indexHandle} {
+		trailer := data[handle.Offset+handle.Size : handle.Offset+handle.Size+blockTailSize]
+		want := crc32c.Mask(crc32c.Value(data[handle.Offset : handle.Offset+handle.Size+1]))
+		assertTrue(t, binary.LittleEndian.Uint32(trailer[1:]) == want, "block checksum should be masked crc32c")
+	}
+
+	// restart arrays are little endian and index entries point to data blocks with varint handles
+	indexContent := data[indexHandle.Offset : indexHandle.Offset+indexHandle.Size]
+	numRestarts := binary.LittleEndian.Uint32(indexContent[len(indexContent)-4:])
+	assertTrue(t, int(numRestarts)*4+4 < len(indexContent), fmt.Sprintf("bad number of restarts %d", numRestarts))
+	iter := block.NewIter(block.New(indexContent, FormatLevelDB), comparator.Bytewise)
+	offset := uint64(0)
+	for iter.SeekToFirst(); iter.Success(); iter.Next() {
+		handle, n, err := block.NewVarintHandle(iter.Value())
+		assertTrue(t, err == nil && n == len(iter.Value()), fmt.Sprintf("bad index entry %v", iter.Value()))
+		assertTrue(t, handle.Offset == offset, fmt.Sprintf("data block at %d, want %d", handle.Offset, offset))
+		offset += handle.Size + blockTailSize
+	}
+	assertTrue(t, offset > 0, "index block should not be empty")
+
+	table := newTable(t, fileReader)
+	assertTrue(t, table.format == FormatLevelDB, "the format should be detected from the footer")
+	for _, entry := range entries {
+		getVal, err := table.Get(entry.key, nil)
+		assertTrue(t, nil == err, fmt.Sprintf("get %s, gotErr %s", entry.key, err))
+		assertTrue(t, getVal.Compare(entry.value) == 0, fmt.Sprintf("get %s, got %s", entry.key, getVal))
+	}
+}
+
+func randomValue(n int) slice.Slice {
+	b := make([]byte, n)
+	rand.Read(b)
+
+	return b
+}
+
 func makeEntry(k, v string) *entry {
 	return &entry{
 		key:   []byte(k),
@@ -1,10 +1,13 @@
 package table
 
 import (
-	"encoding/binary"
+	"errors"
 	"hash/crc32"
 
+	"github.com/goleveldb/goleveldb/comparator"
+	"github.com/goleveldb/goleveldb/compress"
 	"github.com/goleveldb/goleveldb/config"
+	"github.com/goleveldb/goleveldb/crc32c"
 	"github.com/goleveldb/goleveldb/file"
 	"github.com/goleveldb/goleveldb/slice"
 	"github.com/goleveldb/goleveldb/table/block"
@@ -13,118 +16,208 @@ import (
 type Writer interface {
 	Add(k, v slice.Slice) error
 	Finish() error
+	// FileSize returns the number of bytes written to the file so far, the size of the whole table after Finish
+	FileSize() uint64
 }
 
 type writerImpl struct {
-	indexBlock block.Writer
-	dataBlock  block.Writer
-	file       file.Writer
-	offset     uint64
-	lastKey    slice.Slice
+	opts        *Options
+	cmp         comparator.Comparator
+	filterBlock *filterBlockWriter // nil if no filter policy is configured
+	indexBlock  block.Writer
+	dataBlock   block.Writer
+	file        file.Writer
+	offset      uint64
+	lastKey     slice.Slice
+	numEntries  int
+
+	// the index entry of a data block is added when the first key of the next block arrives,
+	// so that a short separator between the two blocks can be used as the index key
+	pendingIndexEntry bool
+	pendingHandle     *block.Handle
 }
 
 const (
 	blockTailSize = 4 + 1 // extra bytes (4 for crc validation info, 1 for compression type) for block serialization
 
-	noCompression byte = 0 // do not compress the value
+	// keys in the metaindex block and the properties block
+	propertiesBlockKey = "goleveldb.properties"
+	comparatorKey      = "comparator"
 )
 
-var _ Writer = (*writerImpl)(nil)
+var (
+	_ Writer = (*writerImpl)(nil)
+
+	ErrKeyOrder = errors.New("keys must be added to the table in increasing order")
+)
 
 // NewWriter: create a concrete instrance for TableWriter interface
-func NewWriter(file file.Writer) Writer {
-	return &writerImpl{
-		indexBlock: block.NewWriter(),
-		dataBlock:  block.NewWriter(),
+func NewWriter(file file.Writer, opts *Options) Writer {
+	opts = opts.sanitize()
+	w := &writerImpl{
+		opts:       opts,
+		cmp:        opts.Comparator,
+		indexBlock: block.NewWriter(opts.Comparator, opts.Format, config.INDEX_RESTART_INTERVAL),
+		dataBlock:  block.NewWriter(opts.Comparator, opts.Format, config.BLOCK_RESTART_INTERVAL),
 		file:       file,
 	}
+
+	if opts.FilterPolicy != nil {
+		w.filterBlock = newFilterBlockWriter(opts.FilterPolicy)
+		w.filterBlock.startBlock(0)
+	}
+
+	return w
 }
 
 // Add: add an entry to current table
 func (t *writerImpl) Add(key, value slice.Slice) error {
-	if err := t.dataBlock.AddEntry(key, value); err != nil {
-		return err
+	if t.numEntries > 0 && t.cmp.Compare(key, t.lastKey) <= 0 {
+		return ErrKeyOrder
 	}
 
-	currentSize := t.dataBlock.Size()
-	if currentSize >= config.BLOCK_MAX_SIZE {
-		blockOffset, blockSize := t.flush()
-		blockHandle := &block.Handle{
-			Offset: uint64(blockOffset),
-			Size:   uint64(blockSize),
-		}
-
-		if err := t.indexBlock.AddEntry(key, blockHandle.ToSlice()); err != nil {
+	if t.pendingIndexEntry {
+		separator := t.cmp.FindShortestSeparator(t.lastKey, key)
+		if err := t.indexBlock.AddEntry(separator, t.opts.Format.EncodeHandle(t.pendingHandle)); err != nil {
 			return err
 		}
+		t.pendingIndexEntry = false
+	}
+
+	if t.filterBlock != nil {
+		t.filterBlock.addKey(key)
+	}
+
+	if err := t.dataBlock.AddEntry(key, value); err != nil {
+		return err
+	}
+	// the caller may reuse the memory of key after Add returns
+	t.lastKey = append(t.lastKey[:0], key...)
+	t.numEntries++
+
+	if t.dataBlock.Size() >= config.BLOCK_MAX_SIZE {
+		return t.flush()
 	}
 
-	t.lastKey = key
 	return nil
 }
 
-// flush: flush the content in TableWriter to storage
-func (t *writerImpl) flush() (offset, size int) {
-	offset, size = t.writeBlockContent(t.dataBlock.Finish(), noCompression)
+// flush: flush the pending data block to storage, its index entry is added on next Add() or Finish()
+func (t *writerImpl) flush() error {
+	handle, err := t.writeBlock(t.dataBlock.Finish())
+	if err != nil {
+		return err
+	}
 	t.dataBlock.Reset()
 
-	return
+	if t.filterBlock != nil {
+		t.filterBlock.startBlock(t.offset)
+	}
+
+	t.pendingIndexEntry = true
+	t.pendingHandle = handle
+
+	return nil
 }
 
-//	writerBlockContent: append block content with its type and crc info to file
-//	format:
-//  block_data: Slice
-//	type : uint8
-//	crc : uint32
-// 	returns <offset, size> of the block written in the file
-func (t *writerImpl) writeBlockContent(content slice.Slice, cType byte) (offset, size int) {
+// writeBlock: compress the block with the configured compression type and write it,
+// the block is stored uncompressed if compression saves less than 1/8 of its size
+func (t *writerImpl) writeBlock(raw slice.Slice) (*block.Handle, error) {
+	cType := t.opts.Compression
+	if cType == compress.NoCompression {
+		return t.writeBlockContent(raw, compress.NoCompression)
+	}
+
+	compressed, err := compress.Compress(cType, raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(compressed) >= len(raw)-len(raw)/8 {
+		return t.writeBlockContent(raw, compress.NoCompression)
+	}
+
+	return t.writeBlockContent(compressed, cType)
+}
+
+// writeBlockContent: append block content with its type and crc info to file
+// format:
+//   - block_data : Slice
+//   - type : uint8
+//   - crc : uint32 (see blockChecksum)
+//
+// returns the handle of the block written in the file
+func (t *writerImpl) writeBlockContent(content slice.Slice, cType compress.Type) (*block.Handle, error) {
 	if err := t.file.Append(content); err != nil {
-		return
+		return nil, err
 	}
 
 	tail := make([]byte, blockTailSize)
-	tail[0] = cType
-	// TODO compression is not needed at this moment, so we set tail[0] to 0 in a fixed way
-	checksum := crc32.Update(
-		crc32.ChecksumIEEE(content), crc32.IEEETable, []byte{tail[0]})
-	binary.BigEndian.PutUint32(tail[1:], checksum)
+	tail[0] = byte(cType)
+	t.opts.Format.ByteOrder().PutUint32(tail[1:], blockChecksum(t.opts.Format, content, tail[0]))
 	if err := t.file.Append(tail); err != nil {
-		return
+		return nil, err
 	}
 	if err := t.file.Flush(); err != nil {
-		return
+		return nil, err
 	}
 
-	blockOffset, blockSize := t.offset, len(content)
+	handle := &block.Handle{
+		Offset: t.offset,
+		Size:   uint64(len(content)),
+	}
 	t.offset += uint64(len(content) + blockTailSize)
 
-	return int(blockOffset), blockSize
+	return handle, nil
+}
+
+// blockChecksum: the checksum of a block content followed by its compression type, which is
+// a big-endian IEEE CRC32 in FormatGoLevelDB, and a little-endian masked CRC32C in FormatLevelDB
+func blockChecksum(format Format, content slice.Slice, cType byte) uint32 {
+	if format == FormatLevelDB {
+		return crc32c.Mask(crc32c.Extend(crc32c.Value(content), []byte{cType}))
+	}
+
+	return crc32.Update(crc32.ChecksumIEEE(content), crc32.IEEETable, []byte{cType})
+}
+
+// FileSize: number of bytes written so far, the pending data block is not counted
+func (t *writerImpl) FileSize() uint64 {
+	return t.offset
 }
 
 // Finish: flush everything in the table to its file storage
-// TODO metaindex block.
-// currently, only index block and footer are implemented
+// layout: data blocks | filter block | properties block (FormatGoLevelDB only) | metaindex block | index block | footer
 func (t *writerImpl) Finish() error {
-	// TODO meta index block
-
 	// flush remaining data block if any new entry is written in it
-	if t.dataBlock.Size() > 0 {
-		dataBlockOffset, dataBlockSize := t.flush()
-		blockHandle := &block.Handle{
-			Offset: uint64(dataBlockOffset),
-			Size:   uint64(dataBlockSize),
+	if !t.dataBlock.Empty() {
+		if err := t.flush(); err != nil {
+			return err
 		}
-		t.indexBlock.AddEntry(t.lastKey, blockHandle.ToSlice())
 	}
 
-	indexBlockOffset, indexBlockSize := t.writeBlockContent(t.indexBlock.Finish(), noCompression)
+	if t.pendingIndexEntry {
+		successor := t.cmp.FindShortSuccessor(t.lastKey)
+		if err := t.indexBlock.AddEntry(successor, t.opts.Format.EncodeHandle(t.pendingHandle)); err != nil {
+			return err
+		}
+		t.pendingIndexEntry = false
+	}
+
+	metaIndexHandle, err := t.writeMetaBlocks()
+	if err != nil {
+		return err
+	}
+
+	indexHandle, err := t.writeBlock(t.indexBlock.Finish())
+	if err != nil {
+		return err
+	}
 
 	// writer sstable footer
 	tableFooter := &footer{
-		indexHandle: &block.Handle{
-			Offset: uint64(indexBlockOffset),
-			Size:   uint64(indexBlockSize),
-		},
+		format:          t.opts.Format,
+		indexHandle:     indexHandle,
+		metaIndexHandle: metaIndexHandle,
 	}
 	if err := t.file.Append(tableFooter.toSlice()); err != nil {
 		return err
@@ -136,3 +229,46 @@ func (t *writerImpl) Finish() error {
 
 	return nil
 }
+
+// writeMetaBlocks: write the filter block, the properties block (FormatGoLevelDB only) and the metaindex block pointing to them,
+// returns the handle of the metaindex block
+func (t *writerImpl) writeMetaBlocks() (*block.Handle, error) {
+	// keys in meta blocks are always ordered bytewise, regardless of the table comparator
+	metaIndex := block.NewWriter(comparator.Bytewise, t.opts.Format, config.BLOCK_RESTART_INTERVAL)
+
+	if t.filterBlock != nil {
+		filterHandle, err := t.writeBlockContent(t.filterBlock.finish(), compress.NoCompression)
+		if err != nil {
+			return nil, err
+		}
+
+		filterKey := slice.Slice(filterBlockKeyPrefix + t.opts.FilterPolicy.Name())
+		if err := metaIndex.AddEntry(filterKey, t.opts.Format.EncodeHandle(filterHandle)); err != nil {
+			return nil, err
+		}
+	}
+
+	// LevelDB writes no properties block, FormatLevelDB tables leave it out to stay byte-identical
+	// with LevelDB's output, so their comparator is not checked when they are opened
+	if t.opts.Format != FormatLevelDB {
+		if err := t.writePropertiesBlock(metaIndex); err != nil {
+			return nil, err
+		}
+	}
+
+	return t.writeBlock(metaIndex.Finish())
+}
+
+// writePropertiesBlock: write the properties block and add its entry to metaIndex
+func (t *writerImpl) writePropertiesBlock(metaIndex block.Writer) error {
+	properties := block.NewWriter(comparator.Bytewise, t.opts.Format, config.BLOCK_RESTART_INTERVAL)
+	if err := properties.AddEntry(slice.Slice(comparatorKey), slice.Slice(t.cmp.Name())); err != nil {
+		return err
+	}
+	propertiesHandle, err := t.writeBlockContent(properties.Finish(), compress.NoCompression)
+	if err != nil {
+		return err
+	}
+
+	return metaIndex.AddEntry(slice.Slice(propertiesBlockKey), t.opts.Format.EncodeHandle(propertiesHandle))
+}
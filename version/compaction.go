@@ -0,0 +1,194 @@
+package version
+
+import (
+	"github.com/goleveldb/goleveldb/ikey"
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+// Compaction 描述一次压缩: 将若干层中的文件合并, 输出到 OutputLevel() 层.
+// 输入按数据从新到旧排列, 同一个 key 在前面的输入中的版本更新.
+type Compaction struct {
+	version           *Version
+	inputs            []compactionInput
+	outputLevel       int
+	maxOutputFileSize uint64
+	deletionOnly      bool
+	edit              VersionEdit
+}
+
+// compactionInput 参与压缩的某一层的文件.
+type compactionInput struct {
+	level int
+	files []*FileMetaData
+}
+
+// NumInputLevels 返回参与压缩的层数.
+func (c *Compaction) NumInputLevels() int {
+	return len(c.inputs)
+}
+
+// InputLevel 返回第 which 个输入所在的层.
+func (c *Compaction) InputLevel(which int) int {
+	return c.inputs[which].level
+}
+
+// Inputs 返回第 which 个输入的文件, 可能为空. 第 0 层的文件按从新到旧排列.
+func (c *Compaction) Inputs(which int) []*FileMetaData {
+	return c.inputs[which].files
+}
+
+// OutputLevel 返回压缩输出写入的层.
+func (c *Compaction) OutputLevel() int {
+	return c.outputLevel
+}
+
+// MaxOutputFileSize 返回输出文件的大小上限, 超过后切换到新的文件.
+func (c *Compaction) MaxOutputFileSize() uint64 {
+	return c.maxOutputFileSize
+}
+
+// Edit 返回记录本次压缩结果的 VersionEdit.
+func (c *Compaction) Edit() *VersionEdit {
+	return &c.edit
+}
+
+// IsDeletionCompaction 返回压缩是否只需删除输入文件, 不产生输出.
+func (c *Compaction) IsDeletionCompaction() bool {
+	return c.deletionOnly
+}
+
+// IsTrivialMove 只有一个输入文件且输出层中没有与之重叠的文件时, 直接将该文件移到输出层, 无需重写.
+func (c *Compaction) IsTrivialMove() bool {
+	numFiles := 0
+	for _, in := range c.inputs {
+		numFiles += len(in.files)
+	}
+
+	return !c.deletionOnly && numFiles == 1 && len(c.inputs[0].files) == 1 && c.inputs[0].level != c.outputLevel
+}
+
+// MaxCreationTime 返回输入文件中最晚的数据写入时间, 作为输出文件的创建时间, 未知时返回 0.
+func (c *Compaction) MaxCreationTime() int64 {
+	var res int64
+	for _, in := range c.inputs {
+		for _, f := range in.files {
+			if f.CreationTime > res {
+				res = f.CreationTime
+			}
+		}
+	}
+
+	return res
+}
+
+// IsBaseLevelForKey 返回输出层之下的各层中是否都没有可能包含 userKey 的文件.
+// 此时输出中 userKey 的删除标记之下没有更旧的数据, 对所有快照都不可见的删除标记可以丢弃.
+func (c *Compaction) IsBaseLevelForKey(userKey slice.Slice) bool {
+	return c.version.IsBaseLevelForKey(c.outputLevel, userKey)
+}
+
+// AddInputDeletions 在 edit 中删除所有输入文件.
+func (c *Compaction) AddInputDeletions(edit *VersionEdit) {
+	for _, in := range c.inputs {
+		for _, f := range in.files {
+			edit.DeleteFile(in.level, f.Number)
+		}
+	}
+}
+
+// NeedsCompaction 返回当前 Version 是否需要压缩.
+// 使用 CompactionStyleFIFO 时, 即使 Version 不变, table 也会随时间过期.
+func (s *VersionSet) NeedsCompaction() bool {
+	if s.opts.CompactionStyle == CompactionStyleFIFO {
+		return len(s.pickFIFOFiles()) > 0
+	}
+
+	return s.current.compactionScore >= 1
+}
+
+// PickCompaction 按 CompactionStyle 选择需要压缩的文件, 不需要压缩时返回 nil.
+func (s *VersionSet) PickCompaction() *Compaction {
+	if !s.NeedsCompaction() {
+		return nil
+	}
+
+	switch s.opts.CompactionStyle {
+	case CompactionStyleFIFO:
+		return s.pickFIFOCompaction()
+	case CompactionStyleUniversal:
+		return s.pickUniversalCompaction()
+	default:
+		return s.pickLeveledCompaction()
+	}
+}
+
+// pickLeveledCompaction 选择分数最高的层, 将其中的文件与下一层中重叠的文件合并到下一层.
+// 同一层的文件轮流被压缩: 每次从上一次压缩的最大 key 之后的第一个文件开始.
+func (s *VersionSet) pickLeveledCompaction() *Compaction {
+	v := s.current
+	level := v.compactionLevel
+	var inputs []*FileMetaData
+	for _, f := range v.files[level] {
+		if s.compactPointers[level] == nil || s.icmp.Compare(f.Largest, s.compactPointers[level]) > 0 {
+			inputs = []*FileMetaData{f}
+			break
+		}
+	}
+	if len(inputs) == 0 {
+		// 已经到达该层的末尾, 从头开始.
+		inputs = []*FileMetaData{v.files[level][0]}
+	}
+
+	// 第 0 层的文件之间可能重叠, 需要同时压缩所有重叠的文件, 否则旧的版本可能覆盖新的版本.
+	smallest, largest := v.keyRange(inputs)
+	if level == 0 {
+		inputs = v.OverlappingInputs(0, ikey.InternalKey(smallest).UserKey(), ikey.InternalKey(largest).UserKey())
+		smallest, largest = v.keyRange(inputs)
+	}
+
+	c := &Compaction{
+		version: v,
+		inputs: []compactionInput{
+			{level: level, files: inputs},
+			{level: level + 1, files: v.OverlappingInputs(level+1, ikey.InternalKey(smallest).UserKey(), ikey.InternalKey(largest).UserKey())},
+		},
+		outputLevel:       level + 1,
+		maxOutputFileSize: s.opts.TargetFileSize,
+	}
+	c.edit.SetCompactPointer(level, append(slice.Slice(nil), largest...))
+
+	return c
+}
+
+// finalize 计算 v 需要压缩的程度, 分数不小于 1 时需要压缩.
+// CompactionStyleFIFO 在 NeedsCompaction 时计算.
+func (s *VersionSet) finalize(v *Version) {
+	switch s.opts.CompactionStyle {
+	case CompactionStyleFIFO:
+		v.compactionLevel, v.compactionScore = 0, 0
+	case CompactionStyleUniversal:
+		v.compactionLevel = 0
+		v.compactionScore = float64(len(sortedRuns(v))) / float64(s.opts.L0CompactionTrigger)
+	default:
+		s.finalizeLeveled(v)
+	}
+}
+
+// finalizeLeveled 计算 v 中最需要压缩的层:
+// 第 0 层按文件数计算分数, 因为每次读取都可能需要查找第 0 层的所有文件;
+// 其余各层按总大小与该层大小上限的比值计算分数. 最后一层不需要压缩.
+func (s *VersionSet) finalizeLeveled(v *Version) {
+	v.compactionLevel, v.compactionScore = -1, -1
+	for level := 0; level < NumLevels-1; level++ {
+		var score float64
+		if level == 0 {
+			score = float64(len(v.files[0])) / float64(s.opts.L0CompactionTrigger)
+		} else {
+			score = float64(totalFileSize(v.files[level])) / s.opts.maxBytesForLevel(level)
+		}
+
+		if score > v.compactionScore {
+			v.compactionLevel, v.compactionScore = level, score
+		}
+	}
+}
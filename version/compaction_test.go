@@ -0,0 +1,389 @@
+package version
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+// newTestVersionSet 创建包含 files 的 VersionSet, files 的下标为层数.
+func newTestVersionSet(t *testing.T, opts *Options, files map[int][]*FileMetaData) *VersionSet {
+	t.Helper()
+
+	s := NewVersionSet(t.TempDir(), opts)
+	s.MarkFileNumberUsed(1000)
+	edit := &VersionEdit{}
+	for level, levelFiles := range files {
+		for _, f := range levelFiles {
+			edit.AddFile(level, f)
+		}
+	}
+	if err := s.LogAndApply(edit); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func TestVersion_OverlappingInputs(t *testing.T) {
+	s := newTestVersionSet(t, nil, map[int][]*FileMetaData{
+		0: {testFile(1, "a", "c"), testFile(2, "b", "f"), testFile(3, "e", "h"), testFile(4, "x", "z")},
+		1: {testFile(5, "a", "c"), testFile(6, "d", "f"), testFile(7, "g", "k")},
+	})
+	v := s.Current()
+
+	tests := []struct {
+		name       string
+		level      int
+		begin, end string
+		want       []uint64
+	}{
+		{name: "level 0 expands range", level: 0, begin: "a", end: "a", want: []uint64{3, 2, 1}},
+		{name: "level 0 no expansion", level: 0, begin: "y", end: "y", want: []uint64{4}},
+		{name: "level 1", level: 1, begin: "c", end: "e", want: []uint64{5, 6}},
+		{name: "level 1 boundary", level: 1, begin: "k", end: "z", want: []uint64{7}},
+		{name: "level 1 unbounded", level: 1, want: []uint64{5, 6, 7}},
+		{name: "no overlap", level: 1, begin: "l", end: "w"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var begin, end slice.Slice
+			if tt.begin != "" {
+				begin, end = slice.Slice(tt.begin), slice.Slice(tt.end)
+			}
+			got := fileNumbers(v.OverlappingInputs(tt.level, begin, end))
+			if len(got) != len(tt.want) {
+				t.Fatalf("OverlappingInputs() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("OverlappingInputs() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestVersion_IsBaseLevelForKey(t *testing.T) {
+	s := newTestVersionSet(t, nil, map[int][]*FileMetaData{
+		0: {testFile(1, "a", "z")},
+		1: {testFile(2, "a", "c")},
+		3: {testFile(3, "e", "g")},
+	})
+	v := s.Current()
+
+	tests := []struct {
+		level int
+		key   string
+		want  bool
+	}{
+		{level: 0, key: "b", want: false},
+		{level: 1, key: "b", want: true},
+		{level: 1, key: "f", want: false},
+		{level: 2, key: "g", want: false},
+		{level: 3, key: "f", want: true},
+		{level: 0, key: "x", want: true},
+	}
+	for _, tt := range tests {
+		if got := v.IsBaseLevelForKey(tt.level, slice.Slice(tt.key)); got != tt.want {
+			t.Errorf("IsBaseLevelForKey(%d, %s) = %v, want %v", tt.level, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestVersionSet_PickCompaction(t *testing.T) {
+	t.Run("no compaction needed", func(t *testing.T) {
+		s := newTestVersionSet(t, nil, map[int][]*FileMetaData{
+			0: {testFile(1, "a", "c"), testFile(2, "b", "d")},
+		})
+		if s.NeedsCompaction() || s.PickCompaction() != nil {
+			t.Error("2 level 0 files should not be compacted")
+		}
+	})
+
+	t.Run("level 0 by file count", func(t *testing.T) {
+		s := newTestVersionSet(t, &Options{L0CompactionTrigger: 3}, map[int][]*FileMetaData{
+			0: {testFile(1, "a", "c"), testFile(2, "b", "d"), testFile(3, "m", "n")},
+			1: {testFile(4, "a", "b"), testFile(5, "c", "e"), testFile(6, "f", "z")},
+		})
+		c := s.PickCompaction()
+		if c == nil || c.InputLevel(0) != 0 {
+			t.Fatalf("PickCompaction() = %+v, want level 0", c)
+		}
+		// 从最新的文件 3 开始, 与其重叠的第 0 层文件只有它自身.
+		if got := fileNumbers(c.Inputs(0)); len(got) != 1 || got[0] != 3 {
+			t.Errorf("inputs[0] = %v, want [3]", got)
+		}
+		if got := fileNumbers(c.Inputs(1)); len(got) != 1 || got[0] != 6 {
+			t.Errorf("inputs[1] = %v, want [6]", got)
+		}
+	})
+
+	t.Run("level 0 overlapping files", func(t *testing.T) {
+		s := newTestVersionSet(t, &Options{L0CompactionTrigger: 2}, map[int][]*FileMetaData{
+			0: {testFile(1, "a", "c"), testFile(2, "b", "d")},
+			1: {testFile(4, "a", "b"), testFile(5, "x", "z")},
+		})
+		c := s.PickCompaction()
+		if got := fileNumbers(c.Inputs(0)); len(got) != 2 || got[0] != 2 || got[1] != 1 {
+			t.Errorf("inputs[0] = %v, want [2 1]", got)
+		}
+		if got := fileNumbers(c.Inputs(1)); len(got) != 1 || got[0] != 4 {
+			t.Errorf("inputs[1] = %v, want [4]", got)
+		}
+		if c.IsTrivialMove() {
+			t.Error("IsTrivialMove() = true, want false")
+		}
+	})
+
+	t.Run("level by size", func(t *testing.T) {
+		// 第 1 层 600 + 700 字节超过上限 1000, 第 2 层 800 字节未超过上限 10000.
+		s := newTestVersionSet(t, &Options{MaxBytesForLevelBase: 1000}, map[int][]*FileMetaData{
+			1: {testFile(6, "a", "c"), testFile(7, "d", "f")},
+			2: {testFile(8, "b", "e")},
+		})
+		if s.Current().NumLevelBytes(1) != 1300 {
+			t.Fatalf("NumLevelBytes(1) = %d, want 1300", s.Current().NumLevelBytes(1))
+		}
+		c := s.PickCompaction()
+		if c == nil || c.InputLevel(0) != 1 {
+			t.Fatalf("PickCompaction() = %+v, want level 1", c)
+		}
+		if got := fileNumbers(c.Inputs(0)); len(got) != 1 || got[0] != 6 {
+			t.Errorf("inputs[0] = %v, want [6]", got)
+		}
+		if got := fileNumbers(c.Inputs(1)); len(got) != 1 || got[0] != 8 {
+			t.Errorf("inputs[1] = %v, want [8]", got)
+		}
+		if c.MaxOutputFileSize() != defaultTargetFileSize {
+			t.Errorf("MaxOutputFileSize() = %d, want %d", c.MaxOutputFileSize(), defaultTargetFileSize)
+		}
+	})
+
+	t.Run("round robin within level", func(t *testing.T) {
+		dir := t.TempDir()
+		opts := &Options{MaxBytesForLevelBase: 100}
+		s := NewVersionSet(dir, opts)
+		s.MarkFileNumberUsed(1000)
+		edit := &VersionEdit{}
+		edit.AddFile(1, testFile(1, "a", "b"))
+		edit.AddFile(1, testFile(2, "c", "d"))
+		edit.AddFile(1, testFile(3, "e", "f"))
+		if err := s.LogAndApply(edit); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, want := range []uint64{1, 2, 3, 1} {
+			c := s.PickCompaction()
+			if got := fileNumbers(c.Inputs(0)); len(got) != 1 || got[0] != want {
+				t.Fatalf("inputs[0] = %v, want [%d]", got, want)
+			}
+			if !c.IsTrivialMove() {
+				t.Error("IsTrivialMove() = false, want true")
+			}
+			// 只记录压缩的结束位置, 不移动文件.
+			if err := s.LogAndApply(c.Edit()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s.Close()
+
+		// 压缩的结束位置记录在 MANIFEST 中.
+		recovered := NewVersionSet(dir, opts)
+		if err := recovered.Recover(); err != nil {
+			t.Fatal(err)
+		}
+		if got := fileNumbers(recovered.PickCompaction().Inputs(0)); len(got) != 1 || got[0] != 2 {
+			t.Errorf("inputs[0] after recovery = %v, want [2]", got)
+		}
+	})
+}
+
+// sizedFile 返回大小为 size 的文件.
+func sizedFile(number, size uint64, smallest, largest string) *FileMetaData {
+	f := testFile(number, smallest, largest)
+	f.Size = size
+
+	return f
+}
+
+func TestVersionSet_PickUniversalCompaction(t *testing.T) {
+	type input struct {
+		level int
+		files []uint64
+	}
+	tests := []struct {
+		name        string
+		trigger     int
+		files       map[int][]*FileMetaData
+		wantInputs  []input // 为空时不需要压缩
+		outputLevel int
+	}{
+		{
+			name:    "fewer runs than trigger",
+			trigger: 3,
+			files: map[int][]*FileMetaData{
+				0: {sizedFile(1, 100, "a", "b")},
+				6: {sizedFile(2, 100, "a", "b")},
+			},
+		},
+		{
+			name:    "similar size runs",
+			trigger: 3,
+			files: map[int][]*FileMetaData{
+				0: {sizedFile(1, 100, "a", "b"), sizedFile(2, 100, "a", "b"), sizedFile(3, 100, "a", "b")},
+				6: {sizedFile(4, 10000, "a", "z")},
+			},
+			wantInputs:  []input{{level: 0, files: []uint64{3, 2, 1}}},
+			outputLevel: 5,
+		},
+		{
+			name:    "space amplification",
+			trigger: 3,
+			files: map[int][]*FileMetaData{
+				0: {sizedFile(1, 100, "a", "b"), sizedFile(2, 100, "a", "b")},
+				6: {sizedFile(3, 50, "a", "z")},
+			},
+			wantInputs:  []input{{level: 0, files: []uint64{2, 1}}, {level: 6, files: []uint64{3}}},
+			outputLevel: 6,
+		},
+		{
+			name:    "reduce number of runs",
+			trigger: 3,
+			files: map[int][]*FileMetaData{
+				0: {sizedFile(1, 10000, "a", "b"), sizedFile(2, 1000, "a", "b"), sizedFile(3, 100, "a", "b")},
+				6: {sizedFile(4, 100000, "a", "z")},
+			},
+			// 合并最新的两个 sorted run, 输出层之上没有空闲的层, 因此加入文件 1.
+			wantInputs:  []input{{level: 0, files: []uint64{3, 2, 1}}},
+			outputLevel: 5,
+		},
+		{
+			name:    "no free level above the next run",
+			trigger: 2,
+			files: map[int][]*FileMetaData{
+				0: {sizedFile(1, 100, "a", "b"), sizedFile(2, 100, "a", "b")},
+				1: {sizedFile(3, 1000, "a", "z")},
+				6: {sizedFile(4, 10000, "a", "z")},
+			},
+			wantInputs:  []input{{level: 0, files: []uint64{2, 1}}, {level: 1, files: []uint64{3}}},
+			outputLevel: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestVersionSet(t, &Options{CompactionStyle: CompactionStyleUniversal, L0CompactionTrigger: tt.trigger}, tt.files)
+			c := s.PickCompaction()
+			if len(tt.wantInputs) == 0 {
+				if s.NeedsCompaction() || c != nil {
+					t.Fatalf("PickCompaction() = %+v, want nil", c)
+				}
+				return
+			}
+			if c == nil {
+				t.Fatal("PickCompaction() = nil")
+			}
+
+			var got []input
+			for i := 0; i < c.NumInputLevels(); i++ {
+				got = append(got, input{level: c.InputLevel(i), files: fileNumbers(c.Inputs(i))})
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantInputs) {
+				t.Errorf("inputs = %v, want %v", got, tt.wantInputs)
+			}
+			if c.OutputLevel() != tt.outputLevel {
+				t.Errorf("OutputLevel() = %d, want %d", c.OutputLevel(), tt.outputLevel)
+			}
+		})
+	}
+}
+
+func TestVersionSet_PickFIFOCompaction(t *testing.T) {
+	now := time.Now()
+	timedFile := func(number, size uint64, age time.Duration) *FileMetaData {
+		f := sizedFile(number, size, "a", "z")
+		f.CreationTime = now.Add(-age).Unix()
+		return f
+	}
+
+	tests := []struct {
+		name  string
+		fifo  FIFOOptions
+		files map[int][]*FileMetaData
+		want  []uint64 // 被删除的文件, 从旧到新
+	}{
+		{
+			name:  "within limits",
+			fifo:  FIFOOptions{MaxTableFilesSize: 1000, TTL: time.Hour},
+			files: map[int][]*FileMetaData{0: {timedFile(1, 400, time.Minute), timedFile(2, 400, 0)}},
+		},
+		{
+			name: "exceeds size limit",
+			fifo: FIFOOptions{MaxTableFilesSize: 1000},
+			files: map[int][]*FileMetaData{
+				0: {timedFile(1, 400, 0), timedFile(2, 400, 0), timedFile(3, 400, 0), timedFile(4, 400, 0)},
+			},
+			want: []uint64{1, 2},
+		},
+		{
+			name: "expired files",
+			fifo: FIFOOptions{MaxTableFilesSize: 1000, TTL: time.Hour},
+			files: map[int][]*FileMetaData{
+				0: {timedFile(1, 100, 3*time.Hour), timedFile(2, 100, 2*time.Hour), timedFile(3, 100, time.Minute)},
+			},
+			want: []uint64{1, 2},
+		},
+		{
+			name: "unknown creation time never expires",
+			fifo: FIFOOptions{MaxTableFilesSize: 1000, TTL: time.Hour},
+			files: map[int][]*FileMetaData{
+				0: {sizedFile(1, 100, "a", "z"), timedFile(2, 100, 2*time.Hour)},
+			},
+		},
+		{
+			name: "deeper levels are older",
+			fifo: FIFOOptions{MaxTableFilesSize: 500},
+			files: map[int][]*FileMetaData{
+				0: {timedFile(5, 200, 0)},
+				1: {sizedFile(4, 200, "a", "b"), sizedFile(3, 200, "c", "d")},
+			},
+			want: []uint64{3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestVersionSet(t, &Options{CompactionStyle: CompactionStyleFIFO, FIFO: tt.fifo}, tt.files)
+			c := s.PickCompaction()
+			if len(tt.want) == 0 {
+				if s.NeedsCompaction() || c != nil {
+					t.Fatalf("PickCompaction() = %+v, want nil", c)
+				}
+				return
+			}
+			if c == nil || !c.IsDeletionCompaction() || c.IsTrivialMove() {
+				t.Fatalf("PickCompaction() = %+v, want a deletion compaction", c)
+			}
+
+			var got []uint64
+			for i := 0; i < c.NumInputLevels(); i++ {
+				got = append(got, fileNumbers(c.Inputs(i))...)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("deleted files = %v, want %v", got, tt.want)
+			}
+
+			c.AddInputDeletions(c.Edit())
+			if err := s.LogAndApply(c.Edit()); err != nil {
+				t.Fatal(err)
+			}
+			if s.NeedsCompaction() {
+				t.Error("NeedsCompaction() = true after deleting the picked files")
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+// Package version 记录组成 DB 的 table 文件: 每个 Version 描述某一时刻各层包含的文件,
+// VersionEdit 描述相邻 Version 之间的差异, 并以记录的形式保存在 MANIFEST 中.
+package version
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+// VersionEdit 中各字段的标记, 1 ~ 7 以及 9 与 LevelDB 的 MANIFEST 格式一致.
+const (
+	tagComparator     = 1
+	tagLogNumber      = 2
+	tagNextFileNumber = 3
+	tagLastSequence   = 4
+	tagCompactPointer = 5
+	tagDeletedFile    = 6
+	tagNewFile        = 7
+	// tagPrevLogNumber LevelDB 记录的上一个日志编号, 仅用于兼容旧版本的 LevelDB, 解析时忽略.
+	tagPrevLogNumber = 9
+
+	// tagNewFileWithCreationTime 记录了创建时间的新文件, LevelDB 无法识别该标记.
+	// 只有 VersionSet 使用 CompactionStyleFIFO 时才会写入 MANIFEST, 见 Options.recordsCreationTime.
+	tagNewFileWithCreationTime = 100
+)
+
+// ErrCorruptedEdit VersionEdit 记录无法解析.
+var ErrCorruptedEdit = errors.New("corrupted version edit")
+
+// FileMetaData 描述一个 table 文件.
+type FileMetaData struct {
+	// Number 文件编号.
+	Number uint64
+	// Size 文件大小(字节).
+	Size uint64
+	// Smallest 文件中最小的 internal key.
+	Smallest slice.Slice
+	// Largest 文件中最大的 internal key.
+	Largest slice.Slice
+	// CreationTime 文件中数据的写入时间(Unix 秒), 不晚于文件的创建时间, 为 0 时表示未知.
+	// 压缩生成的文件使用输入文件中最晚的时间.
+	CreationTime int64
+}
+
+type deletedFile struct {
+	level  int
+	number uint64
+}
+
+type newFile struct {
+	level int
+	meta  *FileMetaData
+}
+
+type compactPointer struct {
+	level int
+	key   slice.Slice
+}
+
+// VersionEdit 描述对 Version 的一次修改, 未设置的字段不会被编码.
+type VersionEdit struct {
+	comparator     string
+	logNumber      uint64
+	nextFileNumber uint64
+	lastSequence   uint64
+
+	hasComparator     bool
+	hasLogNumber      bool
+	hasNextFileNumber bool
+	hasLastSequence   bool
+
+	compactPointers []compactPointer
+	deletedFiles    []deletedFile
+	newFiles        []newFile
+}
+
+// SetComparatorName 记录比较器名称, 打开 DB 时据此检查比较器是否一致.
+func (e *VersionEdit) SetComparatorName(name string) {
+	e.hasComparator = true
+	e.comparator = name
+}
+
+// SetLogNumber 记录日志编号, 编号小于 number 的日志内容均已写入 table.
+func (e *VersionEdit) SetLogNumber(number uint64) {
+	e.hasLogNumber = true
+	e.logNumber = number
+}
+
+// SetNextFileNumber 记录下一个可用的文件编号.
+func (e *VersionEdit) SetNextFileNumber(number uint64) {
+	e.hasNextFileNumber = true
+	e.nextFileNumber = number
+}
+
+// SetLastSequence 记录已使用的最大序列号.
+func (e *VersionEdit) SetLastSequence(seq uint64) {
+	e.hasLastSequence = true
+	e.lastSequence = seq
+}
+
+// SetCompactPointer 记录 level 层上一次压缩的最大 key, 下一次压缩从该 key 之后开始.
+func (e *VersionEdit) SetCompactPointer(level int, key slice.Slice) {
+	e.compactPointers = append(e.compactPointers, compactPointer{level: level, key: key})
+}
+
+// AddFile 向 level 层添加文件.
+func (e *VersionEdit) AddFile(level int, meta *FileMetaData) {
+	e.newFiles = append(e.newFiles, newFile{level: level, meta: meta})
+}
+
+// DeleteFile 从 level 层删除编号为 number 的文件.
+func (e *VersionEdit) DeleteFile(level int, number uint64) {
+	e.deletedFiles = append(e.deletedFiles, deletedFile{level: level, number: number})
+}
+
+// Encode 将 VersionEdit 编码为 MANIFEST 中的一条记录.
+// 格式为若干字段, 每个字段以 varint 编码的标记开头:
+//   - comparator: tag | varint length | name
+//   - log number, next file number, last sequence: tag | varint
+//   - compact pointer: tag | varint level | varint length | key
+//   - deleted file: tag | varint level | varint number
+//   - new file: tag | varint level | varint number | varint size | varint length | smallest | varint length | largest
+//   - new file with creation time: new file 的各字段 | varint creation time
+//
+// 创建时间未知的文件使用 new file 字段, 与 LevelDB 的格式一致.
+func (e *VersionEdit) Encode() slice.Slice {
+	return e.encode(true)
+}
+
+// encode 编码 VersionEdit, creationTime 为 false 时不记录文件的创建时间, 所有新文件都使用 LevelDB 的格式.
+func (e *VersionEdit) encode(creationTime bool) slice.Slice {
+	var dst []byte
+	if e.hasComparator {
+		dst = putUvarint(dst, tagComparator)
+		dst = putLengthPrefixed(dst, slice.Slice(e.comparator))
+	}
+	if e.hasLogNumber {
+		dst = putUvarint(dst, tagLogNumber)
+		dst = putUvarint(dst, e.logNumber)
+	}
+	if e.hasNextFileNumber {
+		dst = putUvarint(dst, tagNextFileNumber)
+		dst = putUvarint(dst, e.nextFileNumber)
+	}
+	if e.hasLastSequence {
+		dst = putUvarint(dst, tagLastSequence)
+		dst = putUvarint(dst, e.lastSequence)
+	}
+
+	for _, p := range e.compactPointers {
+		dst = putUvarint(dst, tagCompactPointer)
+		dst = putUvarint(dst, uint64(p.level))
+		dst = putLengthPrefixed(dst, p.key)
+	}
+
+	for _, f := range e.deletedFiles {
+		dst = putUvarint(dst, tagDeletedFile)
+		dst = putUvarint(dst, uint64(f.level))
+		dst = putUvarint(dst, f.number)
+	}
+
+	for _, f := range e.newFiles {
+		withCreationTime := creationTime && f.meta.CreationTime > 0
+		if withCreationTime {
+			dst = putUvarint(dst, tagNewFileWithCreationTime)
+		} else {
+			dst = putUvarint(dst, tagNewFile)
+		}
+		dst = putUvarint(dst, uint64(f.level))
+		dst = putUvarint(dst, f.meta.Number)
+		dst = putUvarint(dst, f.meta.Size)
+		dst = putLengthPrefixed(dst, f.meta.Smallest)
+		dst = putLengthPrefixed(dst, f.meta.Largest)
+		if withCreationTime {
+			dst = putUvarint(dst, uint64(f.meta.CreationTime))
+		}
+	}
+
+	return dst
+}
+
+// Decode 从 MANIFEST 的一条记录中解析 VersionEdit.
+func (e *VersionEdit) Decode(src slice.Slice) error {
+	*e = VersionEdit{}
+	d := &decoder{src: src}
+	for len(d.src) > 0 && d.err == nil {
+		switch tag := d.uvarint(); tag {
+		case tagComparator:
+			e.SetComparatorName(string(d.lengthPrefixed()))
+		case tagLogNumber:
+			e.SetLogNumber(d.uvarint())
+		case tagNextFileNumber:
+			e.SetNextFileNumber(d.uvarint())
+		case tagLastSequence:
+			e.SetLastSequence(d.uvarint())
+		case tagPrevLogNumber:
+			d.uvarint()
+		case tagCompactPointer:
+			level := d.level()
+			e.SetCompactPointer(level, d.lengthPrefixed())
+		case tagDeletedFile:
+			level := d.level()
+			e.DeleteFile(level, d.uvarint())
+		case tagNewFile, tagNewFileWithCreationTime:
+			level := d.level()
+			meta := &FileMetaData{}
+			meta.Number = d.uvarint()
+			meta.Size = d.uvarint()
+			meta.Smallest = d.lengthPrefixed()
+			meta.Largest = d.lengthPrefixed()
+			if tag == tagNewFileWithCreationTime {
+				meta.CreationTime = int64(d.uvarint())
+			}
+			e.AddFile(level, meta)
+		default:
+			if d.err == nil {
+				d.err = fmt.Errorf("%w: unknown tag %d", ErrCorruptedEdit, tag)
+			}
+		}
+	}
+
+	return d.err
+}
+
+func putUvarint(dst []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+
+	return append(dst, buf[:n]...)
+}
+
+func putLengthPrefixed(dst []byte, s slice.Slice) []byte {
+	dst = putUvarint(dst, uint64(len(s)))
+
+	return append(dst, s...)
+}
+
+// decoder 依次解析字段, 出错后不再解析, 错误记录在 err 中.
+type decoder struct {
+	src slice.Slice
+	err error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(d.src)
+	if n <= 0 {
+		d.err = fmt.Errorf("%w: bad varint", ErrCorruptedEdit)
+		return 0
+	}
+	d.src = d.src[n:]
+
+	return v
+}
+
+func (d *decoder) level() int {
+	level := d.uvarint()
+	if d.err == nil && level >= NumLevels {
+		d.err = fmt.Errorf("%w: level %d", ErrCorruptedEdit, level)
+	}
+
+	return int(level)
+}
+
+func (d *decoder) lengthPrefixed() slice.Slice {
+	length := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if length > uint64(len(d.src)) {
+		d.err = fmt.Errorf("%w: bad length %d", ErrCorruptedEdit, length)
+		return nil
+	}
+
+	res := make(slice.Slice, length)
+	copy(res, d.src)
+	d.src = d.src[length:]
+
+	return res
+}
@@ -0,0 +1,111 @@
+package version
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+func TestVersionEdit_EncodeDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func() *VersionEdit
+	}{
+		{name: "empty", edit: func() *VersionEdit { return &VersionEdit{} }},
+		{name: "all fields", edit: func() *VersionEdit {
+			edit := &VersionEdit{}
+			edit.SetComparatorName("foo")
+			edit.SetLogNumber(7)
+			edit.SetNextFileNumber(1 << 40)
+			edit.SetLastSequence(1<<56 + 3)
+			for i := uint64(0); i < 4; i++ {
+				edit.AddFile(int(i), &FileMetaData{
+					Number:   300 + i,
+					Size:     1 << 31,
+					Smallest: slice.Slice("foo"),
+					Largest:  slice.Slice("zoo"),
+					// i 为 0 时创建时间未知, 使用 LevelDB 的格式.
+					CreationTime: int64(i) * 1600000000,
+				})
+				edit.DeleteFile(int(i+1), 100+i)
+				edit.SetCompactPointer(int(i), slice.Slice(fmt.Sprintf("key_%d", i)))
+			}
+			return edit
+		}},
+		{name: "zero values", edit: func() *VersionEdit {
+			edit := &VersionEdit{}
+			edit.SetLogNumber(0)
+			edit.AddFile(0, &FileMetaData{Smallest: slice.Slice{}, Largest: slice.Slice{}})
+			return edit
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edit := tt.edit()
+			encoded := edit.Encode()
+
+			decoded := &VersionEdit{}
+			if err := decoded.Decode(encoded); err != nil {
+				t.Fatalf("Decode() => get err = %v", err)
+			}
+			if !reflect.DeepEqual(decoded.Encode(), encoded) {
+				t.Errorf("re-encoded edit differs: %v, want %v", decoded.Encode(), encoded)
+			}
+			if decoded.hasLogNumber != edit.hasLogNumber || decoded.logNumber != edit.logNumber ||
+				len(decoded.newFiles) != len(edit.newFiles) || len(decoded.deletedFiles) != len(edit.deletedFiles) ||
+				len(decoded.compactPointers) != len(edit.compactPointers) {
+				t.Errorf("Decode() = %+v, want %+v", decoded, edit)
+			}
+			for i, f := range decoded.newFiles {
+				if f.meta.CreationTime != edit.newFiles[i].meta.CreationTime {
+					t.Errorf("file %d creation time = %d, want %d", f.meta.Number, f.meta.CreationTime, edit.newFiles[i].meta.CreationTime)
+				}
+			}
+		})
+	}
+}
+
+func TestVersionEdit_EncodeWithoutCreationTime(t *testing.T) {
+	f := &FileMetaData{Number: 3, Size: 100, Smallest: slice.Slice("a"), Largest: slice.Slice("b")}
+	edit := &VersionEdit{}
+	edit.AddFile(1, f)
+	want := edit.Encode()
+
+	// 不记录创建时间时, 新文件的编码与创建时间未知的文件相同.
+	f.CreationTime = 1600000000
+	got := edit.encode(false)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("encode(false) = %v, want %v", got, want)
+	}
+	if got[0] != tagNewFile {
+		t.Errorf("tag = %d, want %d", got[0], tagNewFile)
+	}
+}
+
+func TestVersionEdit_DecodeCorruption(t *testing.T) {
+	edit := &VersionEdit{}
+	edit.SetComparatorName("foo")
+	edit.AddFile(1, &FileMetaData{Number: 1, Size: 2, Smallest: slice.Slice("a"), Largest: slice.Slice("b")})
+	encoded := edit.Encode()
+
+	tests := []struct {
+		name  string
+		input slice.Slice
+	}{
+		{name: "unknown tag", input: slice.Slice{8, 1}},
+		{name: "truncated varint", input: slice.Slice{tagLogNumber, 0x80}},
+		{name: "truncated string", input: encoded[:len(encoded)-1]},
+		{name: "bad level", input: slice.Slice{tagDeletedFile, NumLevels, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (&VersionEdit{}).Decode(tt.input); !errors.Is(err, ErrCorruptedEdit) {
+				t.Errorf("Decode() => want err = %v, get err = %v", ErrCorruptedEdit, err)
+			}
+		})
+	}
+}
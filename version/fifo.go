@@ -0,0 +1,81 @@
+package version
+
+import (
+	"sort"
+	"time"
+)
+
+// levelFile 某一层中的文件.
+type levelFile struct {
+	level int
+	meta  *FileMetaData
+}
+
+// filesOldestFirst 按数据从旧到新返回 v 中的所有文件: 层数越大数据越旧, 同一层中编号越小数据越旧.
+func filesOldestFirst(v *Version) []levelFile {
+	var res []levelFile
+	for level := NumLevels - 1; level >= 1; level-- {
+		var files []levelFile
+		for _, f := range v.files[level] {
+			files = append(files, levelFile{level: level, meta: f})
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i].meta.Number < files[j].meta.Number })
+		res = append(res, files...)
+	}
+	for i := len(v.files[0]) - 1; i >= 0; i-- {
+		res = append(res, levelFile{level: 0, meta: v.files[0][i]})
+	}
+
+	return res
+}
+
+// pickFIFOFiles 从最旧的文件开始, 选择需要删除的文件:
+// 所有文件的总大小超过 MaxTableFilesSize 时, 删除最旧的文件直到总大小不超过上限;
+// 否则删除所有已过期的文件.
+func (s *VersionSet) pickFIFOFiles() []levelFile {
+	files := filesOldestFirst(s.current)
+	var total uint64
+	for _, f := range files {
+		total += f.meta.Size
+	}
+
+	var res []levelFile
+	if total > s.opts.FIFO.MaxTableFilesSize {
+		for _, f := range files {
+			if total <= s.opts.FIFO.MaxTableFilesSize {
+				break
+			}
+			res = append(res, f)
+			total -= f.meta.Size
+		}
+		return res
+	}
+
+	if s.opts.FIFO.TTL <= 0 {
+		return nil
+	}
+	expiration := time.Now().Add(-s.opts.FIFO.TTL).Unix()
+	for _, f := range files {
+		// 更新的文件中的数据写入时间更晚, 遇到未过期的文件即可停止.
+		if f.meta.CreationTime == 0 || f.meta.CreationTime >= expiration {
+			break
+		}
+		res = append(res, f)
+	}
+
+	return res
+}
+
+// pickFIFOCompaction 返回删除最旧的若干文件的压缩, 不重写任何数据.
+func (s *VersionSet) pickFIFOCompaction() *Compaction {
+	c := &Compaction{version: s.current, deletionOnly: true}
+	for _, f := range s.pickFIFOFiles() {
+		if n := len(c.inputs); n > 0 && c.inputs[n-1].level == f.level {
+			c.inputs[n-1].files = append(c.inputs[n-1].files, f.meta)
+			continue
+		}
+		c.inputs = append(c.inputs, compactionInput{level: f.level, files: []*FileMetaData{f.meta}})
+	}
+
+	return c
+}
@@ -0,0 +1,141 @@
+package version
+
+import (
+	"time"
+
+	"github.com/goleveldb/goleveldb/comparator"
+)
+
+const (
+	// defaultL0CompactionTrigger 默认触发第 0 层压缩的文件数.
+	defaultL0CompactionTrigger = 4
+	// defaultMaxBytesForLevelBase 默认第 1 层的大小上限.
+	defaultMaxBytesForLevelBase = 10 * 1024 * 1024
+	// defaultMaxBytesForLevelMultiplier 默认相邻两层大小上限的倍数.
+	defaultMaxBytesForLevelMultiplier = 10
+	// defaultTargetFileSize 默认压缩输出的 table 文件大小.
+	defaultTargetFileSize = 2 * 1024 * 1024
+	// defaultUniversalSizeRatio 默认合并相邻 sorted run 时允许的大小差异百分比.
+	defaultUniversalSizeRatio = 1
+	// defaultUniversalMinMergeWidth 默认一次至少合并的 sorted run 数.
+	defaultUniversalMinMergeWidth = 2
+	// defaultUniversalMaxSizeAmplificationPercent 默认的空间放大上限百分比.
+	defaultUniversalMaxSizeAmplificationPercent = 200
+	// defaultFIFOMaxTableFilesSize 默认 FIFO 压缩时所有 table 文件的大小上限.
+	defaultFIFOMaxTableFilesSize = 1024 * 1024 * 1024
+)
+
+// CompactionStyle 压缩策略.
+type CompactionStyle int
+
+const (
+	// CompactionStyleLeveled 分层压缩: 每一层的大小是上一层的若干倍, 超过上限的层与下一层合并.
+	// 读放大与空间放大较小, 写放大较大.
+	CompactionStyleLeveled CompactionStyle = iota
+	// CompactionStyleUniversal 分级(size-tiered)压缩: 将大小相近的 sorted run 合并为一个,
+	// 第 0 层的每个文件, 以及其余每个非空的层, 各是一个 sorted run. 写放大较小, 读放大与空间放大较大.
+	CompactionStyleUniversal
+	// CompactionStyleFIFO 先进先出: 所有 table 都留在第 0 层, 从不重写数据,
+	// 总大小超过上限或数据过期时直接删除最旧的 table. 适用于只需保留最近数据的场景.
+	CompactionStyleFIFO
+)
+
+// UniversalOptions 控制 CompactionStyleUniversal 的行为.
+type UniversalOptions struct {
+	// SizeRatio 从较新的 sorted run 开始, 已选择的 sorted run 的总大小乘以 (100 + SizeRatio)%
+	// 不小于下一个 sorted run 的大小时, 将其一起合并. 为 0 时使用默认值 1.
+	SizeRatio int
+	// MinMergeWidth 一次至少合并的 sorted run 数, 小于 2 时使用默认值 2.
+	MinMergeWidth int
+	// MaxSizeAmplificationPercent 除最旧的 sorted run 以外的数据大小超过最旧的 sorted run 的该百分比时,
+	// 合并所有 sorted run 以回收被覆盖的数据占用的空间. 为 0 时使用默认值 200.
+	MaxSizeAmplificationPercent int
+}
+
+// FIFOOptions 控制 CompactionStyleFIFO 的行为.
+type FIFOOptions struct {
+	// MaxTableFilesSize 所有 table 文件的大小上限(字节), 超过后删除最旧的 table, 为 0 时使用默认值 1GB.
+	MaxTableFilesSize uint64
+	// TTL 大于 0 时, 删除数据写入时间早于 TTL 之前的 table. 创建时间未知的 table 不会因过期被删除.
+	// 使用 CompactionStyleFIFO 时, 无论是否配置 TTL, 每个 table 的创建时间都记录在 MANIFEST 中,
+	// 之后再配置 TTL 时已有的 table 同样会过期; 此时 MANIFEST 使用了 LevelDB 无法识别的标记,
+	// 不能再由 LevelDB 打开.
+	TTL time.Duration
+}
+
+// Options 控制 VersionSet 的行为.
+type Options struct {
+	// Comparator 定义 user key 的顺序, 为 nil 时使用 comparator.Bytewise.
+	// 文件的 key 范围与压缩指针均为 internal key, 见 ikey.InternalKey.
+	Comparator comparator.Comparator
+	// L0CompactionTrigger 第 0 层的文件数达到该值时触发压缩, 为 0 时使用默认值 4.
+	L0CompactionTrigger int
+	// MaxBytesForLevelBase 第 1 层的大小上限(字节), 为 0 时使用默认值 10MB.
+	MaxBytesForLevelBase uint64
+	// MaxBytesForLevelMultiplier 每一层的大小上限是上一层的倍数, 为 0 时使用默认值 10.
+	MaxBytesForLevelMultiplier int
+	// TargetFileSize 压缩输出的 table 文件大小(字节), 输出超过该大小后切换到新的文件, 为 0 时使用默认值 2MB.
+	TargetFileSize uint64
+	// CompactionStyle 压缩策略, 默认为 CompactionStyleLeveled.
+	// 使用 CompactionStyleUniversal 时, sorted run 的数量达到 L0CompactionTrigger 时开始压缩.
+	CompactionStyle CompactionStyle
+	// Universal 使用 CompactionStyleUniversal 时的配置.
+	Universal UniversalOptions
+	// FIFO 使用 CompactionStyleFIFO 时的配置.
+	FIFO FIFOOptions
+}
+
+// sanitize 返回填充了默认值的 Options 副本.
+func (o *Options) sanitize() *Options {
+	res := Options{}
+	if o != nil {
+		res = *o
+	}
+
+	if res.Comparator == nil {
+		res.Comparator = comparator.Bytewise
+	}
+	if res.L0CompactionTrigger <= 0 {
+		res.L0CompactionTrigger = defaultL0CompactionTrigger
+	}
+	if res.MaxBytesForLevelBase == 0 {
+		res.MaxBytesForLevelBase = defaultMaxBytesForLevelBase
+	}
+	if res.MaxBytesForLevelMultiplier <= 0 {
+		res.MaxBytesForLevelMultiplier = defaultMaxBytesForLevelMultiplier
+	}
+	if res.TargetFileSize == 0 {
+		res.TargetFileSize = defaultTargetFileSize
+	}
+	if res.Universal.SizeRatio <= 0 {
+		res.Universal.SizeRatio = defaultUniversalSizeRatio
+	}
+	if res.Universal.MinMergeWidth < 2 {
+		res.Universal.MinMergeWidth = defaultUniversalMinMergeWidth
+	}
+	if res.Universal.MaxSizeAmplificationPercent <= 0 {
+		res.Universal.MaxSizeAmplificationPercent = defaultUniversalMaxSizeAmplificationPercent
+	}
+	if res.FIFO.MaxTableFilesSize == 0 {
+		res.FIFO.MaxTableFilesSize = defaultFIFOMaxTableFilesSize
+	}
+
+	return &res
+}
+
+// recordsCreationTime 返回 MANIFEST 中是否记录 table 的创建时间.
+// 只有 FIFO TTL 需要使用创建时间, 但 TTL 可能在 table 创建之后才配置,
+// 因此使用 CompactionStyleFIFO 时总是记录.
+func (o *Options) recordsCreationTime() bool {
+	return o.CompactionStyle == CompactionStyleFIFO
+}
+
+// maxBytesForLevel 返回 level 层(level >= 1)的大小上限.
+func (o *Options) maxBytesForLevel(level int) float64 {
+	res := float64(o.MaxBytesForLevelBase)
+	for ; level > 1; level-- {
+		res *= float64(o.MaxBytesForLevelMultiplier)
+	}
+
+	return res
+}
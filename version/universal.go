@@ -0,0 +1,101 @@
+package version
+
+// sortedRun 一组 key 范围互不重叠的文件, 可以看作一个有序的整体.
+type sortedRun struct {
+	level int
+	files []*FileMetaData
+	size  uint64
+}
+
+// sortedRuns 按数据从新到旧返回 v 中的 sorted run: 第 0 层的每个文件各是一个 sorted run,
+// 其余每个非空的层是一个 sorted run, 层数越大数据越旧.
+func sortedRuns(v *Version) []sortedRun {
+	var runs []sortedRun
+	for _, f := range v.files[0] {
+		runs = append(runs, sortedRun{level: 0, files: []*FileMetaData{f}, size: f.Size})
+	}
+	for level := 1; level < NumLevels; level++ {
+		if len(v.files[level]) > 0 {
+			runs = append(runs, sortedRun{level: level, files: v.files[level], size: totalFileSize(v.files[level])})
+		}
+	}
+
+	return runs
+}
+
+// pickUniversalCompaction 选择相邻的若干 sorted run 合并为一个:
+//   - 空间放大超过上限时合并所有 sorted run;
+//   - 否则从最新的 sorted run 开始, 合并大小相近的 sorted run;
+//   - 否则合并最新的若干 sorted run, 使 sorted run 的数量低于 L0CompactionTrigger.
+func (s *VersionSet) pickUniversalCompaction() *Compaction {
+	runs := sortedRuns(s.current)
+	if len(runs) < 2 {
+		return nil
+	}
+
+	start, end := 0, len(runs)
+	if !s.exceedsSpaceAmplification(runs) {
+		var ok bool
+		if start, end, ok = s.pickSimilarSizeRuns(runs); !ok {
+			start, end = 0, len(runs)-s.opts.L0CompactionTrigger+1
+			if end < s.opts.Universal.MinMergeWidth {
+				end = s.opts.Universal.MinMergeWidth
+			}
+			if end > len(runs) {
+				end = len(runs)
+			}
+		}
+	}
+
+	// 输出写入下一个更旧的 sorted run 之上的空闲层, 保证层数越大数据越旧;
+	// 没有空闲的层时, 将下一个更旧的 sorted run 也加入合并.
+	for end < len(runs) && runs[end].level <= 1 {
+		end++
+	}
+	outputLevel := NumLevels - 1
+	if end < len(runs) {
+		outputLevel = runs[end].level - 1
+	}
+
+	c := &Compaction{version: s.current, outputLevel: outputLevel, maxOutputFileSize: s.opts.TargetFileSize}
+	for _, run := range runs[start:end] {
+		// 第 0 层相邻的文件合并为同一个输入, 保持从新到旧的顺序.
+		if n := len(c.inputs); n > 0 && run.level == 0 && c.inputs[n-1].level == 0 {
+			c.inputs[n-1].files = append(c.inputs[n-1].files, run.files...)
+			continue
+		}
+		c.inputs = append(c.inputs, compactionInput{level: run.level, files: run.files})
+	}
+
+	return c
+}
+
+// exceedsSpaceAmplification 返回除最旧的 sorted run 以外的数据大小,
+// 是否超过最旧的 sorted run 的 MaxSizeAmplificationPercent%.
+func (s *VersionSet) exceedsSpaceAmplification(runs []sortedRun) bool {
+	var newer uint64
+	for _, run := range runs[:len(runs)-1] {
+		newer += run.size
+	}
+
+	return newer*100 > runs[len(runs)-1].size*uint64(s.opts.Universal.MaxSizeAmplificationPercent)
+}
+
+// pickSimilarSizeRuns 从最新的 sorted run 开始, 选择第一组至少 MinMergeWidth 个大小相近的相邻 sorted run,
+// 返回其范围 [start, end).
+func (s *VersionSet) pickSimilarSizeRuns(runs []sortedRun) (start, end int, ok bool) {
+	for start = 0; start < len(runs); start++ {
+		size := runs[start].size
+		for end = start + 1; end < len(runs); end++ {
+			if size*uint64(100+s.opts.Universal.SizeRatio)/100 < runs[end].size {
+				break
+			}
+			size += runs[end].size
+		}
+		if end-start >= s.opts.Universal.MinMergeWidth {
+			return start, end, true
+		}
+	}
+
+	return 0, 0, false
+}
@@ -0,0 +1,202 @@
+package version
+
+import (
+	"sort"
+
+	"github.com/goleveldb/goleveldb/ikey"
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+// NumLevels table 文件的层数.
+const NumLevels = 7
+
+// Version 描述某一时刻各层包含的 table 文件, 创建后不再修改.
+// 第 0 层的文件之间 key 范围可能重叠, 按文件编号从新到旧排列;
+// 其余各层的文件之间 user key 范围不重叠, 按最小 key 从小到大排列.
+type Version struct {
+	icmp  *ikey.Comparator
+	files [NumLevels][]*FileMetaData
+
+	// 最需要压缩的层及其分数, 分数不小于 1 时需要压缩.
+	compactionLevel int
+	compactionScore float64
+}
+
+// Files 返回 level 层的文件, 调用者不应修改返回的结果.
+func (v *Version) Files(level int) []*FileMetaData {
+	return v.files[level]
+}
+
+// NumFiles 返回 level 层的文件数.
+func (v *Version) NumFiles(level int) int {
+	return len(v.files[level])
+}
+
+// NumLevelBytes 返回 level 层所有文件的总大小.
+func (v *Version) NumLevelBytes(level int) uint64 {
+	return totalFileSize(v.files[level])
+}
+
+// AddLiveFiles 将 Version 中所有文件的编号加入 live.
+func (v *Version) AddLiveFiles(live map[uint64]struct{}) {
+	for _, files := range v.files {
+		for _, f := range files {
+			live[f.Number] = struct{}{}
+		}
+	}
+}
+
+// FilesForKey 按查找顺序返回 user key 范围包含 userKey 的文件:
+// 先是第 0 层的文件(从新到旧), 然后是其余各层中至多一个文件(从上到下).
+func (v *Version) FilesForKey(userKey slice.Slice) []*FileMetaData {
+	ucmp := v.icmp.UserComparator()
+	var res []*FileMetaData
+	for _, f := range v.files[0] {
+		if ucmp.Compare(userKey, smallestUserKey(f)) >= 0 && ucmp.Compare(userKey, largestUserKey(f)) <= 0 {
+			res = append(res, f)
+		}
+	}
+
+	for level := 1; level < NumLevels; level++ {
+		files := v.files[level]
+		i := sort.Search(len(files), func(i int) bool { return ucmp.Compare(largestUserKey(files[i]), userKey) >= 0 })
+		if i < len(files) && ucmp.Compare(userKey, smallestUserKey(files[i])) >= 0 {
+			res = append(res, files[i])
+		}
+	}
+
+	return res
+}
+
+// OverlappingInputs 返回 level 层中 user key 范围与 [begin, end] 重叠的文件, begin 或 end 为 nil 时表示不限.
+// 第 0 层的文件之间可能重叠, 返回的文件所覆盖的范围会被扩展, 直到不再与其余文件重叠.
+func (v *Version) OverlappingInputs(level int, begin, end slice.Slice) []*FileMetaData {
+	ucmp := v.icmp.UserComparator()
+	var res []*FileMetaData
+	files := v.files[level]
+	for i := 0; i < len(files); {
+		f := files[i]
+		i++
+		fileBegin, fileEnd := smallestUserKey(f), largestUserKey(f)
+		if begin != nil && ucmp.Compare(fileEnd, begin) < 0 || end != nil && ucmp.Compare(fileBegin, end) > 0 {
+			continue
+		}
+		res = append(res, f)
+
+		if level != 0 {
+			continue
+		}
+		if begin != nil && ucmp.Compare(fileBegin, begin) < 0 {
+			begin = fileBegin
+			res, i = nil, 0
+		} else if end != nil && ucmp.Compare(fileEnd, end) > 0 {
+			end = fileEnd
+			res, i = nil, 0
+		}
+	}
+
+	return res
+}
+
+// IsBaseLevelForKey 返回 level 之下的各层中是否都没有 user key 范围包含 userKey 的文件,
+// 此时 userKey 在 level 层及之上的删除标记不再需要保留.
+func (v *Version) IsBaseLevelForKey(level int, userKey slice.Slice) bool {
+	for l := level + 1; l < NumLevels; l++ {
+		if len(v.OverlappingInputs(l, userKey, userKey)) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// keyRange 返回 files 的最小 internal key 与最大 internal key.
+func (v *Version) keyRange(files []*FileMetaData) (smallest, largest slice.Slice) {
+	for i, f := range files {
+		if i == 0 || v.icmp.Compare(f.Smallest, smallest) < 0 {
+			smallest = f.Smallest
+		}
+		if i == 0 || v.icmp.Compare(f.Largest, largest) > 0 {
+			largest = f.Largest
+		}
+	}
+
+	return smallest, largest
+}
+
+// smallestUserKey 返回文件中最小的 user key.
+func smallestUserKey(f *FileMetaData) slice.Slice {
+	return ikey.InternalKey(f.Smallest).UserKey()
+}
+
+// largestUserKey 返回文件中最大的 user key.
+func largestUserKey(f *FileMetaData) slice.Slice {
+	return ikey.InternalKey(f.Largest).UserKey()
+}
+
+func totalFileSize(files []*FileMetaData) uint64 {
+	var res uint64
+	for _, f := range files {
+		res += f.Size
+	}
+
+	return res
+}
+
+// builder 将一系列 VersionEdit 应用到 base 上, 生成新的 Version.
+type builder struct {
+	icmp    *ikey.Comparator
+	base    *Version
+	deleted [NumLevels]map[uint64]struct{}
+	added   [NumLevels][]*FileMetaData
+}
+
+func newBuilder(icmp *ikey.Comparator, base *Version) *builder {
+	b := &builder{icmp: icmp, base: base}
+	for level := range b.deleted {
+		b.deleted[level] = make(map[uint64]struct{})
+	}
+
+	return b
+}
+
+// apply 记录 edit 中删除与添加的文件.
+func (b *builder) apply(edit *VersionEdit) {
+	for _, f := range edit.deletedFiles {
+		b.deleted[f.level][f.number] = struct{}{}
+	}
+
+	for _, f := range edit.newFiles {
+		delete(b.deleted[f.level], f.meta.Number)
+		b.added[f.level] = append(b.added[f.level], f.meta)
+	}
+}
+
+// build 生成新的 Version.
+func (b *builder) build() *Version {
+	v := &Version{icmp: b.icmp}
+	for level := 0; level < NumLevels; level++ {
+		var files []*FileMetaData
+		for _, group := range [][]*FileMetaData{b.base.files[level], b.added[level]} {
+			for _, f := range group {
+				if _, ok := b.deleted[level][f.Number]; !ok {
+					files = append(files, f)
+				}
+			}
+		}
+
+		if level == 0 {
+			sort.Slice(files, func(i, j int) bool { return files[i].Number > files[j].Number })
+		} else {
+			sort.Slice(files, func(i, j int) bool {
+				if c := b.icmp.Compare(files[i].Smallest, files[j].Smallest); c != 0 {
+					return c < 0
+				}
+				return files[i].Number < files[j].Number
+			})
+		}
+		v.files[level] = files
+	}
+
+	return v
+}
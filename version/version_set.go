@@ -0,0 +1,371 @@
+package version
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/goleveldb/goleveldb/file"
+	"github.com/goleveldb/goleveldb/ikey"
+	"github.com/goleveldb/goleveldb/log"
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+const (
+	// CurrentFile 记录当前 MANIFEST 文件名的文件.
+	CurrentFile = "CURRENT"
+	// ManifestFilePrefix MANIFEST 文件名的前缀, 其后为文件编号.
+	ManifestFilePrefix = "MANIFEST-"
+	// TempFileSuffix 写入 CURRENT 时使用的临时文件后缀.
+	TempFileSuffix = ".dbtmp"
+)
+
+var (
+	// ErrCorruptedManifest MANIFEST 或 CURRENT 文件损坏.
+	ErrCorruptedManifest = errors.New("corrupted manifest")
+	// ErrComparatorMismatch DB 创建时使用的比较器与当前比较器不一致.
+	ErrComparatorMismatch = errors.New("comparator does not match the one the db is created with")
+)
+
+// CurrentFileName 返回 CURRENT 文件路径.
+func CurrentFileName(dir string) string {
+	return filepath.Join(dir, CurrentFile)
+}
+
+// ManifestFileName 返回编号为 number 的 MANIFEST 文件路径.
+func ManifestFileName(dir string, number uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%s%06d", ManifestFilePrefix, number))
+}
+
+// tempFileName 返回编号为 number 的临时文件路径.
+func tempFileName(dir string, number uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%06d%s", number, TempFileSuffix))
+}
+
+// VersionSet 维护 DB 的当前 Version 与文件编号、序列号等元数据, 所有修改都先记录到 MANIFEST 中.
+// VersionSet 不是并发安全的, 由调用者加锁保护.
+type VersionSet struct {
+	dir     string
+	opts    *Options
+	icmp    *ikey.Comparator
+	current *Version
+
+	// 各层上一次压缩的最大 internal key, 下一次压缩从该 key 之后的文件开始.
+	compactPointers [NumLevels]slice.Slice
+
+	nextFileNumber uint64
+	manifestNumber uint64
+	logNumber      uint64
+	lastSequence   uint64
+
+	// 当前的 MANIFEST, 为 nil 时下一次 LogAndApply 会创建新的 MANIFEST.
+	manifestFile file.Writer
+	manifest     log.Writer
+
+	// 不加锁读取的 Version 及其引用计数, 其中的文件在释放前不会被删除.
+	refs map[*Version]int
+}
+
+// NewVersionSet 创建 dir 目录下 DB 的 VersionSet, 此时不包含任何文件.
+// 已有的 DB 需要调用 Recover 从 MANIFEST 中恢复.
+func NewVersionSet(dir string, opts *Options) *VersionSet {
+	opts = opts.sanitize()
+	icmp := ikey.NewComparator(opts.Comparator)
+	s := &VersionSet{
+		dir:            dir,
+		opts:           opts,
+		icmp:           icmp,
+		current:        &Version{icmp: icmp},
+		nextFileNumber: 1,
+		refs:           make(map[*Version]int),
+	}
+	s.finalize(s.current)
+
+	return s
+}
+
+// Current 返回当前 Version.
+func (s *VersionSet) Current() *Version {
+	return s.current
+}
+
+// Ref 增加 v 的引用计数, 在 Unref 之前 v 中的文件都被视为仍在使用, 见 AddLiveFiles.
+func (s *VersionSet) Ref(v *Version) {
+	s.refs[v]++
+}
+
+// Unref 减少 v 的引用计数, 每次 Ref 都需要对应一次 Unref.
+func (s *VersionSet) Unref(v *Version) {
+	if s.refs[v]--; s.refs[v] <= 0 {
+		delete(s.refs, v)
+	}
+}
+
+// AddLiveFiles 将当前 Version 与所有被引用的 Version 中的文件编号加入 live, 这些文件不能被删除.
+func (s *VersionSet) AddLiveFiles(live map[uint64]struct{}) {
+	s.current.AddLiveFiles(live)
+	for v := range s.refs {
+		v.AddLiveFiles(live)
+	}
+}
+
+// NewFileNumber 分配一个新的文件编号.
+func (s *VersionSet) NewFileNumber() uint64 {
+	number := s.nextFileNumber
+	s.nextFileNumber++
+
+	return number
+}
+
+// MarkFileNumberUsed 保证之后分配的文件编号大于 number.
+func (s *VersionSet) MarkFileNumberUsed(number uint64) {
+	if s.nextFileNumber <= number {
+		s.nextFileNumber = number + 1
+	}
+}
+
+// LogNumber 返回需要重放的最小日志编号, 编号更小的日志内容均已写入 table.
+func (s *VersionSet) LogNumber() uint64 {
+	return s.logNumber
+}
+
+// ManifestFileNumber 返回当前 MANIFEST 的文件编号.
+func (s *VersionSet) ManifestFileNumber() uint64 {
+	return s.manifestNumber
+}
+
+// LastSequence 返回已使用的最大序列号.
+func (s *VersionSet) LastSequence() uint64 {
+	return s.lastSequence
+}
+
+// SetLastSequence 设置已使用的最大序列号.
+func (s *VersionSet) SetLastSequence(seq uint64) {
+	s.lastSequence = seq
+}
+
+// LogAndApply 将 edit 应用到当前 Version 生成新的 Version, edit 写入 MANIFEST 并同步到磁盘后新的 Version 才会生效.
+// edit 中未设置的日志编号使用当前值, 文件编号与序列号总是使用当前值.
+func (s *VersionSet) LogAndApply(edit *VersionEdit) error {
+	if !edit.hasLogNumber {
+		edit.SetLogNumber(s.logNumber)
+	}
+	if edit.logNumber < s.logNumber || edit.logNumber >= s.nextFileNumber {
+		return fmt.Errorf("invalid log number %d", edit.logNumber)
+	}
+
+	b := newBuilder(s.icmp, s.current)
+	b.apply(edit)
+	v := b.build()
+	s.finalize(v)
+
+	var err error
+	createdManifest := s.manifest == nil
+	if createdManifest {
+		err = s.createManifest()
+	}
+	if err == nil {
+		edit.SetNextFileNumber(s.nextFileNumber)
+		edit.SetLastSequence(s.lastSequence)
+		err = s.manifest.AddRecord(edit.encode(s.opts.recordsCreationTime()))
+	}
+	if err == nil {
+		err = s.manifestFile.Sync()
+	}
+	if err == nil && createdManifest {
+		err = setCurrentFile(s.dir, s.manifestNumber)
+	}
+	if err != nil {
+		// 写入失败后 MANIFEST 的内容无法确定, 下一次 LogAndApply 时创建新的 MANIFEST.
+		s.closeManifest()
+		if createdManifest {
+			os.Remove(ManifestFileName(s.dir, s.manifestNumber))
+		}
+		return err
+	}
+
+	s.current = v
+	s.logNumber = edit.logNumber
+	s.applyCompactPointers(edit)
+
+	return nil
+}
+
+// createManifest 创建新的 MANIFEST, 并写入当前 Version 的快照.
+func (s *VersionSet) createManifest() error {
+	s.manifestNumber = s.NewFileNumber()
+	manifestFile, err := file.NewWriter(ManifestFileName(s.dir, s.manifestNumber))
+	if err != nil {
+		return err
+	}
+	s.manifestFile = manifestFile
+	s.manifest = log.NewWriter(manifestFile, 0)
+
+	snapshot := &VersionEdit{}
+	snapshot.SetComparatorName(s.opts.Comparator.Name())
+	for level, key := range s.compactPointers {
+		if key != nil {
+			snapshot.SetCompactPointer(level, key)
+		}
+	}
+	for level, files := range s.current.files {
+		for _, f := range files {
+			snapshot.AddFile(level, f)
+		}
+	}
+
+	return s.manifest.AddRecord(snapshot.encode(s.opts.recordsCreationTime()))
+}
+
+func (s *VersionSet) closeManifest() {
+	if s.manifestFile != nil {
+		s.manifestFile.Close()
+	}
+	s.manifestFile = nil
+	s.manifest = nil
+}
+
+// setCurrentFile 将 CURRENT 指向编号为 number 的 MANIFEST, 先写入临时文件再重命名, 保证 CURRENT 总是完整的.
+func setCurrentFile(dir string, number uint64) error {
+	tempName := tempFileName(dir, number)
+	content := filepath.Base(ManifestFileName(dir, number)) + "\n"
+	tempFile, err := file.NewWriter(tempName)
+	if err != nil {
+		return err
+	}
+
+	err = tempFile.Append(slice.Slice(content))
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempName, CurrentFileName(dir))
+	}
+	if err != nil {
+		os.Remove(tempName)
+	}
+
+	return err
+}
+
+// manifestReporter 将读取 MANIFEST 时发现的第一个损坏记录为错误.
+type manifestReporter struct {
+	err error
+}
+
+func (r *manifestReporter) Corruption(_ int, err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// Recover 读取 CURRENT 指向的 MANIFEST, 依次应用其中的 VersionEdit 恢复 Version 与元数据.
+// 恢复后的 VersionSet 不会继续写入原有的 MANIFEST, 下一次 LogAndApply 时会创建新的 MANIFEST.
+func (s *VersionSet) Recover() error {
+	current, err := ioutil.ReadFile(CurrentFileName(s.dir))
+	if err != nil {
+		return err
+	}
+	name := string(current)
+	if !strings.HasSuffix(name, "\n") || !strings.HasPrefix(name, ManifestFilePrefix) {
+		return fmt.Errorf("%w: bad CURRENT content %q", ErrCorruptedManifest, name)
+	}
+	name = strings.TrimSuffix(name, "\n")
+	manifestNumber, err := strconv.ParseUint(strings.TrimPrefix(name, ManifestFilePrefix), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: bad CURRENT content %q", ErrCorruptedManifest, name)
+	}
+
+	reader, err := file.NewSequentialReader(filepath.Join(s.dir, name))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	var (
+		reporter  = &manifestReporter{}
+		logReader = log.NewReader(reader, reporter, &log.ReaderOptions{Strict: true})
+		b         = newBuilder(s.icmp, s.current)
+		state     VersionEdit // 记录各字段的最新值
+	)
+	for {
+		record, err := logReader.ReadRecord()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w: %v", name, ErrCorruptedManifest, err)
+		}
+
+		edit := &VersionEdit{}
+		if err := edit.Decode(record); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if edit.hasComparator && edit.comparator != s.opts.Comparator.Name() {
+			return fmt.Errorf("%w: %s, given %s", ErrComparatorMismatch, edit.comparator, s.opts.Comparator.Name())
+		}
+
+		b.apply(edit)
+		s.applyCompactPointers(edit)
+		if edit.hasLogNumber {
+			state.SetLogNumber(edit.logNumber)
+		}
+		if edit.hasNextFileNumber {
+			state.SetNextFileNumber(edit.nextFileNumber)
+		}
+		if edit.hasLastSequence {
+			state.SetLastSequence(edit.lastSequence)
+		}
+	}
+	if reporter.err != nil {
+		return fmt.Errorf("%s: %w: %v", name, ErrCorruptedManifest, reporter.err)
+	}
+
+	switch {
+	case !state.hasNextFileNumber:
+		return fmt.Errorf("%s: %w: no next file number", name, ErrCorruptedManifest)
+	case !state.hasLogNumber:
+		return fmt.Errorf("%s: %w: no log number", name, ErrCorruptedManifest)
+	case !state.hasLastSequence:
+		return fmt.Errorf("%s: %w: no last sequence", name, ErrCorruptedManifest)
+	}
+
+	s.current = b.build()
+	s.finalize(s.current)
+	s.manifestNumber = manifestNumber
+	s.nextFileNumber = state.nextFileNumber
+	s.logNumber = state.logNumber
+	s.lastSequence = state.lastSequence
+	s.MarkFileNumberUsed(manifestNumber)
+	s.MarkFileNumberUsed(state.logNumber)
+
+	return nil
+}
+
+// applyCompactPointers 记录 edit 中各层压缩的结束位置.
+func (s *VersionSet) applyCompactPointers(edit *VersionEdit) {
+	for _, p := range edit.compactPointers {
+		s.compactPointers[p.level] = p.key
+	}
+}
+
+// Close 关闭 MANIFEST 文件.
+func (s *VersionSet) Close() error {
+	if s.manifestFile == nil {
+		return nil
+	}
+
+	err := s.manifestFile.Close()
+	s.manifestFile = nil
+	s.manifest = nil
+
+	return err
+}
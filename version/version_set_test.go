@@ -0,0 +1,467 @@
+package version
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/goleveldb/goleveldb/comparator"
+	"github.com/goleveldb/goleveldb/file"
+	"github.com/goleveldb/goleveldb/ikey"
+	"github.com/goleveldb/goleveldb/log"
+	"github.com/goleveldb/goleveldb/slice"
+)
+
+// testFile 返回 user key 范围为 [smallest, largest] 的文件, 文件中的数据使用 number 作为序列号.
+func testFile(number uint64, smallest, largest string) *FileMetaData {
+	return &FileMetaData{
+		Number:   number,
+		Size:     number * 100,
+		Smallest: slice.Slice(ikey.MakeInternalKey(slice.Slice(smallest), number, ikey.TypeValue)),
+		Largest:  slice.Slice(ikey.MakeInternalKey(slice.Slice(largest), number, ikey.TypeValue)),
+	}
+}
+
+func fileNumbers(files []*FileMetaData) []uint64 {
+	var res []uint64
+	for _, f := range files {
+		res = append(res, f.Number)
+	}
+
+	return res
+}
+
+func assertFiles(t *testing.T, v *Version, level int, want ...uint64) {
+	t.Helper()
+
+	got := fileNumbers(v.Files(level))
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("level %d files = %v, want %v", level, got, want)
+	}
+}
+
+func TestVersionSet_LogAndApply(t *testing.T) {
+	s := NewVersionSet(t.TempDir(), nil)
+	for i := 0; i < 10; i++ {
+		s.NewFileNumber()
+	}
+
+	edit := &VersionEdit{}
+	edit.AddFile(0, testFile(1, "a", "m"))
+	edit.AddFile(0, testFile(3, "c", "z"))
+	edit.AddFile(0, testFile(2, "b", "d"))
+	edit.AddFile(1, testFile(5, "n", "p"))
+	edit.AddFile(1, testFile(4, "a", "f"))
+	if err := s.LogAndApply(edit); err != nil {
+		t.Fatal(err)
+	}
+	v1 := s.Current()
+	assertFiles(t, v1, 0, 3, 2, 1)
+	assertFiles(t, v1, 1, 4, 5)
+
+	edit = &VersionEdit{}
+	edit.DeleteFile(0, 2)
+	edit.DeleteFile(1, 4)
+	edit.AddFile(2, testFile(6, "a", "f"))
+	edit.SetLogNumber(8)
+	if err := s.LogAndApply(edit); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, s.Current(), 0, 3, 1)
+	assertFiles(t, s.Current(), 1, 5)
+	assertFiles(t, s.Current(), 2, 6)
+	if s.LogNumber() != 8 {
+		t.Errorf("LogNumber() = %d, want 8", s.LogNumber())
+	}
+
+	// 已有的 Version 不受影响.
+	assertFiles(t, v1, 0, 3, 2, 1)
+
+	live := make(map[uint64]struct{})
+	s.Current().AddLiveFiles(live)
+	if len(live) != 4 {
+		t.Errorf("live files = %v, want 4 files", live)
+	}
+
+	// 日志编号不能回退.
+	edit = &VersionEdit{}
+	edit.SetLogNumber(7)
+	if err := s.LogAndApply(edit); err == nil {
+		t.Error("LogAndApply() with smaller log number => want err, get nil")
+	}
+}
+
+func TestVersionSet_RefLiveFiles(t *testing.T) {
+	s := NewVersionSet(t.TempDir(), nil)
+	for i := 0; i < 10; i++ {
+		s.NewFileNumber()
+	}
+	edit := &VersionEdit{}
+	edit.AddFile(0, testFile(1, "a", "c"))
+	edit.AddFile(0, testFile(2, "d", "f"))
+	if err := s.LogAndApply(edit); err != nil {
+		t.Fatal(err)
+	}
+	v1 := s.Current()
+	s.Ref(v1)
+	s.Ref(v1)
+
+	edit = &VersionEdit{}
+	edit.DeleteFile(0, 1)
+	edit.AddFile(1, testFile(3, "a", "c"))
+	if err := s.LogAndApply(edit); err != nil {
+		t.Fatal(err)
+	}
+
+	liveFiles := func() string {
+		live := make(map[uint64]struct{})
+		s.AddLiveFiles(live)
+		return fmt.Sprint(len(live), live)
+	}
+	// 被引用的旧 Version 中的文件仍被视为在使用, 所有引用释放后才可以删除.
+	want := fmt.Sprint(3, map[uint64]struct{}{1: {}, 2: {}, 3: {}})
+	if got := liveFiles(); got != want {
+		t.Errorf("live files = %s, want %s", got, want)
+	}
+	s.Unref(v1)
+	if got := liveFiles(); got != want {
+		t.Errorf("live files = %s, want %s", got, want)
+	}
+	s.Unref(v1)
+	want = fmt.Sprint(2, map[uint64]struct{}{2: {}, 3: {}})
+	if got := liveFiles(); got != want {
+		t.Errorf("live files after Unref = %s, want %s", got, want)
+	}
+}
+
+func TestVersionSet_Recover(t *testing.T) {
+	dir := t.TempDir()
+	s := NewVersionSet(dir, nil)
+	logNumber := s.NewFileNumber()
+	edit := &VersionEdit{}
+	edit.SetLogNumber(logNumber)
+	edit.AddFile(0, testFile(s.NewFileNumber(), "a", "c"))
+	s.SetLastSequence(10)
+	if err := s.LogAndApply(edit); err != nil {
+		t.Fatal(err)
+	}
+
+	edit = &VersionEdit{}
+	edit.AddFile(0, testFile(s.NewFileNumber(), "b", "d"))
+	edit.AddFile(1, testFile(s.NewFileNumber(), "e", "f"))
+	s.SetLastSequence(20)
+	if err := s.LogAndApply(edit); err != nil {
+		t.Fatal(err)
+	}
+	nextFileNumber := s.NewFileNumber()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	recovered := NewVersionSet(dir, nil)
+	if err := recovered.Recover(); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, recovered.Current(), 0, 4, 2)
+	assertFiles(t, recovered.Current(), 1, 5)
+	if f := recovered.Current().Files(1)[0]; string(smallestUserKey(f)) != "e" || string(largestUserKey(f)) != "f" || f.Size != 500 {
+		t.Errorf("recovered file = %+v", f)
+	}
+	if recovered.LogNumber() != logNumber || recovered.LastSequence() != 20 {
+		t.Errorf("LogNumber() = %d, LastSequence() = %d, want %d, 20", recovered.LogNumber(), recovered.LastSequence(), logNumber)
+	}
+	if recovered.ManifestFileNumber() != s.ManifestFileNumber() {
+		t.Errorf("ManifestFileNumber() = %d, want %d", recovered.ManifestFileNumber(), s.ManifestFileNumber())
+	}
+	// 未写入 MANIFEST 的文件编号可能已被使用, 恢复后不会重复分配.
+	if got := recovered.NewFileNumber(); got < nextFileNumber-1 {
+		t.Errorf("NewFileNumber() = %d, want >= %d", got, nextFileNumber-1)
+	}
+
+	// 恢复后的修改写入新的 MANIFEST, CURRENT 指向新的 MANIFEST.
+	edit = &VersionEdit{}
+	edit.DeleteFile(0, 2)
+	if err := recovered.LogAndApply(edit); err != nil {
+		t.Fatal(err)
+	}
+	if recovered.ManifestFileNumber() == s.ManifestFileNumber() {
+		t.Error("recovered version set should write a new manifest")
+	}
+	if err := recovered.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	again := NewVersionSet(dir, nil)
+	if err := again.Recover(); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, again.Current(), 0, 4)
+	assertFiles(t, again.Current(), 1, 5)
+}
+
+func TestVersionSet_RecoverCreationTime(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *Options
+		want int64
+	}{
+		// 不使用 FIFO 时 MANIFEST 与 LevelDB 兼容, 不记录创建时间.
+		{name: "leveled", opts: nil, want: 0},
+		{name: "fifo without ttl", opts: &Options{CompactionStyle: CompactionStyleFIFO}, want: 1600000000},
+		{name: "fifo with ttl", opts: &Options{CompactionStyle: CompactionStyleFIFO, FIFO: FIFOOptions{TTL: time.Hour}}, want: 1600000000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := NewVersionSet(dir, tt.opts)
+			f := testFile(s.NewFileNumber(), "a", "c")
+			f.CreationTime = 1600000000
+			edit := &VersionEdit{}
+			edit.AddFile(0, f)
+			if err := s.LogAndApply(edit); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			recovered := NewVersionSet(dir, tt.opts)
+			if err := recovered.Recover(); err != nil {
+				t.Fatal(err)
+			}
+			if got := recovered.Current().Files(0)[0].CreationTime; got != tt.want {
+				t.Errorf("recovered creation time = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionSet_FIFOTTLEnabledLater(t *testing.T) {
+	dir := t.TempDir()
+	s := NewVersionSet(dir, &Options{CompactionStyle: CompactionStyleFIFO})
+	old := testFile(s.NewFileNumber(), "a", "c")
+	old.CreationTime = time.Now().Add(-2 * time.Hour).Unix()
+	recent := testFile(s.NewFileNumber(), "a", "c")
+	recent.CreationTime = time.Now().Unix()
+	edit := &VersionEdit{}
+	edit.AddFile(0, old)
+	edit.AddFile(0, recent)
+	if err := s.LogAndApply(edit); err != nil {
+		t.Fatal(err)
+	}
+	if s.NeedsCompaction() {
+		t.Error("NeedsCompaction() = true without TTL")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 配置 TTL 之前创建的 table 同样会过期.
+	recovered := NewVersionSet(dir, &Options{CompactionStyle: CompactionStyleFIFO, FIFO: FIFOOptions{TTL: time.Hour}})
+	if err := recovered.Recover(); err != nil {
+		t.Fatal(err)
+	}
+	defer recovered.Close()
+	c := recovered.PickCompaction()
+	if c == nil || !c.IsDeletionCompaction() {
+		t.Fatalf("PickCompaction() = %+v, want deletion compaction", c)
+	}
+	if got := fileNumbers(c.Inputs(0)); fmt.Sprint(got) != fmt.Sprint([]uint64{old.Number}) {
+		t.Errorf("deleted files = %v, want [%d]", got, old.Number)
+	}
+}
+
+// goldenManifest 由 LevelDB 的 VersionEdit 编码生成, 依次包含 VersionSet::WriteSnapshot 写入的快照,
+// 一次刷盘与一次第 0 层的压缩, 后两条记录包含 LevelDB 的 prev log number 字段.
+const goldenManifest = "testdata/leveldb.manifest"
+
+func TestVersionSet_RecoverLevelDBManifest(t *testing.T) {
+	content, err := ioutil.ReadFile(goldenManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(ManifestFileName(dir, 4), content, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := setCurrentFile(dir, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewVersionSet(dir, nil)
+	if err := s.Recover(); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, s.Current(), 0)
+	assertFiles(t, s.Current(), 1, 11, 6)
+	if f := s.Current().Files(1)[0]; string(smallestUserKey(f)) != "a" || string(largestUserKey(f)) != "m" || f.Size != 3000 {
+		t.Errorf("recovered file = %+v", f)
+	}
+	if s.LogNumber() != 9 || s.LastSequence() != 60 {
+		t.Errorf("LogNumber() = %d, LastSequence() = %d, want 9, 60", s.LogNumber(), s.LastSequence())
+	}
+	if got := s.NewFileNumber(); got != 12 {
+		t.Errorf("NewFileNumber() = %d, want 12", got)
+	}
+	wantPointer := ikey.MakeInternalKey(slice.Slice("k"), 50, ikey.TypeDeletion)
+	if got := s.compactPointers[0]; string(got) != string(wantPointer) {
+		t.Errorf("level 0 compact pointer = %v, want %v", got, wantPointer)
+	}
+
+	// 快照记录与 LevelDB 的编码完全相同; prev log number 之外的字段重新编码后与 LevelDB 的编码相同,
+	// 因此 LevelDB 可以读取写入的 MANIFEST.
+	reader, err := file.NewSequentialReader(goldenManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	logReader := log.NewReader(reader, &manifestReporter{}, &log.ReaderOptions{Strict: true})
+	for i := 0; ; i++ {
+		record, err := logReader.ReadRecord()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		edit := &VersionEdit{}
+		if err := edit.Decode(record); err != nil {
+			t.Fatalf("record %d: Decode() => get err = %v", i, err)
+		}
+		want := bytes.Replace(record, []byte{tagPrevLogNumber, 0}, nil, 1)
+		if got := edit.Encode(); !bytes.Equal(got, want) {
+			t.Errorf("record %d: Encode() = %x, want %x", i, got, want)
+		}
+	}
+}
+
+func TestVersionSet_RecoverErrors(t *testing.T) {
+	newDB := func(t *testing.T) string {
+		dir := t.TempDir()
+		s := NewVersionSet(dir, nil)
+		if err := s.LogAndApply(&VersionEdit{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		return dir
+	}
+
+	t.Run("comparator mismatch", func(t *testing.T) {
+		dir := newDB(t)
+		err := NewVersionSet(dir, &Options{Comparator: comparator.Reverse(comparator.Bytewise)}).Recover()
+		if !errors.Is(err, ErrComparatorMismatch) {
+			t.Errorf("Recover() => want err = %v, get err = %v", ErrComparatorMismatch, err)
+		}
+	})
+
+	t.Run("missing current", func(t *testing.T) {
+		if err := NewVersionSet(t.TempDir(), nil).Recover(); err == nil {
+			t.Error("Recover() => want err, get nil")
+		}
+	})
+
+	t.Run("bad current", func(t *testing.T) {
+		dir := newDB(t)
+		if err := ioutil.WriteFile(CurrentFileName(dir), []byte("MANIFEST-000001"), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := NewVersionSet(dir, nil).Recover(); !errors.Is(err, ErrCorruptedManifest) {
+			t.Errorf("Recover() => want err = %v, get err = %v", ErrCorruptedManifest, err)
+		}
+	})
+
+	t.Run("corrupted manifest", func(t *testing.T) {
+		dir := newDB(t)
+		manifest := ManifestFileName(dir, 1)
+		content, err := ioutil.ReadFile(manifest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content[len(content)-1] ^= 0xff
+		if err := ioutil.WriteFile(manifest, content, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := NewVersionSet(dir, nil).Recover(); !errors.Is(err, ErrCorruptedManifest) {
+			t.Errorf("Recover() => want err = %v, get err = %v", ErrCorruptedManifest, err)
+		}
+	})
+}
+
+func TestVersion_FilesForKey(t *testing.T) {
+	s := NewVersionSet(t.TempDir(), nil)
+	for i := 0; i < 10; i++ {
+		s.NewFileNumber()
+	}
+	edit := &VersionEdit{}
+	edit.AddFile(0, testFile(1, "a", "m"))
+	edit.AddFile(0, testFile(2, "k", "z"))
+	edit.AddFile(1, testFile(3, "a", "c"))
+	edit.AddFile(1, testFile(4, "e", "g"))
+	edit.AddFile(2, testFile(5, "b", "f"))
+	if err := s.LogAndApply(edit); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key  string
+		want []uint64
+	}{
+		{key: "0"},
+		{key: "a", want: []uint64{1, 3}},
+		{key: "b", want: []uint64{1, 3, 5}},
+		{key: "d", want: []uint64{1, 5}},
+		{key: "f", want: []uint64{1, 4, 5}},
+		{key: "l", want: []uint64{2, 1}},
+		{key: "zz"},
+	}
+	for _, tt := range tests {
+		got := fileNumbers(s.Current().FilesForKey(slice.Slice(tt.key)))
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("FilesForKey(%s) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestVersionSet_LogAndApplyCreateManifestError(t *testing.T) {
+	dir := t.TempDir()
+	s := NewVersionSet(dir, nil)
+
+	// 第一个 MANIFEST 指向 /dev/full, 写入快照时失败.
+	failed := ManifestFileName(dir, 1)
+	if err := os.Symlink("/dev/full", failed); err != nil {
+		t.Skip(err)
+	}
+	edit := &VersionEdit{}
+	edit.AddFile(0, testFile(5, "a", "c"))
+	if err := s.LogAndApply(edit); err == nil {
+		t.Fatal("LogAndApply() => want err, get nil")
+	}
+	if _, err := os.Lstat(failed); !os.IsNotExist(err) {
+		t.Errorf("partial MANIFEST not removed, Lstat() err = %v", err)
+	}
+
+	// 下一次 LogAndApply 创建新的 MANIFEST, edit 在恢复后仍然可见.
+	edit = &VersionEdit{}
+	edit.AddFile(0, testFile(6, "d", "f"))
+	if err := s.LogAndApply(edit); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	again := NewVersionSet(dir, nil)
+	if err := again.Recover(); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, again.Current(), 0, 6)
+}